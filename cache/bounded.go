@@ -0,0 +1,355 @@
+package cache
+
+import (
+	"container/heap"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+)
+
+// EvictionPolicy selects the entries a BoundedInMemoryCache evicts first.
+type EvictionPolicy int
+
+const (
+	LRU EvictionPolicy = iota // Evict the least recently used entry.
+	LFU                       // Evict the least frequently used entry (least recently used among equals).
+)
+
+// CacheStats holds the counters of a BoundedInMemoryCache.
+type CacheStats struct {
+	Hits, Misses, Evictions uint64
+	Entries                 int
+	Bytes                   int64
+}
+
+// BoundedInMemoryCache is an in-memory cache holding at most a given number of
+// entries and a given number of bytes.  The size of an entry is the length of
+// its serialized value (see Serialize).  When a limit is exceeded, entries are
+// evicted according to the eviction policy.
+//
+// Expired entries are removed when they are accessed, or evicted as usual.
+type BoundedInMemoryCache struct {
+	// Called for every entry evicted to respect the limits, or removed because
+	// it expired.  It is called without holding the cache lock.
+	OnEvicted func(key string, value interface{})
+
+	mu                sync.Mutex
+	defaultExpiration time.Duration
+	maxEntries        int   // 0 for no limit
+	maxBytes          int64 // 0 for no limit
+	entries           map[string]*boundedEntry
+	queue             evictionQueue
+	bytes             int64
+	tick              uint64
+	stats             CacheStats
+}
+
+type boundedEntry struct {
+	key        string
+	value      interface{}
+	size       int64
+	expiration time.Time // zero for no expiration
+	frequency  uint64
+	lastUsed   uint64
+	index      int // in the eviction queue
+}
+
+type evictedEntry struct {
+	key   string
+	value interface{}
+}
+
+// NewBoundedInMemoryCache returns an in-memory cache limited to maxEntries
+// entries and maxBytes bytes.  A limit of 0 disables it.
+func NewBoundedInMemoryCache(defaultExpiration time.Duration, maxEntries int, maxBytes int64, policy EvictionPolicy) *BoundedInMemoryCache {
+	return &BoundedInMemoryCache{
+		defaultExpiration: defaultExpiration,
+		maxEntries:        maxEntries,
+		maxBytes:          maxBytes,
+		entries:           make(map[string]*boundedEntry),
+		queue:             evictionQueue{policy: policy},
+	}
+}
+
+func (c *BoundedInMemoryCache) Get(key string, ptrValue interface{}) error {
+	c.mu.Lock()
+	entry, evicted := c.lookup(key)
+	if entry == nil {
+		c.stats.Misses++
+	} else {
+		c.stats.Hits++
+	}
+	c.mu.Unlock()
+	c.notify(evicted)
+
+	if entry == nil {
+		return ErrCacheMiss
+	}
+
+	v := reflect.ValueOf(ptrValue)
+	if v.Type().Kind() == reflect.Ptr && v.Elem().CanSet() {
+		v.Elem().Set(reflect.ValueOf(entry.value))
+		return nil
+	}
+
+	err := fmt.Errorf("revel/cache: attempt to get %s, but can not set value %v", key, v)
+	glog.Error(err)
+	return err
+}
+
+func (c *BoundedInMemoryCache) GetMulti(keys ...string) (Getter, error) {
+	return c, nil
+}
+
+func (c *BoundedInMemoryCache) Set(key string, value interface{}, expires time.Duration) error {
+	return c.store(key, value, expires, func(exists bool) bool { return true })
+}
+
+func (c *BoundedInMemoryCache) Add(key string, value interface{}, expires time.Duration) error {
+	return c.store(key, value, expires, func(exists bool) bool { return !exists })
+}
+
+func (c *BoundedInMemoryCache) Replace(key string, value interface{}, expires time.Duration) error {
+	return c.store(key, value, expires, func(exists bool) bool { return exists })
+}
+
+func (c *BoundedInMemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	entry, evicted := c.lookup(key)
+	if entry != nil {
+		c.remove(entry)
+	}
+	c.mu.Unlock()
+	c.notify(evicted)
+
+	if entry == nil {
+		return ErrCacheMiss
+	}
+	return nil
+}
+
+func (c *BoundedInMemoryCache) Increment(key string, n uint64) (newValue uint64, err error) {
+	return c.update(key, func(value uint64) uint64 {
+		return value + n
+	})
+}
+
+func (c *BoundedInMemoryCache) Decrement(key string, n uint64) (newValue uint64, err error) {
+	return c.update(key, func(value uint64) uint64 {
+		if n > value {
+			return 0
+		}
+		return value - n
+	})
+}
+
+func (c *BoundedInMemoryCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*boundedEntry)
+	c.queue.entries = nil
+	c.bytes = 0
+	return nil
+}
+
+// Stats returns the current counters of the cache.
+func (c *BoundedInMemoryCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// Store the value if the given condition on the existence of the key holds.
+func (c *BoundedInMemoryCache) store(key string, value interface{}, expires time.Duration, condition func(exists bool) bool) error {
+	b, err := Serialize(value)
+	if err != nil {
+		return err
+	}
+	size := int64(len(b))
+	if c.maxBytes > 0 && size > c.maxBytes {
+		err = fmt.Errorf("revel/cache: value of %s (%d bytes) exceeds the cache size (%d bytes)", key, size, c.maxBytes)
+		glog.Error(err)
+		return err
+	}
+
+	c.mu.Lock()
+	existing, evicted := c.lookup(key)
+	if !condition(existing != nil) {
+		c.mu.Unlock()
+		c.notify(evicted)
+		return ErrNotStored
+	}
+	if existing != nil {
+		c.remove(existing)
+	}
+
+	// Make room before adding the entry, as it would otherwise be the first
+	// candidate for eviction under LFU.
+	evicted = append(evicted, c.evict(1, size)...)
+
+	entry := &boundedEntry{
+		key:        key,
+		value:      value,
+		size:       size,
+		expiration: c.expiration(expires),
+	}
+	c.touch(entry)
+	c.entries[key] = entry
+	heap.Push(&c.queue, entry)
+	c.bytes += size
+	c.mu.Unlock()
+	c.notify(evicted)
+	return nil
+}
+
+// Apply the given function to the integer stored at the key.
+func (c *BoundedInMemoryCache) update(key string, f func(uint64) uint64) (uint64, error) {
+	c.mu.Lock()
+	entry, evicted := c.lookup(key)
+	if entry == nil {
+		c.mu.Unlock()
+		c.notify(evicted)
+		return 0, ErrCacheMiss
+	}
+
+	var newValue uint64
+	v := reflect.ValueOf(entry.value)
+	updated := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			c.mu.Unlock()
+			c.notify(evicted)
+			err := fmt.Errorf("revel/cache: value of %s is negative", key)
+			glog.Error(err)
+			return 0, err
+		}
+		newValue = f(uint64(v.Int()))
+		updated.SetInt(int64(newValue))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		newValue = f(v.Uint())
+		updated.SetUint(newValue)
+	default:
+		c.mu.Unlock()
+		c.notify(evicted)
+		err := fmt.Errorf("revel/cache: value of %s is not an integer", key)
+		glog.Error(err)
+		return 0, err
+	}
+
+	// The serialized size of an integer is the length of its decimal form.
+	size := int64(len(fmt.Sprint(updated.Interface())))
+	c.bytes += size - entry.size
+	entry.value, entry.size = updated.Interface(), size
+
+	evicted = append(evicted, c.evict(0, 0)...)
+	c.mu.Unlock()
+	c.notify(evicted)
+	return newValue, nil
+}
+
+// Return the live entry of the key, marking it used, along with the entry
+// removed if it had expired.
+func (c *BoundedInMemoryCache) lookup(key string) (*boundedEntry, []evictedEntry) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if !entry.expiration.IsZero() && !time.Now().Before(entry.expiration) {
+		c.remove(entry)
+		return nil, []evictedEntry{{entry.key, entry.value}}
+	}
+	c.touch(entry)
+	heap.Fix(&c.queue, entry.index)
+	return entry, nil
+}
+
+func (c *BoundedInMemoryCache) touch(entry *boundedEntry) {
+	c.tick++
+	entry.frequency++
+	entry.lastUsed = c.tick
+}
+
+func (c *BoundedInMemoryCache) remove(entry *boundedEntry) {
+	heap.Remove(&c.queue, entry.index)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+// Evict entries until the cache has room for the given number of additional
+// entries and bytes within its limits.
+func (c *BoundedInMemoryCache) evict(entries int, bytes int64) []evictedEntry {
+	var evicted []evictedEntry
+	for len(c.entries) > 0 &&
+		((c.maxEntries > 0 && len(c.entries)+entries > c.maxEntries) ||
+			(c.maxBytes > 0 && c.bytes+bytes > c.maxBytes)) {
+		entry := c.queue.entries[0]
+		c.remove(entry)
+		c.stats.Evictions++
+		evicted = append(evicted, evictedEntry{entry.key, entry.value})
+	}
+	return evicted
+}
+
+func (c *BoundedInMemoryCache) notify(evicted []evictedEntry) {
+	if c.OnEvicted == nil {
+		return
+	}
+	for _, entry := range evicted {
+		c.OnEvicted(entry.key, entry.value)
+	}
+}
+
+func (c *BoundedInMemoryCache) expiration(expires time.Duration) time.Time {
+	switch expires {
+	case DEFAULT:
+		expires = c.defaultExpiration
+	case FOREVER:
+		return time.Time{}
+	}
+	if expires <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expires)
+}
+
+// evictionQueue is a heap of entries with the next one to evict first.
+type evictionQueue struct {
+	entries []*boundedEntry
+	policy  EvictionPolicy
+}
+
+func (q evictionQueue) Len() int { return len(q.entries) }
+
+func (q evictionQueue) Less(i, j int) bool {
+	a, b := q.entries[i], q.entries[j]
+	if q.policy == LFU && a.frequency != b.frequency {
+		return a.frequency < b.frequency
+	}
+	return a.lastUsed < b.lastUsed
+}
+
+func (q evictionQueue) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+	q.entries[i].index = i
+	q.entries[j].index = j
+}
+
+func (q *evictionQueue) Push(x interface{}) {
+	entry := x.(*boundedEntry)
+	entry.index = len(q.entries)
+	q.entries = append(q.entries, entry)
+}
+
+func (q *evictionQueue) Pop() interface{} {
+	last := len(q.entries) - 1
+	entry := q.entries[last]
+	q.entries = q.entries[:last]
+	return entry
+}
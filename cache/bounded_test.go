@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+var newBoundedInMemoryCache = func(_ *testing.T, defaultExpiration time.Duration) Cache {
+	return NewBoundedInMemoryCache(defaultExpiration, 100, 1<<20, LRU)
+}
+
+func TestBoundedInMemoryCache_TypicalGetSet(t *testing.T) {
+	typicalGetSet(t, newBoundedInMemoryCache)
+}
+
+func TestBoundedInMemoryCache_IncrDecr(t *testing.T) {
+	incrDecr(t, newBoundedInMemoryCache)
+}
+
+func TestBoundedInMemoryCache_Expiration(t *testing.T) {
+	expiration(t, newBoundedInMemoryCache)
+}
+
+func TestBoundedInMemoryCache_EmptyCache(t *testing.T) {
+	emptyCache(t, newBoundedInMemoryCache)
+}
+
+func TestBoundedInMemoryCache_Replace(t *testing.T) {
+	testReplace(t, newBoundedInMemoryCache)
+}
+
+func TestBoundedInMemoryCache_Add(t *testing.T) {
+	testAdd(t, newBoundedInMemoryCache)
+}
+
+func TestBoundedInMemoryCache_GetMulti(t *testing.T) {
+	testGetMulti(t, newBoundedInMemoryCache)
+}
+
+func TestBoundedInMemoryCache_LRU(t *testing.T) {
+	cache := NewBoundedInMemoryCache(time.Hour, 3, 0, LRU)
+	var evicted []string
+	cache.OnEvicted = func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	}
+
+	cache.Set("a", 1, DEFAULT)
+	cache.Set("b", 2, DEFAULT)
+	cache.Set("c", 3, DEFAULT)
+
+	// Use "a", so that "b" is the least recently used.
+	var i int
+	if err := cache.Get("a", &i); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	cache.Set("d", 4, DEFAULT)
+
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Errorf("Expected b to be evicted, got %v", evicted)
+	}
+	if err := cache.Get("b", &i); err != ErrCacheMiss {
+		t.Errorf("Expected cache miss for an evicted key, got: %v", err)
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if err := cache.Get(key, &i); err != nil {
+			t.Errorf("Expected %s to be kept, got: %s", key, err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 4 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestBoundedInMemoryCache_LFU(t *testing.T) {
+	cache := NewBoundedInMemoryCache(time.Hour, 3, 0, LFU)
+	cache.Set("a", 1, DEFAULT)
+	cache.Set("b", 2, DEFAULT)
+	cache.Set("c", 3, DEFAULT)
+
+	// "a" and "c" are used more often than "b", even though "b" was used last.
+	var i int
+	cache.Get("a", &i)
+	cache.Get("c", &i)
+	cache.Get("c", &i)
+	cache.Get("b", &i)
+	cache.Get("a", &i)
+	cache.Set("d", 4, DEFAULT)
+
+	if err := cache.Get("b", &i); err != ErrCacheMiss {
+		t.Errorf("Expected b to be evicted, got: %v", err)
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if err := cache.Get(key, &i); err != nil {
+			t.Errorf("Expected %s to be kept, got: %s", key, err)
+		}
+	}
+}
+
+func TestBoundedInMemoryCache_MaxBytes(t *testing.T) {
+	cache := NewBoundedInMemoryCache(time.Hour, 0, 10, LRU)
+
+	// Integers are serialized in decimal, so each of these takes 4 bytes.
+	cache.Set("a", 1000, DEFAULT)
+	cache.Set("b", 2000, DEFAULT)
+	if stats := cache.Stats(); stats.Bytes != 8 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	cache.Set("c", 3000, DEFAULT)
+	if stats := cache.Stats(); stats.Bytes != 8 || stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	var i int
+	if err := cache.Get("a", &i); err != ErrCacheMiss {
+		t.Errorf("Expected a to be evicted, got: %v", err)
+	}
+
+	// Replacing a value accounts for the size difference.
+	cache.Set("b", 2, DEFAULT)
+	if stats := cache.Stats(); stats.Bytes != 5 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Values larger than the cache are refused.
+	if err := cache.Set("big", []byte("more than ten bytes"), DEFAULT); err == nil {
+		t.Errorf("Expected an error storing a value larger than the cache")
+	}
+
+	cache.Flush()
+	if stats := cache.Stats(); stats.Bytes != 0 || stats.Entries != 0 {
+		t.Errorf("Unexpected stats after flush: %+v", stats)
+	}
+}
+
+func TestBoundedInMemoryCache_IncrDecrErrors(t *testing.T) {
+	cache := NewBoundedInMemoryCache(time.Hour, 0, 0, LRU)
+	var evicted []string
+	cache.OnEvicted = func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	}
+
+	cache.Set("negative", -1, DEFAULT)
+	if _, err := cache.Increment("negative", 1); err == nil {
+		t.Errorf("Expected an error incrementing a negative value")
+	}
+	if _, err := cache.Decrement("negative", 1); err == nil {
+		t.Errorf("Expected an error decrementing a negative value")
+	}
+	var i int
+	if err := cache.Get("negative", &i); err != nil || i != -1 {
+		t.Errorf("Expected the negative value to be kept, got %d: %v", i, err)
+	}
+
+	cache.Set("string", "abc", DEFAULT)
+	if _, err := cache.Increment("string", 1); err == nil {
+		t.Errorf("Expected an error incrementing a string")
+	}
+
+	// The expired entries are notified, even if the update fails.
+	cache.Set("expired", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := cache.Increment("expired", 1); err != ErrCacheMiss {
+		t.Errorf("Expected cache miss incrementing an expired key, got: %v", err)
+	}
+	if len(evicted) != 1 || evicted[0] != "expired" {
+		t.Errorf("Expected the expired key to be notified, got %v", evicted)
+	}
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"errors"
+	"sync"
 	"time"
 )
 
@@ -117,6 +118,11 @@ type Cache interface {
 var (
 	Instance Cache
 
+	// Implements GetOrCompute on the Instance.  It is set on startup along
+	// with the Instance, or else on first use.
+	instanceComputer     *computer
+	instanceComputerLock sync.Mutex
+
 	ErrCacheMiss = errors.New("revel/cache: key not found.")
 	ErrNotStored = errors.New("revel/cache: not stored.")
 )
@@ -138,3 +144,16 @@ func Add(key string, value interface{}, expires time.Duration) error {
 func Replace(key string, value interface{}, expires time.Duration) error {
 	return Instance.Replace(key, value, expires)
 }
+
+// GetOrCompute gets the value of the key, computing and storing it if missing.
+// See LayeredCache.GetOrCompute.  The values are stored in the Instance set on
+// startup, or else the one at the first call.
+func GetOrCompute(key string, ptrValue interface{}, expires time.Duration, compute ComputeFunc) error {
+	instanceComputerLock.Lock()
+	if instanceComputer == nil {
+		instanceComputer = newComputer(Instance, 0)
+	}
+	c := instanceComputer
+	instanceComputerLock.Unlock()
+	return c.GetOrCompute(key, ptrValue, expires, compute)
+}
@@ -1,10 +1,12 @@
 package cache
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/robfig/humanize"
 )
 
 func init() {
@@ -18,6 +20,23 @@ func init() {
 			}
 		}
 
+		// Coalesce and refresh computations with GetOrCompute on whichever cache is used.
+		defer func() {
+			instanceComputerLock.Lock()
+			defer instanceComputerLock.Unlock()
+			if layered, ok := Instance.(*LayeredCache); ok {
+				instanceComputer = layered.computer
+			} else {
+				instanceComputer = newComputer(Instance, defaultExpiration)
+			}
+			if betaStr, found := revel.Config.String("cache.compute.beta"); found {
+				var err error
+				if instanceComputer.Beta, err = strconv.ParseFloat(betaStr, 64); err != nil {
+					panic("Could not parse cache.compute.beta " + betaStr + ": " + err.Error())
+				}
+			}
+		}()
+
 		// Use memcached?
 		if revel.Config.BoolDefault("cache.memcached", false) {
 			hosts := strings.Split(revel.Config.StringDefault("cache.hosts", ""), ",")
@@ -25,7 +44,40 @@ func init() {
 				panic("Memcache enabled but no memcached hosts specified!")
 			}
 
-			Instance = NewMemcachedCache(hosts, defaultExpiration)
+			Instance = withLocalCache(NewMemcachedCache(hosts, defaultExpiration), defaultExpiration)
+			return
+		}
+
+		// Use Redis?
+		if revel.Config.BoolDefault("cache.redis", false) {
+			Instance = withLocalCache(NewRedisCache(
+				revel.Config.StringDefault("cache.redis.host", "localhost:6379"),
+				revel.Config.StringDefault("cache.redis.password", ""),
+				revel.Config.IntDefault("cache.redis.database", 0),
+				revel.Config.IntDefault("cache.redis.poolsize", 0),
+				defaultExpiration), defaultExpiration)
+			return
+		}
+
+		// Limit the in-memory cache?
+		maxEntries := revel.Config.IntDefault("cache.inmemory.maxentries", 0)
+		var maxBytes uint64
+		if maxBytesStr, found := revel.Config.String("cache.inmemory.maxbytes"); found {
+			var err error
+			if maxBytes, err = humanize.ParseBytes(maxBytesStr); err != nil {
+				panic("Could not parse cache.inmemory.maxbytes " + maxBytesStr + ": " + err.Error())
+			}
+		}
+		if maxEntries > 0 || maxBytes > 0 {
+			policy := LRU
+			switch policyStr := revel.Config.StringDefault("cache.inmemory.policy", "lru"); strings.ToLower(policyStr) {
+			case "lru":
+			case "lfu":
+				policy = LFU
+			default:
+				panic("Unknown cache eviction policy " + policyStr + ", expected lru or lfu")
+			}
+			Instance = NewBoundedInMemoryCache(defaultExpiration, maxEntries, int64(maxBytes), policy)
 			return
 		}
 
@@ -33,3 +85,21 @@ func init() {
 		Instance = NewInMemoryCache(defaultExpiration)
 	})
 }
+
+// Put a local near-cache in front of the remote cache, if configured.
+func withLocalCache(remote Cache, defaultExpiration time.Duration) Cache {
+	if !revel.Config.BoolDefault("cache.local", false) {
+		return remote
+	}
+
+	localExpiration := 10 * time.Second
+	if expireStr, found := revel.Config.String("cache.local.expires"); found {
+		var err error
+		if localExpiration, err = time.ParseDuration(expireStr); err != nil {
+			panic("Could not parse local cache expiration duration " + expireStr + ": " + err.Error())
+		}
+	}
+	local := NewBoundedInMemoryCache(localExpiration,
+		revel.Config.IntDefault("cache.local.maxentries", 10000), 0, LRU)
+	return NewLayeredCache(local, remote, localExpiration, defaultExpiration)
+}
@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+)
+
+// ComputeFunc computes a value missing from the cache.
+type ComputeFunc func() (interface{}, error)
+
+// LayeredCache puts a local in-memory cache (the near-cache) in front of a
+// remote one, such as memcached or Redis.
+//
+// Values read from the remote cache are kept locally for at most the local
+// expiration, so they may be stale for that long after another process changed
+// them.  Writes go to the remote cache and replace or invalidate the local copy.
+// The local copies are serialized (see Serialize), like the remote ones, so that
+// changing a value after it is set or got does not change the cached one.
+type LayeredCache struct {
+	Local, Remote   Cache
+	LocalExpiration time.Duration
+	*computer
+}
+
+// NewLayeredCache returns a cache keeping values of the remote cache in the
+// local cache for at most localExpiration.  The default expiration should be
+// the one of the remote cache; it is used by GetOrCompute.
+func NewLayeredCache(local, remote Cache, localExpiration, defaultExpiration time.Duration) *LayeredCache {
+	c := &LayeredCache{
+		Local:           local,
+		Remote:          remote,
+		LocalExpiration: localExpiration,
+	}
+	c.computer = newComputer(c, defaultExpiration)
+	return c
+}
+
+func (c *LayeredCache) Get(key string, ptrValue interface{}) error {
+	var data []byte
+	if err := c.Local.Get(key, &data); err == nil {
+		return Deserialize(append([]byte(nil), data...), ptrValue)
+	}
+
+	if err := c.Remote.Get(key, ptrValue); err != nil {
+		return err
+	}
+	c.setLocal(key, reflect.ValueOf(ptrValue).Elem().Interface(), c.LocalExpiration)
+	return nil
+}
+
+func (c *LayeredCache) GetMulti(keys ...string) (Getter, error) {
+	return c.Remote.GetMulti(keys...)
+}
+
+func (c *LayeredCache) Set(key string, value interface{}, expires time.Duration) error {
+	if err := c.Remote.Set(key, value, expires); err != nil {
+		c.Local.Delete(key)
+		return err
+	}
+	c.setLocal(key, value, c.localExpiration(expires))
+	return nil
+}
+
+func (c *LayeredCache) Add(key string, value interface{}, expires time.Duration) error {
+	if err := c.Remote.Add(key, value, expires); err != nil {
+		return err
+	}
+	c.setLocal(key, value, c.localExpiration(expires))
+	return nil
+}
+
+func (c *LayeredCache) Replace(key string, value interface{}, expires time.Duration) error {
+	if err := c.Remote.Replace(key, value, expires); err != nil {
+		c.Local.Delete(key)
+		return err
+	}
+	c.setLocal(key, value, c.localExpiration(expires))
+	return nil
+}
+
+func (c *LayeredCache) Delete(key string) error {
+	c.Local.Delete(key)
+	return c.Remote.Delete(key)
+}
+
+func (c *LayeredCache) Increment(key string, n uint64) (newValue uint64, err error) {
+	c.Local.Delete(key)
+	return c.Remote.Increment(key, n)
+}
+
+func (c *LayeredCache) Decrement(key string, n uint64) (newValue uint64, err error) {
+	c.Local.Delete(key)
+	return c.Remote.Decrement(key, n)
+}
+
+func (c *LayeredCache) Flush() error {
+	c.Local.Flush()
+	return c.Remote.Flush()
+}
+
+// Keep a serialized copy of the value locally, or none if it can not be
+// serialized.
+func (c *LayeredCache) setLocal(key string, value interface{}, expires time.Duration) {
+	data, err := Serialize(value)
+	if err != nil {
+		c.Local.Delete(key)
+		return
+	}
+	c.Local.Set(key, append([]byte(nil), data...), expires)
+}
+
+// Keep values locally no longer than they live remotely.
+func (c *LayeredCache) localExpiration(expires time.Duration) time.Duration {
+	if expires > 0 && expires < c.LocalExpiration {
+		return expires
+	}
+	return c.LocalExpiration
+}
+
+// computer implements GetOrCompute on top of a cache.
+type computer struct {
+	cache             Cache
+	defaultExpiration time.Duration
+
+	// Beta scales how early values are refreshed before they expire.  Values
+	// above 1 favor earlier refreshes, values below 1 later ones; 0 disables
+	// early refreshes.  It defaults to 1.
+	Beta float64
+
+	mu    sync.Mutex
+	calls map[string]*computeCall
+}
+
+// An in-flight computation of a key.
+type computeCall struct {
+	wg   sync.WaitGroup
+	item computedItem
+	err  error
+}
+
+// computedItem is how GetOrCompute stores values: along with their expiration
+// and the time it took to compute them, which together decide when to refresh.
+type computedItem struct {
+	Data   []byte // The serialized value
+	Expiry int64  // Unix time in nanoseconds, or 0 if the value does not expire
+	Delta  int64  // Time taken to compute the value, in nanoseconds
+}
+
+func newComputer(cache Cache, defaultExpiration time.Duration) *computer {
+	return &computer{
+		cache:             cache,
+		defaultExpiration: defaultExpiration,
+		Beta:              1,
+		calls:             make(map[string]*computeCall),
+	}
+}
+
+// GetOrCompute gets the value of the key into ptrValue, calling compute to
+// produce and store it (for the given duration) if it is not in the cache.
+//
+// Concurrent misses of the same key in this process share a single call to
+// compute.  As a value nears its expiration, callers refresh it in the
+// background with a probability that rises as the expiration approaches and the
+// longer the value took to compute ("probabilistic early expiration"), so that
+// processes sharing a cache rarely recompute a hot key at the same moment.
+//
+// Values stored by GetOrCompute should only be read through GetOrCompute.
+func (c *computer) GetOrCompute(key string, ptrValue interface{}, expires time.Duration, compute ComputeFunc) error {
+	var item computedItem
+	err := c.cache.Get(key, &item)
+	if err == nil {
+		if c.refreshEarly(item) {
+			glog.V(1).Infof("revel/cache: refreshing %s before it expires", key)
+			go func() {
+				if _, err := c.compute(key, expires, compute); err != nil {
+					glog.Warningf("revel/cache: failed to refresh %s: %s", key, err)
+				}
+			}()
+		}
+		return Deserialize(item.Data, ptrValue)
+	}
+	if err != ErrCacheMiss {
+		glog.Warningf("revel/cache: failed to get %s, computing it: %s", key, err)
+	}
+
+	if item, err = c.compute(key, expires, compute); err != nil {
+		return err
+	}
+	return Deserialize(item.Data, ptrValue)
+}
+
+// Compute the value of the key and store it, or wait for the computation
+// already in progress.  A panic of compute is returned as an error, to every
+// caller waiting for it.
+func (c *computer) compute(key string, expires time.Duration, compute ComputeFunc) (computedItem, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.item, call.err
+	}
+	call := new(computeCall)
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	start := time.Now()
+	value, err := callCompute(compute)
+	if err != nil {
+		call.err = err
+		return call.item, err
+	}
+	if call.item.Data, err = Serialize(value); err != nil {
+		call.err = err
+		return call.item, err
+	}
+	call.item.Delta = int64(time.Since(start))
+
+	switch expires {
+	case DEFAULT:
+		expires = c.defaultExpiration
+	case FOREVER:
+		expires = 0
+	}
+	if expires > 0 {
+		call.item.Expiry = time.Now().Add(expires).UnixNano()
+	}
+
+	if err = c.cache.Set(key, call.item, expires); err != nil {
+		glog.Warningf("revel/cache: failed to store computed %s: %s", key, err)
+	}
+	return call.item, nil
+}
+
+// Call compute, turning a panic into an error.
+func callCompute(compute ComputeFunc) (value interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			glog.Errorf("revel/cache: compute panicked: %v\n%s", recovered, debug.Stack())
+			err = fmt.Errorf("revel/cache: compute panicked: %v", recovered)
+		}
+	}()
+	return compute()
+}
+
+// Decide whether to refresh the item now, following the XFetch algorithm:
+// refresh when now - delta * beta * ln(rand()) >= expiry.
+func (c *computer) refreshEarly(item computedItem) bool {
+	if item.Expiry == 0 || c.Beta <= 0 {
+		return false
+	}
+	gap := float64(item.Delta) * c.Beta * -math.Log(1-rand.Float64())
+	return float64(time.Now().UnixNano())+gap >= float64(item.Expiry)
+}
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var newLayeredCache = func(_ *testing.T, defaultExpiration time.Duration) Cache {
+	return NewLayeredCache(NewInMemoryCache(time.Second), NewInMemoryCache(defaultExpiration),
+		time.Second, defaultExpiration)
+}
+
+func TestLayeredCache_TypicalGetSet(t *testing.T) {
+	typicalGetSet(t, newLayeredCache)
+}
+
+func TestLayeredCache_IncrDecr(t *testing.T) {
+	incrDecr(t, newLayeredCache)
+}
+
+func TestLayeredCache_Expiration(t *testing.T) {
+	expiration(t, newLayeredCache)
+}
+
+func TestLayeredCache_EmptyCache(t *testing.T) {
+	emptyCache(t, newLayeredCache)
+}
+
+func TestLayeredCache_Replace(t *testing.T) {
+	testReplace(t, newLayeredCache)
+}
+
+func TestLayeredCache_Add(t *testing.T) {
+	testAdd(t, newLayeredCache)
+}
+
+func TestLayeredCache_GetMulti(t *testing.T) {
+	testGetMulti(t, newLayeredCache)
+}
+
+func TestLayeredCache_NearCache(t *testing.T) {
+	remote := NewInMemoryCache(time.Hour)
+	cache := NewLayeredCache(NewInMemoryCache(time.Hour), remote, 50*time.Millisecond, time.Hour)
+
+	remote.Set("key", "remote", DEFAULT)
+	var value string
+	if err := cache.Get("key", &value); err != nil || value != "remote" {
+		t.Fatalf("Expected the remote value, got %q (%v)", value, err)
+	}
+
+	// Another process changes the value: the local copy is served until it expires.
+	remote.Set("key", "changed", DEFAULT)
+	if err := cache.Get("key", &value); err != nil || value != "remote" {
+		t.Errorf("Expected the local value, got %q (%v)", value, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := cache.Get("key", &value); err != nil || value != "changed" {
+		t.Errorf("Expected the changed remote value, got %q (%v)", value, err)
+	}
+
+	// Writes through the layered cache are seen at once.
+	cache.Set("key", "written", DEFAULT)
+	if err := cache.Get("key", &value); err != nil || value != "written" {
+		t.Errorf("Expected the written value, got %q (%v)", value, err)
+	}
+	cache.Delete("key")
+	if err := cache.Get("key", &value); err != ErrCacheMiss {
+		t.Errorf("Expected a cache miss after delete, got %q (%v)", value, err)
+	}
+}
+
+func TestLayeredCache_LocalCopies(t *testing.T) {
+	cache := NewLayeredCache(NewInMemoryCache(time.Hour), NewInMemoryCache(time.Hour), time.Hour, time.Hour)
+
+	// Changing a value after setting it, or after getting it, does not change
+	// the cached value.
+	set := []string{"a", "b"}
+	cache.Set("slice", set, DEFAULT)
+	set[0] = "changed"
+	var got []string
+	if err := cache.Get("slice", &got); err != nil || got[0] != "a" {
+		t.Fatalf("Expected the value as set, got %v (%v)", got, err)
+	}
+	got[1] = "changed"
+	var again []string
+	if err := cache.Get("slice", &again); err != nil || again[1] != "b" {
+		t.Errorf("Expected the value as set, got %v (%v)", again, err)
+	}
+
+	// Getting a value of another type fails, as it does remotely.
+	var wrongType map[string]int
+	if err := cache.Get("slice", &wrongType); err == nil {
+		t.Errorf("Expected an error getting a value of another type")
+	}
+}
+
+func TestLayeredCache_GetOrCompute(t *testing.T) {
+	cache := newLayeredCache(t, time.Hour).(*LayeredCache)
+
+	// Concurrent misses share a single computation.
+	var calls int32
+	compute := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return "computed", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var value string
+			if err := cache.GetOrCompute("key", &value, DEFAULT, compute); err != nil || value != "computed" {
+				t.Errorf("Expected the computed value, got %q (%v)", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("Expected a single computation, got %d", calls)
+	}
+
+	// Later calls are served from the cache.
+	var value string
+	if err := cache.GetOrCompute("key", &value, DEFAULT, compute); err != nil || value != "computed" {
+		t.Errorf("Expected the cached value, got %q (%v)", value, err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no further computation, got %d", calls)
+	}
+
+	// Errors are returned and nothing is stored.
+	failed := errors.New("failed")
+	err := cache.GetOrCompute("error", &value, DEFAULT, func() (interface{}, error) { return nil, failed })
+	if err != failed {
+		t.Errorf("Expected the computation error, got %v", err)
+	}
+	var item computedItem
+	if err = cache.Get("error", &item); err != ErrCacheMiss {
+		t.Errorf("Expected nothing stored for a failed computation, got %v", err)
+	}
+}
+
+func TestLayeredCache_GetOrComputeEarlyRefresh(t *testing.T) {
+	cache := newLayeredCache(t, time.Hour).(*LayeredCache)
+	cache.Beta = 1e12 // Values are refreshed almost as soon as they are computed.
+
+	var calls int32
+	compute := func() (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return int(atomic.AddInt32(&calls, 1)), nil
+	}
+	var value int
+	if err := cache.GetOrCompute("key", &value, time.Minute, compute); err != nil || value != 1 {
+		t.Fatalf("Expected the first computed value, got %d (%v)", value, err)
+	}
+
+	// The current value is returned while it is refreshed in the background.
+	if err := cache.GetOrCompute("key", &value, time.Minute, compute); err != nil || value != 1 {
+		t.Errorf("Expected the cached value, got %d (%v)", value, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&calls) < 2 {
+		t.Errorf("Expected the value to be refreshed early")
+	}
+
+	// Values cached forever are never refreshed early.
+	cache.Flush()
+	atomic.StoreInt32(&calls, 0)
+	cache.GetOrCompute("forever", &value, FOREVER, compute)
+	cache.GetOrCompute("forever", &value, FOREVER, compute)
+	time.Sleep(50 * time.Millisecond)
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Errorf("Expected a single computation, got %d", calls)
+	}
+}
+
+func TestLayeredCache_GetOrComputePanic(t *testing.T) {
+	cache := newLayeredCache(t, time.Hour).(*LayeredCache)
+
+	// A panic is returned as an error to every caller waiting for it.
+	compute := func() (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		panic("boom")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var value string
+			if err := cache.GetOrCompute("key", &value, DEFAULT, compute); err == nil {
+				t.Errorf("Expected the panic as an error, got %q", value)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A panic refreshing a value in the background is recovered as well.
+	cache.Beta = 1e12
+	var value int
+	if err := cache.GetOrCompute("refreshed", &value, time.Minute, func() (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return 1, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.GetOrCompute("refreshed", &value, time.Minute, func() (interface{}, error) {
+		panic("boom")
+	}); err != nil || value != 1 {
+		t.Errorf("Expected the cached value, got %d (%v)", value, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/garyburd/redigo/redis"
+)
+
+// The number of times a read-modify-write operation (Increment, Decrement) is
+// retried when the key is modified concurrently.
+const redisMaxRetries = 16
+
+// Wraps the Redis client to meet the Cache interface.
+type RedisCache struct {
+	pool              *redis.Pool
+	defaultExpiration time.Duration
+}
+
+// NewRedisCache returns a cache backed by the Redis server at the given host
+// ("host:port").  The password may be empty, database selects the Redis
+// database and poolSize limits the number of open connections (0 for no limit).
+func NewRedisCache(host, password string, database, poolSize int, defaultExpiration time.Duration) RedisCache {
+	maxIdle := poolSize
+	if maxIdle == 0 {
+		maxIdle = 8
+	}
+	pool := &redis.Pool{
+		MaxIdle:     maxIdle,
+		MaxActive:   poolSize,
+		Wait:        poolSize > 0,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			conn, err := redis.Dial("tcp", host)
+			if err != nil {
+				return nil, err
+			}
+			if password != "" {
+				if _, err = conn.Do("AUTH", password); err != nil {
+					conn.Close()
+					return nil, err
+				}
+			}
+			if database != 0 {
+				if _, err = conn.Do("SELECT", database); err != nil {
+					conn.Close()
+					return nil, err
+				}
+			}
+			return conn, nil
+		},
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Minute {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+	return RedisCache{pool, defaultExpiration}
+}
+
+func (c RedisCache) Set(key string, value interface{}, expires time.Duration) error {
+	_, err := c.set(key, value, expires)
+	return err
+}
+
+func (c RedisCache) Add(key string, value interface{}, expires time.Duration) error {
+	return c.setIf("NX", key, value, expires)
+}
+
+func (c RedisCache) Replace(key string, value interface{}, expires time.Duration) error {
+	return c.setIf("XX", key, value, expires)
+}
+
+// Set the key only if the given condition (NX or XX) holds.
+func (c RedisCache) setIf(condition, key string, value interface{}, expires time.Duration) error {
+	stored, err := c.set(key, value, expires, condition)
+	if err == nil && stored == nil {
+		return ErrNotStored
+	}
+	return err
+}
+
+func (c RedisCache) Get(key string, ptrValue interface{}) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	item, err := redis.Bytes(conn.Do("GET", key))
+	if err != nil {
+		return convertRedisError(err)
+	}
+	return Deserialize(item, ptrValue)
+}
+
+func (c RedisCache) GetMulti(keys ...string) (Getter, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	items, err := redis.ByteSlices(conn.Do("MGET", args...))
+	if err != nil {
+		return nil, convertRedisError(err)
+	}
+
+	getter := make(RedisItemMapGetter, len(keys))
+	for i, item := range items {
+		if item != nil {
+			getter[keys[i]] = item
+		}
+	}
+	return getter, nil
+}
+
+func (c RedisCache) Delete(key string) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	deleted, err := redis.Int(conn.Do("DEL", key))
+	if err != nil {
+		return convertRedisError(err)
+	}
+	if deleted == 0 {
+		return ErrCacheMiss
+	}
+	return nil
+}
+
+func (c RedisCache) Increment(key string, delta uint64) (newValue uint64, err error) {
+	return c.update(key, func(value uint64) uint64 {
+		return value + delta
+	})
+}
+
+func (c RedisCache) Decrement(key string, delta uint64) (newValue uint64, err error) {
+	return c.update(key, func(value uint64) uint64 {
+		if delta > value {
+			return 0
+		}
+		return value - delta
+	})
+}
+
+func (c RedisCache) Flush() error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("FLUSHDB")
+	return convertRedisError(err)
+}
+
+// Run a SET command for the given key and value, with the expiration applied
+// and the given extra arguments (NX, XX) appended.
+func (c RedisCache) set(key string, value interface{}, expires time.Duration, extra ...interface{}) (interface{}, error) {
+	switch expires {
+	case DEFAULT:
+		expires = c.defaultExpiration
+	case FOREVER:
+		expires = time.Duration(0)
+	}
+
+	b, err := Serialize(value)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{key, b}
+	if expires > 0 {
+		args = append(args, "PX", int64(expires/time.Millisecond))
+	}
+
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	reply, err := conn.Do("SET", append(args, extra...)...)
+	return reply, convertRedisError(err)
+}
+
+// Apply the given function to the counter stored at the key, keeping its expiration.
+//
+// Redis' own INCRBY and DECRBY work on signed 64-bit integers and create missing
+// keys, so instead the value is updated in a WATCH / MULTI transaction, which is
+// retried if the key was changed concurrently.
+func (c RedisCache) update(key string, f func(uint64) uint64) (uint64, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	for i := 0; i < redisMaxRetries; i++ {
+		if _, err := conn.Do("WATCH", key); err != nil {
+			return 0, convertRedisError(err)
+		}
+
+		value, err := redis.Uint64(conn.Do("GET", key))
+		if err != nil {
+			conn.Do("UNWATCH")
+			return 0, convertRedisError(err)
+		}
+		ttl, err := redis.Int64(conn.Do("PTTL", key))
+		if err != nil {
+			conn.Do("UNWATCH")
+			return 0, convertRedisError(err)
+		}
+
+		newValue := f(value)
+		conn.Send("MULTI")
+		if ttl > 0 {
+			conn.Send("SET", key, newValue, "PX", ttl)
+		} else {
+			conn.Send("SET", key, newValue)
+		}
+		reply, err := conn.Do("EXEC")
+		if err != nil {
+			return 0, convertRedisError(err)
+		}
+		if reply != nil {
+			return newValue, nil
+		}
+		glog.V(1).Infof("revel/cache: %s was modified concurrently, retrying", key)
+	}
+
+	err := errors.New("revel/cache: too many concurrent modifications of " + key)
+	glog.Error(err)
+	return 0, err
+}
+
+// Implement a Getter on top of the values returned by MGET.
+type RedisItemMapGetter map[string][]byte
+
+func (g RedisItemMapGetter) Get(key string, ptrValue interface{}) error {
+	item, ok := g[key]
+	if !ok {
+		return ErrCacheMiss
+	}
+
+	return Deserialize(item, ptrValue)
+}
+
+func convertRedisError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case redis.ErrNil:
+		return ErrCacheMiss
+	}
+
+	glog.Error("revel/cache:", err)
+	return err
+}
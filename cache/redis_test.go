@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// These tests run against redis on localhost:6379 (the default) if it is
+// running, and against an in-process stub of the server otherwise.
+const redisTestServer = "localhost:6379"
+
+var redisStubAddr string
+
+var newRedisCache = func(t *testing.T, defaultExpiration time.Duration) Cache {
+	addr := redisTestServer
+	if c, err := net.Dial("tcp", addr); err == nil {
+		c.Close()
+	} else {
+		if redisStubAddr == "" {
+			if redisStubAddr, err = startRedisStub(); err != nil {
+				t.Fatalf("couldn't connect to redis on %s or start a stub: %s", redisTestServer, err)
+			}
+		}
+		addr = redisStubAddr
+	}
+
+	cache := NewRedisCache(addr, "", 0, 0, defaultExpiration)
+	if err := cache.Flush(); err != nil {
+		t.Fatalf("couldn't flush redis on %s: %s", addr, err)
+	}
+	return cache
+}
+
+func TestRedisCache_TypicalGetSet(t *testing.T) {
+	typicalGetSet(t, newRedisCache)
+}
+
+func TestRedisCache_IncrDecr(t *testing.T) {
+	incrDecr(t, newRedisCache)
+}
+
+func TestRedisCache_Expiration(t *testing.T) {
+	expiration(t, newRedisCache)
+}
+
+func TestRedisCache_EmptyCache(t *testing.T) {
+	emptyCache(t, newRedisCache)
+}
+
+func TestRedisCache_Replace(t *testing.T) {
+	testReplace(t, newRedisCache)
+}
+
+func TestRedisCache_Add(t *testing.T) {
+	testAdd(t, newRedisCache)
+}
+
+func TestRedisCache_GetMulti(t *testing.T) {
+	testGetMulti(t, newRedisCache)
+}
+
+// A minimal in-process Redis server, implementing the commands used by RedisCache.
+type redisStub struct {
+	sync.Mutex
+	values   map[string]string
+	expires  map[string]time.Time
+	versions map[string]int
+}
+
+func startRedisStub() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	stub := &redisStub{
+		values:   make(map[string]string),
+		expires:  make(map[string]time.Time),
+		versions: make(map[string]int),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+func (s *redisStub) serve(conn net.Conn) {
+	defer conn.Close()
+	var (
+		r       = bufio.NewReader(conn)
+		watched map[string]int
+		queued  [][]string
+		inMulti bool
+	)
+	for {
+		args, err := readRedisCommand(r)
+		if err != nil {
+			return
+		}
+		s.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "WATCH":
+			watched = make(map[string]int)
+			for _, key := range args[1:] {
+				watched[key] = s.versions[key]
+			}
+			reply = "+OK\r\n"
+		case "UNWATCH":
+			watched, reply = nil, "+OK\r\n"
+		case "MULTI":
+			inMulti, queued, reply = true, nil, "+OK\r\n"
+		case "EXEC":
+			conflict := false
+			for key, version := range watched {
+				conflict = conflict || s.versions[key] != version
+			}
+			if conflict {
+				reply = "*-1\r\n"
+			} else {
+				reply = fmt.Sprintf("*%d\r\n", len(queued))
+				for _, command := range queued {
+					reply += s.execute(command)
+				}
+			}
+			inMulti, queued, watched = false, nil, nil
+		default:
+			if inMulti {
+				queued, reply = append(queued, args), "+QUEUED\r\n"
+			} else {
+				reply = s.execute(args)
+			}
+		}
+		s.Unlock()
+		if _, err = io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *redisStub) get(key string) (string, bool) {
+	if expires, ok := s.expires[key]; ok && !time.Now().Before(expires) {
+		s.del(key)
+	}
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func (s *redisStub) del(key string) {
+	delete(s.values, key)
+	delete(s.expires, key)
+	s.versions[key]++
+}
+
+func (s *redisStub) execute(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "FLUSHDB":
+		for key := range s.values {
+			s.del(key)
+		}
+		return "+OK\r\n"
+	case "GET":
+		if value, ok := s.get(args[1]); ok {
+			return redisBulk(value)
+		}
+		return "$-1\r\n"
+	case "MGET":
+		reply := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, key := range args[1:] {
+			if value, ok := s.get(key); ok {
+				reply += redisBulk(value)
+			} else {
+				reply += "$-1\r\n"
+			}
+		}
+		return reply
+	case "SET":
+		key, value := args[1], args[2]
+		_, exists := s.get(key)
+		var expires time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if exists {
+					return "$-1\r\n"
+				}
+			case "XX":
+				if !exists {
+					return "$-1\r\n"
+				}
+			case "PX":
+				i++
+				ms, _ := strconv.ParseInt(args[i], 10, 64)
+				expires = time.Duration(ms) * time.Millisecond
+			}
+		}
+		s.del(key)
+		s.values[key] = value
+		if expires > 0 {
+			s.expires[key] = time.Now().Add(expires)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		if _, ok := s.get(args[1]); ok {
+			s.del(args[1])
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "PTTL":
+		if _, ok := s.get(args[1]); !ok {
+			return ":-2\r\n"
+		}
+		if expires, ok := s.expires[args[1]]; ok {
+			return fmt.Sprintf(":%d\r\n", time.Until(expires)/time.Millisecond)
+		}
+		return ":-1\r\n"
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func redisBulk(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+// Read a command sent as a RESP array of bulk strings.
+func readRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, length+2)
+		if _, err = io.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+		args[i] = string(value[:length])
+	}
+	return args, nil
+}
@@ -32,6 +32,12 @@ func LoadConfig(confName string) (*MergedConfig, error) {
 	return nil, err
 }
 
+// NewEmptyConfig returns a config without options, to be set with SetOption,
+// e.g. in tests.
+func NewEmptyConfig() *MergedConfig {
+	return &MergedConfig{config.NewDefault(), ""}
+}
+
 func (c *MergedConfig) Raw() *config.Config {
 	return c.config
 }
@@ -67,8 +67,20 @@ func (c *Controller) RenderError(err error) Result {
 	if status == 0 {
 		status = http.StatusInternalServerError
 	}
+	if c.RenderArgs == nil {
+		c.RenderArgs = make(map[string]interface{})
+	}
+	if DevMode {
+		c.RenderArgs["ErrorContext"] = newErrorContext(c)
+	}
 	if status/100 == 5 {
-		glog.Errorf("%d %s: %s", status, http.StatusText(status), err)
+		// Identify the error in the log, to find it from the error page.
+		errorId := newErrorId()
+		c.RenderArgs["ErrorId"] = errorId
+		glog.Errorf("%d %s [%s]: %s", status, http.StatusText(status), errorId, err)
+		if revelError, ok := err.(*Error); ok && revelError.Stack != "" {
+			glog.Error(revelError.Stack)
+		}
 	}
 	return ErrorResult{c.RenderArgs, err}
 }
@@ -254,6 +266,32 @@ func (c *Controller) Message(message string, args ...interface{}) (value string)
 	return Message(c.Request.Locale, message, args...)
 }
 
+// Perform a message lookup for the plural form of the given message matching the count,
+// using the current language defined for this controller.
+func (c *Controller) MessagePlural(message string, count int, args ...interface{}) (value string) {
+	return MessagePlural(c.Request.Locale, message, count, args...)
+}
+
+// SetLocale sets the locale of the current request and keeps it for the following
+// requests: in the session if the "session" locale resolver is configured, and in
+// the locale cookie if the "cookie" resolver is.
+func (c *Controller) SetLocale(locale string) {
+	setCurrentLocaleControllerArguments(c, locale)
+
+	if localeResolverEnabled("session") && c.Session != nil {
+		c.Session[Config.StringDefault(localeSessionConfigKey, "locale")] = locale
+	}
+	if localeResolverEnabled("cookie") {
+		c.SetCookie(&http.Cookie{
+			Name:     Config.StringDefault(localeCookieConfigKey, CookiePrefix+"_LANG"),
+			Value:    locale,
+			Path:     "/",
+			HttpOnly: CookieHttpOnly,
+			Secure:   CookieSecure,
+		})
+	}
+}
+
 // SetAction sets the action that is being invoked in the current request.
 // It sets the following properties: Name, Action, Type, MethodType
 func (c *Controller) SetAction(controllerName, methodName string) error {
@@ -392,3 +430,66 @@ func RegisterController(c interface{}, methods []*MethodType) {
 	}
 	glog.V(1).Infof("Registered controller: %s", elem.Name())
 }
+
+// ControllerRegistration adds actions to a controller registered by Register.
+type ControllerRegistration struct {
+	controllerType *ControllerType
+	method         *MethodType // The last action added.
+}
+
+// Register registers a Controller, whose actions are then added one by one,
+// e.g.
+//
+//	revel.Register((*controllers.Hotels)(nil)).
+//		Action("Index").
+//		Action("Show", "id")
+//
+// The types of the arguments of the actions are found by reflection, so that
+// an app may register its controllers itself, and build without the harness.
+func Register(c interface{}) *ControllerRegistration {
+	RegisterController(c, nil)
+	elem := reflect.TypeOf(c).Elem()
+	return &ControllerRegistration{controllerType: controllers[strings.ToLower(elem.Name())]}
+}
+
+// Action adds the named action method, given the names of its arguments (which
+// are bound to the request parameters).  It panics if the controller has no
+// such method, or if the number of names does not match its arguments.
+func (r *ControllerRegistration) Action(name string, argNames ...string) *ControllerRegistration {
+	// Look the method up on the pointer type, to find the methods of both
+	// receiver types, including the ones of embedded types.
+	ct := r.controllerType
+	method, ok := reflect.PtrTo(ct.Type).MethodByName(name)
+	if !ok {
+		panic(fmt.Sprintf("revel: %s has no action %s", ct.Type.Name(), name))
+	}
+	// The first argument is the receiver.
+	if method.Type.NumIn()-1 != len(argNames) {
+		panic(fmt.Sprintf("revel: %s.%s takes %d arguments, %d names given",
+			ct.Type.Name(), name, method.Type.NumIn()-1, len(argNames)))
+	}
+
+	r.method = &MethodType{
+		Name:           name,
+		RenderArgNames: make(map[int][]string),
+		lowerName:      strings.ToLower(name),
+	}
+	for i, argName := range argNames {
+		r.method.Args = append(r.method.Args, &MethodArg{
+			Name: argName,
+			Type: method.Type.In(i + 1),
+		})
+	}
+	ct.Methods = append(ct.Methods, r.method)
+	return r
+}
+
+// RenderArgNames names the arguments of the call to Render() on the given line
+// of the last action added, so that they are available by name in the template.
+func (r *ControllerRegistration) RenderArgNames(line int, names ...string) *ControllerRegistration {
+	if r.method == nil {
+		panic("revel: RenderArgNames called before Action")
+	}
+	r.method.RenderArgNames[line] = names
+	return r
+}
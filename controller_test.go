@@ -3,6 +3,7 @@ package revel
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -33,3 +34,41 @@ func TestAcceptsHeader(t *testing.T) {
 		eq(t, "header", resp.Header().Get("Content-Type"), test.accepts)
 	}
 }
+
+// Test that a controller registered with Register serves its actions.
+func TestRegister(t *testing.T) {
+	startFakeBookingApp()
+	Register((*Hotels)(nil)).
+		Action("Index").
+		Action("Show", "id").
+		RenderArgNames(32, "hotel", "title")
+
+	ct := controllers["hotels"]
+	method := ct.Method("show")
+	if method == nil || len(method.Args) != 1 || method.Args[0].Type != reflect.TypeOf(0) {
+		t.Fatalf("Unexpected registration of Show: %#v", method)
+	}
+
+	showRequest, _ := http.NewRequest("GET", "/hotels/3", nil)
+	showRequest.Header.Set("Accept", "application/json")
+	resp := httptest.NewRecorder()
+	handle(resp, showRequest)
+	eq(t, "status code", resp.Code, 200)
+	if !strings.HasPrefix(resp.Body.String(), `{"HotelId":3`) {
+		t.Errorf("Unexpected body: %s", resp.Body.String())
+	}
+
+	for _, register := range []func(){
+		func() { Register((*Hotels)(nil)).Action("Missing") },
+		func() { Register((*Hotels)(nil)).Action("Show") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic registering an invalid action")
+				}
+			}()
+			register()
+		}()
+	}
+}
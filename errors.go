@@ -1,19 +1,36 @@
 package revel
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
 	"runtime/debug"
+	"sort"
 	"strings"
 )
 
 // An error description, used as an argument to the error template.
 type Error struct {
-	SourceType               string   // The type of source that failed to build.
-	Title, Path, Description string   // Description of the error, as presented to the user.
-	Line, Column             int      // Where the error was encountered.
-	SourceLines              []string // The entire source file, split into lines.
-	Stack                    string   // The raw stack trace string from debug.Stack().
-	MetaError                string   // Error that occurred producing the error page.
+	SourceType               string       // The type of source that failed to build.
+	Title, Path, Description string       // Description of the error, as presented to the user.
+	Line, Column             int          // Where the error was encountered.
+	SourceLines              []string     // The entire source file, split into lines.
+	Stack                    string       // The raw stack trace string from debug.Stack().
+	Frames                   []StackFrame // Every frame of the stack, from the panic down.
+	MetaError                string       // Error that occurred producing the error page.
+}
+
+// A frame of the stack of an Error.
+type StackFrame struct {
+	Function string // e.g. "github.com/revel/samples/booking/app/controllers.Hotels.Book(...)"
+	File     string // The absolute path of the source file.
+	Path     string // The path relative to the app or module, for app frames.
+	Line     int
+	IsApp    bool // The frame is in the code of the app or of a module.
 }
 
 // An object to hold the per-source-line details.
@@ -31,6 +48,7 @@ func NewErrorFromPanic(err interface{}) *Error {
 	// Parse the filename and line from the originating line of app code.
 	// /Users/robfig/code/gocode/src/revel/samples/booking/app/controllers/hotels.go:191 (0x44735)
 	stack := string(debug.Stack())
+	frames := parseStackFrames(stack)
 	frame, basePath := findRelevantStackFrame(stack)
 	if frame == -1 {
 		return nil
@@ -55,6 +73,18 @@ func NewErrorFromPanic(err interface{}) *Error {
 		Description: description,
 		SourceLines: MustReadLines(filename),
 		Stack:       stack,
+		Frames:      frames,
+	}
+}
+
+// NewErrorFromStack returns an Error for the panic, with the given stack and
+// without a code listing, for panics outside of the code of the app.
+func NewErrorFromStack(err interface{}, stack string) *Error {
+	return &Error{
+		Title:       "Panic",
+		Description: fmt.Sprint(err),
+		Stack:       stack,
+		Frames:      parseStackFrames(stack),
 	}
 }
 
@@ -102,14 +132,180 @@ func (e *Error) ContextSource() []sourceLine {
 	return lines
 }
 
+// Returns a snippet of the source around the line of the frame, or nil if the
+// source is not available.
+func (f StackFrame) ContextSource() []sourceLine {
+	lines, err := ReadLines(f.File)
+	if err != nil {
+		return nil
+	}
+	return (&Error{SourceLines: lines, Line: f.Line}).ContextSource()
+}
+
+// Parse the frames of a stack trace, as written by debug.Stack, e.g.
+//
+//	github.com/revel/samples/booking/app/controllers.Hotels.Book(...)
+//		/home/revel/booking/app/controllers/hotels.go:191 +0x44735
+//
+// The frames of the panic itself, down to the call to panic(), are skipped.
+func parseStackFrames(stack string) []StackFrame {
+	var (
+		frames   []StackFrame
+		function string
+	)
+	for _, line := range strings.Split(stack, "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			function = line
+			continue
+		}
+		location := strings.TrimSpace(line)
+		if space := strings.Index(location, " "); space != -1 {
+			location = location[:space]
+		}
+		colonIndex := strings.LastIndex(location, ":")
+		if colonIndex == -1 {
+			continue
+		}
+		frame := StackFrame{Function: function, File: location[:colonIndex]}
+		fmt.Sscan(location[colonIndex+1:], &frame.Line)
+		if basePath := appBasePath(frame.File); basePath != "" {
+			frame.IsApp = true
+			frame.Path = strings.TrimPrefix(frame.File[len(basePath):], "/")
+		}
+		frames = append(frames, frame)
+	}
+
+	// The panic was raised by the frame below the last call to panic().
+	for i := len(frames) - 1; i >= 0; i-- {
+		if strings.HasPrefix(frames[i].Function, "panic(") {
+			return frames[i+1:]
+		}
+	}
+	return frames
+}
+
+// Return the base path of the app or module containing the given file, or ""
+// if it is in neither.
+func appBasePath(filename string) string {
+	if BasePath != "" && strings.HasPrefix(filename, BasePath) {
+		return BasePath
+	}
+	for _, module := range Modules {
+		if strings.HasPrefix(filename, module.Path) {
+			return module.Path
+		}
+	}
+	return ""
+}
+
+// ErrorContext describes the request that failed, for the error page in dev
+// mode.
+type ErrorContext struct {
+	RequestId      string
+	Method, URL    string
+	Header         http.Header
+	Params         url.Values
+	Session        Session
+	Flash          map[string]string
+	Action         string
+	RenderArgNames []string // The keys of the RenderArgs of the action.
+	Filters        []string // The names of the filters of the action, in order.
+	Panels         []*DebugPanel
+}
+
+// A DebugPanel is a table about a request, shown on the error page in dev mode,
+// e.g. the queries it executed.
+type DebugPanel struct {
+	Title   string
+	Summary string
+	Columns []string
+	Rows    [][]string
+}
+
+// DebugPanels return the panels of a request, or nil.  Modules may add to them.
+var DebugPanels []func(c *Controller) *DebugPanel
+
+func newErrorContext(c *Controller) *ErrorContext {
+	context := &ErrorContext{
+		Session: c.Session,
+		Flash:   c.Flash.Data,
+		Action:  c.Action,
+	}
+	if c.Request != nil && c.Request.Request != nil {
+		context.RequestId = c.Request.Id
+		context.Method = c.Request.Method
+		context.URL = c.Request.URL.String()
+		context.Header = c.Request.Header
+	}
+	if c.Params != nil {
+		context.Params = c.Params.Values
+	}
+	for name := range c.RenderArgs {
+		context.RenderArgNames = append(context.RenderArgNames, name)
+	}
+	sort.Strings(context.RenderArgNames)
+	if c.Action != "" {
+		for _, filter := range actionFilters(c) {
+			context.Filters = append(context.Filters, filterName(filter))
+		}
+	}
+	for _, panel := range DebugPanels {
+		if p := panel(c); p != nil {
+			context.Panels = append(context.Panels, p)
+		}
+	}
+	return context
+}
+
+// The global filters, which are read through a function set by init, as they
+// depend on PanicFilter, and so on the error pages.
+var globalFilters func() []Filter
+
+func init() {
+	globalFilters = func() []Filter { return Filters }
+}
+
+// Return the filter chain of the action of the controller: the global filters,
+// with the overrides of the action applied after FilterConfiguringFilter.
+func actionFilters(c *Controller) []Filter {
+	filters := globalFilters()
+	for i, filter := range filters {
+		if !FilterEq(filter, FilterConfiguringFilter) {
+			continue
+		}
+		if overrides := getOverrideChain(c.Name, c.Action); overrides != nil {
+			return append(append([]Filter{}, filters[:i+1]...), overrides...)
+		}
+		break
+	}
+	return filters
+}
+
+// Return the name of the function of the filter, e.g. "revel.RouterFilter".
+func filterName(filter Filter) string {
+	name := runtime.FuncForPC(reflect.ValueOf(filter).Pointer()).Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// Return a new random identifier for an error or a request, to find it in the
+// logs.
+func newErrorId() string {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
 // Return the character index of the first relevant stack frame, or -1 if none were found.
 // Additionally it returns the base path of the tree in which the identified code resides.
 func findRelevantStackFrame(stack string) (int, string) {
-	if frame := strings.Index(stack, BasePath); frame != -1 {
+	// The paths are empty until Init, and would match anywhere.
+	if frame := strings.Index(stack, BasePath); BasePath != "" && frame != -1 {
 		return frame, BasePath
 	}
 	for _, module := range Modules {
-		if frame := strings.Index(stack, module.Path); frame != -1 {
+		if frame := strings.Index(stack, module.Path); module.Path != "" && frame != -1 {
 			return frame, module.Path
 		}
 	}
@@ -0,0 +1,107 @@
+package revel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testStack = `goroutine 16 [running]:
+runtime/debug.Stack()
+	/usr/local/go/src/runtime/debug/stack.go:26 +0x5e
+github.com/BSP-Mosaic/teltech-revel.PanicFilter.func1()
+	/go/src/github.com/BSP-Mosaic/teltech-revel/panic.go:14 +0x35
+panic({0xe1a510?, 0xed0320?})
+	/usr/local/go/src/runtime/panic.go:859 +0x125
+example.com/app/app/controllers.Hotels.Show(...)
+	/home/app/app/controllers/hotels.go:191 +0x44735
+github.com/BSP-Mosaic/teltech-revel.ActionInvoker(0x29582f33d130, {0x29582f324958, 0x1, 0x1})
+	/go/src/github.com/BSP-Mosaic/teltech-revel/invoker.go:51 +0x7e
+created by net/http.(*Server).Serve in goroutine 1
+	/usr/local/go/src/net/http/server.go:3581 +0x4fd
+`
+
+func TestParseStackFrames(t *testing.T) {
+	defer func(basePath string) { BasePath = basePath }(BasePath)
+	BasePath = "/home/app"
+
+	expected := []StackFrame{
+		{"example.com/app/app/controllers.Hotels.Show(...)", "/home/app/app/controllers/hotels.go", "app/controllers/hotels.go", 191, true},
+		{"github.com/BSP-Mosaic/teltech-revel.ActionInvoker(0x29582f33d130, {0x29582f324958, 0x1, 0x1})", "/go/src/github.com/BSP-Mosaic/teltech-revel/invoker.go", "", 51, false},
+		{"created by net/http.(*Server).Serve in goroutine 1", "/usr/local/go/src/net/http/server.go", "", 3581, false},
+	}
+	frames := parseStackFrames(testStack)
+	if len(frames) != len(expected) {
+		t.Fatalf("Expected %d frames, got %d: %v", len(expected), len(frames), frames)
+	}
+	for i, frame := range frames {
+		if frame != expected[i] {
+			t.Errorf("Frame %d: expected %v, got %v", i, expected[i], frame)
+		}
+	}
+}
+
+// Test that a stack is not matched by the base path or the module paths before
+// they are set by Init.
+func TestFindRelevantStackFrame(t *testing.T) {
+	defer func(basePath string, modules []Module) { BasePath, Modules = basePath, modules }(BasePath, Modules)
+
+	BasePath, Modules = "", []Module{{Name: "jobs"}}
+	if frame, path := findRelevantStackFrame(testStack); frame != -1 || path != "" {
+		t.Errorf("Expected no relevant frame without paths, got %d in %q", frame, path)
+	}
+	if err := NewErrorFromPanic("no rooms"); err != nil {
+		t.Errorf("Expected no error without a relevant frame, got %v", err)
+	}
+
+	BasePath = "/home/app"
+	if frame, path := findRelevantStackFrame(testStack); frame != strings.Index(testStack, "/home/app/app") || path != BasePath {
+		t.Errorf("Expected the frame of the app, got %d in %q", frame, path)
+	}
+	Modules = []Module{{Name: "booking", Path: "/home/app/app/controllers"}}
+	BasePath = "/srv/other"
+	if frame, path := findRelevantStackFrame(testStack); frame == -1 || path != Modules[0].Path {
+		t.Errorf("Expected the frame of the module, got %d in %q", frame, path)
+	}
+}
+
+// Test that a panic shows the stack and the request in dev mode, and only a
+// reference to the log otherwise.
+func TestPanicErrorPage(t *testing.T) {
+	startFakeBookingApp()
+	defer func(devMode bool) { DevMode = devMode }(DevMode)
+
+	for _, devMode := range []bool{false, true} {
+		DevMode = devMode
+		req, _ := http.NewRequest("GET", "/hotels/3?tab=rooms", nil)
+		resp := httptest.NewRecorder()
+		c := NewController(NewRequest(req), NewResponse(resp), nil)
+		c.SetAction("Hotels", "Show")
+		ParamsFilter(c, []Filter{PanicFilter, func(c *Controller, _ []Filter) {
+			c.RenderArgs["hotel"] = "Ritz"
+			panic("no rooms")
+		}})
+		c.Result.Apply(c.Request, c.Response)
+
+		body := resp.Body.String()
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", resp.Code)
+		}
+		errorId, _ := c.RenderArgs["ErrorId"].(string)
+		if !regexp.MustCompile("^[0-9a-f]{12}$").MatchString(errorId) {
+			t.Errorf("Expected an error id, got %q", errorId)
+		}
+		if !strings.Contains(body, errorId) {
+			t.Errorf("Expected the error id %s in the page:\n%s", errorId, body)
+		}
+
+		devContent := []string{"no rooms", "errors_test.go", "tab", "rooms", "hotel", "revel.RouterFilter"}
+		for _, content := range devContent {
+			if strings.Contains(body, content) != devMode {
+				t.Errorf("Dev mode %v: expected %q in the page %v:\n%s", devMode, content, devMode, body)
+			}
+		}
+	}
+}
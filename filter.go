@@ -6,6 +6,7 @@ type Filter func(c *Controller, filterChain []Filter)
 // It may be set by the application on initialization.
 var Filters = []Filter{
 	PanicFilter,             // Recover from panics and display an error page instead.
+	I18nPathFilter,          // Remove a locale prefix from the path (if enabled)
 	RouterFilter,            // Use the routing table to select the right Action
 	FilterConfiguringFilter, // A hook for adding or removing per-Action filters.
 	ParamsFilter,            // Parse parameters into Controller.Params.
@@ -0,0 +1,77 @@
+package revel
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+)
+
+// In module mode, packages are located through the module graph of the main
+// module (the one containing the working directory) by the go tool, rather
+// than in GOPATH.
+
+var (
+	goModOnce sync.Once
+	goModFile string // The go.mod of the main module, or "" in GOPATH mode.
+)
+
+// goModulesEnabled returns whether the go tool runs in module mode from the
+// working directory.
+func goModulesEnabled() bool {
+	goModOnce.Do(func() {
+		gomod, err := goCommand("env", "GOMOD")
+		if err != nil {
+			glog.V(1).Info("Using GOPATH, the go tool failed: ", err)
+			return
+		}
+		// GOMOD is empty in GOPATH mode, and /dev/null in module mode outside
+		// of a module.
+		if gomod != os.DevNull {
+			goModFile = gomod
+		}
+	})
+	return goModFile != ""
+}
+
+// findModulePaths uses "go list" to find the directories of Revel and the app
+// in the module graph.
+func findModulePaths(importPath string) (revelPath, basePath string) {
+	var err error
+	if basePath, err = goListDir(importPath); err != nil {
+		glog.Fatalln("Failed to find", importPath, "in the modules of", goModFile, "with error:", err)
+	}
+	if revelPath, err = goListDir(REVEL_IMPORT_PATH); err != nil {
+		glog.Fatalln("Failed to find Revel in the modules of", goModFile, "with error:", err)
+	}
+	return revelPath, basePath
+}
+
+// goListDir returns the directory of the package with the given import path,
+// whether or not it contains Go files, as found by "go list".
+func goListDir(importPath string) (string, error) {
+	dir, err := goCommand("list", "-e", "-find", "-f", "{{.Dir}}", importPath)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return "", fmt.Errorf("package %s is not provided by any required module", importPath)
+	}
+	return dir, nil
+}
+
+// Run the go tool with the given arguments, and return its trimmed output.
+func goCommand(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go %s: %s: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -51,6 +51,8 @@ func NewAppCmd(binPath string, port int, extraFlags []string) AppCmd {
 	}
 	flags = append(flags, extraFlags...)
 	cmd := exec.Command(binPath, flags...)
+	// The app finds itself in its own module.
+	cmd.Dir = goCommandDir()
 	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
 	return AppCmd{cmd}
 }
@@ -3,6 +3,7 @@ package harness
 import (
 	"fmt"
 	"go/build"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
@@ -17,7 +18,13 @@ import (
 	"github.com/BSP-Mosaic/teltech-revel"
 )
 
-var importErrorPattern = regexp.MustCompile("cannot find package \"([^\"]+)\"")
+var (
+	importErrorPattern = regexp.MustCompile("cannot find package \"([^\"]+)\"")
+
+	// Reported in module mode, e.g. "no required module provides package
+	// example.com/pkg; to add it:".
+	moduleImportErrorPattern = regexp.MustCompile("no required module provides package ([^\\s;:]+)")
+)
 
 // Build the app:
 // 1. Generate the the main.go file.
@@ -25,11 +32,27 @@ var importErrorPattern = regexp.MustCompile("cannot find package \"([^\"]+)\"")
 // Requires that revel.Init has been called previously.
 // Returns the path to the built binary, and an error if there was a problem building it.
 func Build() (app *App, compileError *revel.Error) {
-	start := time.Now()
+	return BuildFor(BuildTarget{})
+}
+
+// BuildTarget describes the platform to build the app for, and how.
+type BuildTarget struct {
+	GOOS, GOARCH string   // The target platform, if not the host's.
+	Flags        []string // Extra flags to pass to "go build", e.g. "-trimpath".
+}
 
-	// First, clear the generated files (to avoid them messing with ProcessSource).
-	cleanSource("tmp", "routes")
+// Return whether the target is not the host platform.
+func (t BuildTarget) cross() bool {
+	return (t.GOOS != "" && t.GOOS != runtime.GOOS) || (t.GOARCH != "" && t.GOARCH != runtime.GOARCH)
+}
+
+// BuildFor builds the app like Build, for the given target.  Binaries built for
+// another platform are named after it, e.g. "chat-linux-arm64".
+func BuildFor(target BuildTarget) (app *App, compileError *revel.Error) {
+	start := time.Now()
 
+	// The generated files are kept (and skipped by ProcessSource), so that
+	// they are only rewritten when the controllers change.
 	sourceInfo, compileError := ProcessSource(revel.CodePaths)
 	if compileError != nil {
 		return nil, compileError
@@ -47,7 +70,9 @@ func Build() (app *App, compileError *revel.Error) {
 		"ImportPaths":    calcImportAliases(sourceInfo),
 		"TestSuites":     sourceInfo.TestSuites(),
 	}
-	genSource("tmp", "main.go", MAIN, templateArgs)
+	if genSource("tmp", "main.go", MAIN, templateArgs) {
+		glog.V(1).Info("Regenerated main.go, as the controllers changed")
+	}
 	genSource("routes", "routes.go", ROUTES, templateArgs)
 
 	// Read build config.
@@ -60,21 +85,54 @@ func Build() (app *App, compileError *revel.Error) {
 		glog.Fatalf("Go executable not found in PATH.")
 	}
 
-	pkg, err := build.Default.Import(revel.ImportPath, "", build.FindOnly)
-	if err != nil {
-		glog.Fatalln("Failure importing", revel.ImportPath)
+	var binName string
+	if revel.GoModules {
+		binName = filepath.Join(binDir(), filepath.Base(revel.BasePath))
+	} else {
+		pkg, err := build.Default.Import(revel.ImportPath, "", build.FindOnly)
+		if err != nil {
+			glog.Fatalln("Failure importing", revel.ImportPath)
+		}
+		binName = filepath.Join(pkg.BinDir, filepath.Base(revel.BasePath))
 	}
-	binName := filepath.Join(pkg.BinDir, filepath.Base(revel.BasePath))
-	if runtime.GOOS == "windows" {
+	goos := runtime.GOOS
+	if target.cross() {
+		if target.GOOS != "" {
+			goos = target.GOOS
+		}
+		goarch := runtime.GOARCH
+		if target.GOARCH != "" {
+			goarch = target.GOARCH
+		}
+		binName += "-" + goos + "-" + goarch
+	}
+	if goos == "windows" {
 		binName += ".exe"
 	}
 
 	gotten := make(map[string]struct{})
 	for {
-		buildCmd := exec.Command(goPath, "build",
-			"-i",
+		buildArgs := []string{"build"}
+		if !revel.GoModules && !target.cross() {
+			// Install the dependencies, which module mode does not support (nor
+			// does the standard library of another platform).
+			buildArgs = append(buildArgs, "-i")
+		}
+		buildArgs = append(buildArgs, target.Flags...)
+		buildArgs = append(buildArgs,
 			"-tags", buildTags,
 			"-o", binName, path.Join(revel.ImportPath, "app", "tmp"))
+		buildCmd := exec.Command(goPath, buildArgs...)
+		buildCmd.Dir = goCommandDir()
+		if target.GOOS != "" || target.GOARCH != "" {
+			buildCmd.Env = os.Environ()
+			if target.GOOS != "" {
+				buildCmd.Env = append(buildCmd.Env, "GOOS="+target.GOOS)
+			}
+			if target.GOARCH != "" {
+				buildCmd.Env = append(buildCmd.Env, "GOARCH="+target.GOARCH)
+			}
+		}
 		glog.V(1).Infoln("Exec:", buildCmd.Args)
 		output, err := buildCmd.CombinedOutput()
 
@@ -87,6 +145,9 @@ func Build() (app *App, compileError *revel.Error) {
 
 		// See if it was an import error that we can go get.
 		matches := importErrorPattern.FindStringSubmatch(string(output))
+		if matches == nil {
+			matches = moduleImportErrorPattern.FindStringSubmatch(string(output))
+		}
 		if matches == nil {
 			return nil, newCompileError(output)
 		}
@@ -100,6 +161,7 @@ func Build() (app *App, compileError *revel.Error) {
 
 		// Execute "go get <pkg>"
 		getCmd := exec.Command(goPath, "get", pkgName)
+		getCmd.Dir = goCommandDir()
 		glog.V(1).Infoln("Exec:", getCmd.Args)
 		getOutput, err := getCmd.CombinedOutput()
 		if err != nil {
@@ -113,6 +175,15 @@ func Build() (app *App, compileError *revel.Error) {
 	return nil, nil
 }
 
+// goCommandDir returns the directory to run the go tool in: the app's, so that
+// its go.mod is used in module mode, or the working directory otherwise.
+func goCommandDir() string {
+	if revel.GoModules {
+		return revel.BasePath
+	}
+	return ""
+}
+
 func cleanSource(dirs ...string) {
 	for _, dir := range dirs {
 		tmpPath := filepath.Join(revel.AppPath, dir)
@@ -124,12 +195,18 @@ func cleanSource(dirs ...string) {
 }
 
 // genSource renders the given template to produce source code, which it writes
-// to the given directory and file.
-func genSource(dir, filename, templateSource string, args map[string]interface{}) {
+// to the given directory and file.  The file is left alone if it already has
+// that source, for the go tool to reuse its build; genSource returns whether it
+// was written.
+func genSource(dir, filename, templateSource string, args map[string]interface{}) bool {
 	sourceCode := revel.ExecuteTemplate(
 		template.Must(template.New("").Parse(templateSource)),
 		args)
 
+	if current, err := ioutil.ReadFile(filepath.Join(revel.AppPath, dir, filename)); err == nil && string(current) == sourceCode {
+		return false
+	}
+
 	// Create a fresh dir.
 	tmpPath := filepath.Join(revel.AppPath, dir)
 	err := os.RemoveAll(tmpPath)
@@ -151,6 +228,7 @@ func genSource(dir, filename, templateSource string, args map[string]interface{}
 	if err != nil {
 		glog.Fatalln("Failed to write to file:", err)
 	}
+	return true
 }
 
 // Looks through all the method args and returns a set of unique import paths
@@ -0,0 +1,117 @@
+package harness
+
+import (
+	"go/format"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// Generate writes a main package to main.go in the app's base directory, which
+// registers the controllers, test suites and validation keys of the app with
+// revel.Register, and runs it.  It also generates the reverse routes.
+//
+// Committed, it lets the app build with "go build <import path>", without the
+// harness.  Requires that revel.Init has been called previously.
+func Generate(runMode string) (mainPath string, compileError *revel.Error) {
+	cleanSource("tmp", "routes")
+
+	sourceInfo, compileError := ProcessSource(revel.CodePaths)
+	if compileError != nil {
+		return "", compileError
+	}
+	if dbImportPath, found := revel.Config.String("db.import"); found {
+		sourceInfo.InitImportPaths = append(sourceInfo.InitImportPaths, dbImportPath)
+	}
+
+	templateArgs := map[string]interface{}{
+		"Controllers":    sourceInfo.ControllerSpecs(),
+		"ValidationKeys": sourceInfo.ValidationKeys,
+		"ImportPaths":    calcImportAliases(sourceInfo),
+		"TestSuites":     sourceInfo.TestSuites(),
+	}
+	genSource("routes", "routes.go", ROUTES, templateArgs)
+
+	// The argument types are found by reflection, so only the packages of
+	// the controllers and test suites are imported.
+	aliases := make(map[string]string)
+	for _, specs := range [][]*TypeInfo{sourceInfo.ControllerSpecs(), sourceInfo.TestSuites()} {
+		for _, spec := range specs {
+			addAlias(aliases, spec.ImportPath, spec.PackageName)
+		}
+	}
+	for _, importPath := range sourceInfo.InitImportPaths {
+		if _, ok := aliases[importPath]; !ok {
+			aliases[importPath] = "_"
+		}
+	}
+	templateArgs["ImportPaths"] = aliases
+	templateArgs["AppImportPath"] = revel.ImportPath
+	templateArgs["RunMode"] = runMode
+
+	sourceCode := revel.ExecuteTemplate(template.Must(template.New("").Parse(REGISTRATIONS)), templateArgs)
+	formatted, err := format.Source([]byte(sourceCode))
+	if err != nil {
+		return "", &revel.Error{
+			Title:       "Generation error",
+			Description: "Failed to format the generated main.go: " + err.Error(),
+		}
+	}
+
+	mainPath = filepath.Join(revel.BasePath, "main.go")
+	if err = ioutil.WriteFile(mainPath, formatted, 0666); err != nil {
+		return "", &revel.Error{
+			Title:       "Generation error",
+			Description: "Failed to write " + mainPath + ": " + err.Error(),
+		}
+	}
+	return mainPath, nil
+}
+
+const REGISTRATIONS = `// GENERATED CODE - DO NOT EDIT
+// Run "go generate" to update it after changing the controllers.
+
+//go:generate revel codegen {{.AppImportPath}} {{.RunMode}}
+
+package main
+
+import (
+	"flag"
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/BSP-Mosaic/teltech-revel"{{range $k, $v := $.ImportPaths}}
+	{{$v}} "{{$k}}"{{end}}
+)
+
+var (
+	runMode    *string = flag.String("runMode", "{{.RunMode}}", "Run mode.")
+	port       *int    = flag.Int("port", 0, "By default, read from app.conf")
+	importPath *string = flag.String("importPath", "{{.AppImportPath}}", "Go Import Path for the app.")
+	srcPath    *string = flag.String("srcPath", "", "Path to the source root.")
+)
+
+func init() { {{range .Controllers}}
+	revel.Register((*{{index $.ImportPaths .ImportPath}}.{{.StructName}})(nil)){{range .MethodSpecs}}.
+		Action("{{.Name}}"{{range .Args}}, "{{.Name}}"{{end}}){{range .RenderCalls}}.
+		RenderArgNames({{.Line}}{{range .Names}}, "{{.}}"{{end}}){{end}}{{end}}
+	{{end}}
+	revel.DefaultValidationKeys = map[string]map[int]string{ {{range $path, $lines := .ValidationKeys}}
+		"{{$path}}": { {{range $line, $key := $lines}}
+			{{$line}}: "{{$key}}",{{end}}
+		},{{end}}
+	}
+	revel.TestSuites = []interface{}{ {{range .TestSuites}}
+		(*{{index $.ImportPaths .ImportPath}}.{{.StructName}})(nil),{{end}}
+	}
+}
+
+func main() {
+	flag.Parse()
+	revel.Init(*runMode, *importPath, *srcPath)
+	revel.ConfigureLogging()
+	revel.LoadModules()
+	glog.Info("Running revel server")
+	revel.Run(*port)
+}
+`
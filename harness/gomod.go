@@ -0,0 +1,97 @@
+package harness
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var modulePattern = regexp.MustCompile(`(?m)^\s*module\s+"?([^"\s]+)"?`)
+
+// moduleImportPath returns the import path of the given directory within the
+// Go module containing it, or "" if it is not within a module.
+func moduleImportPath(root string) string {
+	// Look for the go.mod declaring the module, from the directory up.
+	for dir := root; ; dir = filepath.Dir(dir) {
+		if content, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			if modulePath := parseModulePath(content); modulePath != "" {
+				return joinImportPath(modulePath, dir, root)
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	// Modules without a go.mod are still found in the module cache, in
+	// directories named by their escaped path and version.
+	if modCache := goEnv("GOMODCACHE"); modCache != "" && strings.HasPrefix(root, modCache+string(filepath.Separator)) {
+		parts := strings.Split(filepath.ToSlash(root[len(modCache)+1:]), "/")
+		for i, part := range parts {
+			if at := strings.Index(part, "@"); at >= 0 {
+				parts[i] = part[:at]
+				return unescapeModulePath(strings.Join(parts, "/"))
+			}
+		}
+	}
+	return ""
+}
+
+// parseModulePath returns the module path declared by the given go.mod.
+func parseModulePath(goMod []byte) string {
+	if matches := modulePattern.FindSubmatch(goMod); matches != nil {
+		return string(matches[1])
+	}
+	return ""
+}
+
+// Return the import path of dir, within the module at moduleDir.
+func joinImportPath(modulePath, moduleDir, dir string) string {
+	rel, err := filepath.Rel(moduleDir, dir)
+	if err != nil || rel == "." {
+		return modulePath
+	}
+	return modulePath + "/" + filepath.ToSlash(rel)
+}
+
+// unescapeModulePath reverses the case-encoding of module cache paths, where
+// upper-case letters are written as "!" followed by the lower-case letter.
+func unescapeModulePath(escaped string) string {
+	var unescaped []rune
+	bang := false
+	for _, r := range escaped {
+		if bang {
+			r = unicode.ToUpper(r)
+			bang = false
+		} else if r == '!' {
+			bang = true
+			continue
+		}
+		unescaped = append(unescaped, r)
+	}
+	return string(unescaped)
+}
+
+// binDir returns the directory in which "go install" would put binaries.
+func binDir() string {
+	if gobin := goEnv("GOBIN"); gobin != "" {
+		return gobin
+	}
+	if gopaths := filepath.SplitList(goEnv("GOPATH")); len(gopaths) > 0 {
+		return filepath.Join(gopaths[0], "bin")
+	}
+	return os.TempDir()
+}
+
+// Return the value of the given Go environment variable, or "" if unknown.
+func goEnv(name string) string {
+	output, err := exec.Command("go", "env", name).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
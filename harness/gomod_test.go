@@ -0,0 +1,49 @@
+package harness
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseModulePath(t *testing.T) {
+	for goMod, expected := range map[string]string{
+		"module example.com/app\n\ngo 1.12\n":      "example.com/app",
+		"// An app.\nmodule \"example.com/app\"\n": "example.com/app",
+		"go 1.12\n": "",
+	} {
+		if actual := parseModulePath([]byte(goMod)); actual != expected {
+			t.Errorf("Expected module %q in %q, got %q", expected, goMod, actual)
+		}
+	}
+}
+
+func TestModuleImportPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "revel-gomod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	codePath := filepath.Join(dir, "app", "controllers")
+	if err = os.MkdirAll(codePath, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := moduleImportPath(codePath); actual != "example.com/app/app/controllers" {
+		t.Errorf("Expected example.com/app/app/controllers, got %s", actual)
+	}
+	if actual := moduleImportPath(dir); actual != "example.com/app" {
+		t.Errorf("Expected example.com/app, got %s", actual)
+	}
+}
+
+func TestUnescapeModulePath(t *testing.T) {
+	if actual := unescapeModulePath("github.com/!b!s!p-!mosaic/teltech-revel"); actual != "github.com/BSP-Mosaic/teltech-revel" {
+		t.Errorf("Unexpected unescaped path %s", actual)
+	}
+}
@@ -5,6 +5,7 @@
 //    controller classes and starts the user's server.
 // 2. Build and run the user program.  Show compile errors.
 // 3. Monitor the user source and re-build / restart the program when necessary.
+// 4. Reload the pages open in the browser when the program or its templates change.
 //
 // Source files are generated in the app/tmp directory.
 
@@ -16,12 +17,15 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/BSP-Mosaic/teltech-glog"
@@ -41,7 +45,10 @@ type Harness struct {
 	app        *App
 	serverHost string
 	port       int
+	fixedPort  bool // The app always runs on port (harness.port).
 	proxy      *httputil.ReverseProxy
+	reloader   *liveReloader // nil unless harness.livereload.
+	mutex      sync.RWMutex  // Guards serverHost, as the app is swapped.
 }
 
 func renderError(w http.ResponseWriter, r *http.Request, err error) {
@@ -50,9 +57,37 @@ func renderError(w http.ResponseWriter, r *http.Request, err error) {
 	c.RenderError(err).Apply(req, resp)
 }
 
+// Render the error page, with the live reload script if enabled, so that the
+// browser reloads once the error is fixed.
+func (hp *Harness) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	if hp.reloader == nil {
+		renderError(w, r, err)
+		return
+	}
+
+	recorder := httptest.NewRecorder()
+	renderError(recorder, r, err)
+	page := recorder.Result()
+	if err := injectLiveReload(page); err != nil {
+		glog.Errorln("Failed to add the live reload script to the error page:", err)
+	}
+	for name, values := range page.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(page.StatusCode)
+	io.Copy(w, page.Body)
+}
+
 // ServeHTTP handles all requests.
 // It checks for changes to app, rebuilds if necessary, and forwards the request.
 func (hp *Harness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The live reload events are served by the harness itself, whether or not
+	// the app builds.
+	if hp.reloader != nil && r.URL.Path == liveReloadPath {
+		hp.reloader.ServeHTTP(w, r)
+		return
+	}
+
 	// Don't rebuild the app for favicon requests.
 	if lastRequestHadError > 0 && r.URL.Path == "/favicon.ico" {
 		return
@@ -63,7 +98,7 @@ func (hp *Harness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	err := watcher.Notify()
 	if err != nil {
 		atomic.CompareAndSwapInt32(&lastRequestHadError, 0, 1)
-		renderError(w, r, err)
+		hp.renderError(w, r, err)
 		return
 	}
 	atomic.CompareAndSwapInt32(&lastRequestHadError, 1, 0)
@@ -71,7 +106,7 @@ func (hp *Harness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Reverse proxy the request.
 	// (Need special code for websockets, courtesy of bradfitz)
 	if r.Header.Get("Upgrade") == "websocket" {
-		proxyWebsocket(w, r, hp.serverHost)
+		proxyWebsocket(w, r, hp.host())
 	} else {
 		hp.proxy.ServeHTTP(w, r)
 	}
@@ -97,6 +132,7 @@ func NewHarness() *Harness {
 		addr = "localhost"
 	}
 
+	fixedPort := port != 0
 	if port == 0 {
 		port = getFreePort()
 	}
@@ -105,8 +141,21 @@ func NewHarness() *Harness {
 
 	harness := &Harness{
 		port:       port,
-		serverHost: serverUrl.String()[len(scheme+"://"):],
-		proxy:      httputil.NewSingleHostReverseProxy(serverUrl),
+		fixedPort:  fixedPort,
+		serverHost: serverUrl.Host,
+	}
+
+	// Forward to the current app, which moves to another port when rebuilt.
+	harness.proxy = &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = scheme
+			req.URL.Host = harness.host()
+		},
+	}
+
+	if revel.Config.BoolDefault("harness.livereload", true) {
+		harness.reloader = newLiveReloader()
+		harness.proxy.ModifyResponse = injectLiveReload
 	}
 
 	if revel.HttpSsl {
@@ -117,29 +166,67 @@ func NewHarness() *Harness {
 	return harness
 }
 
-// Rebuild the Revel application and run it on the given port.
+// Rebuild the Revel application and run it.
+//
+// The new app starts on another port while the old one keeps serving, and
+// replaces it once it is listening, unless harness.port fixes the app's port.
+// If it fails to build or start, the old app keeps running.
 func (h *Harness) Refresh() (err *revel.Error) {
-	if h.app != nil {
-		h.app.Kill()
+	// Reload the pages either way: to the new app, or to the error.
+	if h.reloader != nil {
+		defer h.reloader.Reload()
 	}
 
 	glog.V(1).Info("Rebuild")
-	h.app, err = Build()
+	app, err := Build()
 	if err != nil {
 		return
 	}
 
-	h.app.Port = h.port
-	if err2 := h.app.Cmd().Start(); err2 != nil {
+	app.Port = h.port
+	if h.app != nil {
+		if h.fixedPort {
+			h.app.Kill()
+			h.app = nil
+		} else {
+			app.Port = getFreePort()
+		}
+	}
+
+	if err2 := app.Cmd().Start(); err2 != nil {
 		return &revel.Error{
 			Title:       "App failed to start up",
 			Description: err2.Error(),
 		}
 	}
 
+	old := h.app
+	h.app = app
+	h.setHost(app.Port)
+	if old != nil {
+		old.Kill()
+	}
 	return
 }
 
+// Return the host:port of the running app.
+func (h *Harness) host() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.serverHost
+}
+
+// Forward the requests to the app on the given port.
+func (h *Harness) setHost(port int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	host, _, err := net.SplitHostPort(h.serverHost)
+	if err != nil {
+		glog.Fatalln("Invalid app address", h.serverHost, ":", err)
+	}
+	h.serverHost = net.JoinHostPort(host, strconv.Itoa(port))
+}
+
 func (h *Harness) WatchDir(basename string) bool {
 	return !revel.ContainsString(doNotWatch, basename)
 }
@@ -153,6 +240,12 @@ func (h *Harness) WatchFile(filename string) bool {
 func (h *Harness) Run() {
 	revel.ConfigureLogging()
 	watcher = revel.NewWatcher()
+	if h.reloader != nil {
+		// Rebuild as soon as the code changes, to reload the pages then, and
+		// reload them on changes to what the app reloads itself.
+		watcher.Eager = true
+		watcher.Listen(h.reloader, h.reloadPaths()...)
+	}
 	watcher.Listen(h, revel.CodePaths...)
 
 	go func() {
@@ -181,6 +274,18 @@ func (h *Harness) Run() {
 	os.Exit(1)
 }
 
+// Return the paths of the files that the app reloads without a rebuild: its
+// templates, configuration (e.g. the routes) and public assets.
+func (h *Harness) reloadPaths() []string {
+	paths := append([]string{}, revel.TemplatePaths...)
+	for _, dir := range []string{"conf", "public"} {
+		if path := filepath.Join(revel.BasePath, dir); revel.DirExists(path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
 // Find an unused port
 func getFreePort() (port int) {
 	conn, err := net.Listen("tcp", ":0")
@@ -0,0 +1,123 @@
+package harness
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// The path of the harness endpoint streaming reload events to the pages.
+const liveReloadPath = "/@livereload"
+
+// The script injected into the pages of the app, which reloads them on the
+// events of the live reload endpoint.
+var liveReloadScript = []byte(`<script>(function() {
+	if (!window.EventSource) return;
+	new EventSource("` + liveReloadPath + `").onmessage = function() { location.reload(); };
+})();</script>`)
+
+// Wait for the app to see the change before reloading, and coalesce the events
+// of a single save.
+const liveReloadDelay = 200 * time.Millisecond
+
+// liveReloader tells the open pages of the app to reload, as Server-Sent Events.
+type liveReloader struct {
+	mutex   sync.Mutex
+	clients map[chan struct{}]struct{}
+	timer   *time.Timer
+}
+
+func newLiveReloader() *liveReloader {
+	return &liveReloader{clients: make(map[chan struct{}]struct{})}
+}
+
+// ServeHTTP streams a reload event to the page when the app changes, until the
+// page goes away.
+func (lr *liveReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	reload := make(chan struct{}, 1)
+	lr.mutex.Lock()
+	lr.clients[reload] = struct{}{}
+	lr.mutex.Unlock()
+	defer func() {
+		lr.mutex.Lock()
+		delete(lr.clients, reload)
+		lr.mutex.Unlock()
+	}()
+
+	select {
+	case <-reload:
+		fmt.Fprint(w, "data: reload\n\n")
+		flusher.Flush()
+	case <-r.Context().Done():
+	}
+}
+
+// Reload tells the open pages to reload, shortly.
+func (lr *liveReloader) Reload() {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	if lr.timer != nil {
+		lr.timer.Stop()
+	}
+	lr.timer = time.AfterFunc(liveReloadDelay, func() {
+		lr.mutex.Lock()
+		defer lr.mutex.Unlock()
+		glog.V(1).Infof("Reloading %d page(s)", len(lr.clients))
+		for client := range lr.clients {
+			select {
+			case client <- struct{}{}:
+			default:
+			}
+		}
+	})
+}
+
+// Refresh reloads the pages on changes to the files the app reloads itself,
+// such as the templates.
+func (lr *liveReloader) Refresh() *revel.Error {
+	lr.Reload()
+	return nil
+}
+
+// injectLiveReload adds the live reload script to the HTML pages served by the
+// app, before their closing body tag.
+func injectLiveReload(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") ||
+		resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+		body = append(body[:i], append(append([]byte{}, liveReloadScript...), body[i:]...)...)
+	} else {
+		body = append(body, liveReloadScript...)
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
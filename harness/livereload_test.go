@@ -0,0 +1,76 @@
+package harness
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+func TestInjectLiveReload(t *testing.T) {
+	tests := []struct {
+		contentType, encoding, body, expected string
+	}{
+		{"text/html; charset=utf-8", "", "<html><body>Hi</body></html>",
+			"<html><body>Hi" + string(liveReloadScript) + "</body></html>"},
+		{"text/html", "", "Hi", "Hi" + string(liveReloadScript)},
+		{"text/html", "gzip", "<body></body>", "<body></body>"},
+		{"application/json", "", `{"body": "</body>"}`, `{"body": "</body>"}`},
+	}
+
+	for _, test := range tests {
+		resp := &http.Response{
+			Header: http.Header{"Content-Type": {test.contentType}},
+			Body:   ioutil.NopCloser(strings.NewReader(test.body)),
+		}
+		if test.encoding != "" {
+			resp.Header.Set("Content-Encoding", test.encoding)
+		}
+		if err := injectLiveReload(resp); err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		if string(body) != test.expected {
+			t.Errorf("%s %q: expected %q, got %q", test.contentType, test.body, test.expected, body)
+		}
+		if test.expected != test.body && resp.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+			t.Errorf("%q: wrong Content-Length %s", test.body, resp.Header.Get("Content-Length"))
+		}
+	}
+}
+
+func TestErrorPageLiveReload(t *testing.T) {
+	oldConfig, oldConfPaths, oldLoader, oldDevMode := revel.Config, revel.ConfPaths, revel.MainTemplateLoader, revel.DevMode
+	defer func() {
+		revel.Config, revel.ConfPaths, revel.MainTemplateLoader, revel.DevMode = oldConfig, oldConfPaths, oldLoader, oldDevMode
+	}()
+	revel.Config, revel.ConfPaths, revel.DevMode = revel.NewEmptyConfig(), []string{filepath.Join("..", "conf")}, true
+	revel.LoadMimeConfig()
+	revel.MainTemplateLoader = revel.NewTemplateLoader([]string{filepath.Join("..", "templates")})
+	if err := revel.MainTemplateLoader.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	compileError := &revel.Error{Title: "Compilation Error", Description: "undefined: hotel"}
+	for _, reloader := range []*liveReloader{nil, newLiveReloader()} {
+		hp := &Harness{reloader: reloader}
+		w := httptest.NewRecorder()
+		hp.renderError(w, httptest.NewRequest("GET", "/hotels", nil), compileError)
+
+		body := w.Body.String()
+		if w.Code != http.StatusInternalServerError || !strings.Contains(body, "undefined: hotel") {
+			t.Errorf("Expected the error page, got %d:\n%s", w.Code, body)
+		}
+		if injected := strings.Contains(body, string(liveReloadScript)); injected != (reloader != nil) {
+			t.Errorf("Expected the live reload script %v, got:\n%s", reloader != nil, body)
+		}
+		if w.Header().Get("Content-Length") != "" && w.Header().Get("Content-Length") != strconv.Itoa(len(body)) {
+			t.Errorf("Wrong Content-Length %s", w.Header().Get("Content-Length"))
+		}
+	}
+}
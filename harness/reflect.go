@@ -12,6 +12,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/BSP-Mosaic/teltech-glog"
@@ -107,6 +108,11 @@ func ProcessSource(roots []string) (*SourceInfo, *revel.Error) {
 				return nil
 			}
 
+			// Skip the generated reverse routes.
+			if path == filepath.Join(revel.AppPath, "routes") {
+				return filepath.SkipDir
+			}
+
 			// Get the import path of the package.
 			pkgImportPath := rootImportPath
 			if root != path {
@@ -193,8 +199,15 @@ func processPackage(fset *token.FileSet, pkgImportPath, pkgPath string, pkg *ast
 			strings.Contains(pkgImportPath, "/tests/")
 	)
 
-	// For each source file in the package...
-	for _, file := range pkg.Files {
+	// For each source file in the package, in order (for the generated code to
+	// be the same from one build to the next)...
+	fileNames := make([]string, 0, len(pkg.Files))
+	for fileName := range pkg.Files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	for _, fileName := range fileNames {
+		file := pkg.Files[fileName]
 
 		// Imports maps the package key to the full import path.
 		// e.g. import "sample/app/models" => "models": "sample/app/models"
@@ -758,6 +771,14 @@ func IsBuiltinType(name string) bool {
 }
 
 func importPathFromPath(root string) string {
+	if revel.GoModules {
+		if importPath := moduleImportPath(root); importPath != "" {
+			return importPath
+		}
+		glog.Error("Unexpected! Code path is not in a Go module: ", root)
+		return ""
+	}
+
 	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
 		srcPath := filepath.Join(gopath, "src")
 		if strings.HasPrefix(root, srcPath) {
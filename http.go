@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,6 +14,7 @@ import (
 
 type Request struct {
 	*http.Request
+	Id              string // From the X-Request-Id header, or random.
 	ContentType     string
 	Format          string // "html", "xml", "json", or "txt"
 	AcceptLanguages AcceptLanguages
@@ -30,9 +32,18 @@ func NewResponse(w http.ResponseWriter) *Response {
 	return &Response{Out: w}
 }
 
+// The request ids accepted from the X-Request-Id header.  Others, which could
+// forge log lines or bloat the logs, are replaced by a random id.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
 func NewRequest(r *http.Request) *Request {
+	id := r.Header.Get("X-Request-Id")
+	if !requestIdPattern.MatchString(id) {
+		id = newErrorId()
+	}
 	return &Request{
 		Request:         r,
+		Id:              id,
 		ContentType:     ResolveContentType(r),
 		Format:          ResolveFormat(r),
 		AcceptLanguages: ResolveAcceptLanguage(r),
@@ -143,6 +154,7 @@ func ResolveAcceptLanguage(req *http.Request) AcceptLanguages {
 	acceptLanguages := make(AcceptLanguages, len(acceptLanguageHeaderValues))
 
 	for i, languageRange := range acceptLanguageHeaderValues {
+		languageRange = strings.Replace(strings.TrimSpace(languageRange), " ", "", -1)
 		if qualifiedRange := strings.Split(languageRange, ";q="); len(qualifiedRange) == 2 {
 			quality, error := strconv.ParseFloat(qualifiedRange[1], 32)
 			if error != nil {
@@ -0,0 +1,31 @@
+package revel
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewRequestId(t *testing.T) {
+	for header, kept := range map[string]bool{
+		"req-1":                   true,
+		"5f2b.91c0_A-z":           true,
+		strings.Repeat("a", 64):   true,
+		"":                        false,
+		strings.Repeat("a", 65):   false,
+		"req 1":                   false,
+		"req-1\nE0101 forged log": false,
+		"<script>":                false,
+		"req/1":                   false,
+	} {
+		r, _ := http.NewRequest("GET", "/hotels", nil)
+		r.Header.Set("X-Request-Id", header)
+		id := NewRequest(r).Id
+		if kept && id != header {
+			t.Errorf("Expected the request id %q to be kept, got %q", header, id)
+		}
+		if !kept && (id == header || !requestIdPattern.MatchString(id)) {
+			t.Errorf("Expected a random request id instead of %q, got %q", header, id)
+		}
+	}
+}
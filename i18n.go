@@ -7,7 +7,6 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/robfig/config"
 	"github.com/BSP-Mosaic/teltech-glog"
 )
 
@@ -22,8 +21,14 @@ const (
 )
 
 var (
-	// All currently loaded message configs.
-	messages map[string]*config.Config
+	// All currently loaded message sources, keyed by language.
+	messages map[string]MessageSource
+
+	// Message files read by one of the MessageLoaders: <name>.<language>[-<region>]<extension>
+	loaderFilePattern = regexp.MustCompile(`^\w+\.([a-zA-Z]{2})(?:[-_]([a-zA-Z]{2}))?(\.\w+)$`)
+
+	// Named message arguments, e.g. "{name}"
+	namedArgPattern = regexp.MustCompile(`\{\w+\}`)
 )
 
 // Return all currently loaded message languages.
@@ -39,55 +44,145 @@ func MessageLanguages() []string {
 
 // Perform a message look-up for the given locale and message using the given arguments.
 //
+// The arguments are either applied to the message with fmt.Sprintf, or, when the only
+// argument is a map, used to replace named placeholders such as "{name}".
+//
 // When either an unknown locale or message is detected, a specially formatted string is returned.
 func Message(locale, message string, args ...interface{}) string {
+	value, found := resolveMessage(locale, message, func(language string) []string {
+		return []string{message}
+	})
+	if !found {
+		return fmt.Sprintf(unknownValueFormat, message)
+	}
+	return formatMessage(value, args)
+}
+
+// Perform a message look-up for the plural form of the given message that matches the count.
+//
+// Plural forms are stored as <message>.<category> (e.g. "items.one" and "items.other"),
+// where the category is selected by the PluralRule of the language.  When that form does
+// not exist, "<message>.other" and then the message itself are tried.
+//
+// Without arguments the count is the only formatting argument of the forms with a
+// formatting verb (e.g. "%d items"), and "{count}" in the others (e.g. "One item").
+// With named arguments, the count is available as "{count}" unless given explicitly.
+func MessagePlural(locale, message string, count int, args ...interface{}) string {
+	value, found := resolveMessage(locale, message, func(language string) []string {
+		category := PluralCategory(language, count)
+		return []string{message + "." + category, message + "." + PluralOther, message}
+	})
+	if !found {
+		return fmt.Sprintf(unknownValueFormat, message)
+	}
+
+	if len(args) == 0 && hasFormatVerb(value) {
+		args = []interface{}{count}
+	} else if len(args) == 0 {
+		args = []interface{}{map[string]interface{}{"count": count}}
+	} else if named, ok := namedMessageArgs(args); ok {
+		withCount := map[string]interface{}{"count": count}
+		for name, arg := range named {
+			withCount[name] = arg
+		}
+		args = []interface{}{withCount}
+	}
+	return formatMessage(value, args)
+}
+
+// Resolve the first of the given message keys known for the locale, falling back to
+// the default language.  The keys are computed per language, as plural forms differ.
+func resolveMessage(locale, message string, keys func(language string) []string) (string, bool) {
 	language, region := parseLocale(locale)
 	glog.V(1).Infof("Resolving message '%s' for language '%s' and region '%s'", message, language, region)
 
-	var value string
-	var err error
-	messageConfig, knownLanguage := messages[language]
-	if knownLanguage {
-		// This works because unlike the goconfig documentation suggests it will actually
-		// try to resolve message in DEFAULT if it did not find it in the given section.
-		value, err = messageConfig.String(region, message)
-		if err != nil {
-			glog.V(1).Infof("Unknown message '%s' for locale '%s', trying default language", message, locale)
-			// Continue to try default language
+	if source, knownLanguage := messages[language]; knownLanguage {
+		if value, found := lookupMessage(source, region, keys(language)); found {
+			return value, true
 		}
+		glog.V(1).Infof("Unknown message '%s' for locale '%s', trying default language", message, locale)
 	} else {
 		glog.V(1).Infof("Unsupported language for locale '%s' and message '%s', trying default language", locale, message)
 	}
 
-	if value == "" {
-		if defaultLanguage, found := Config.String(defaultLanguageOption); found {
-			glog.V(1).Infof("Using default language '%s'", defaultLanguage)
+	defaultLanguage, found := Config.String(defaultLanguageOption)
+	if !found {
+		glog.Warningf("Unable to find default language option (%s); messages for unsupported locales will never be translated", defaultLanguageOption)
+		return "", false
+	}
+	glog.V(1).Infof("Using default language '%s'", defaultLanguage)
 
-			messageConfig, knownLanguage = messages[defaultLanguage]
-			if !knownLanguage {
-				glog.Warningf("Unsupported default language for locale '%s' and message '%s'", defaultLanguage, message)
-				return fmt.Sprintf(unknownValueFormat, message)
-			}
+	source, knownLanguage := messages[defaultLanguage]
+	if !knownLanguage {
+		glog.Warningf("Unsupported default language for locale '%s' and message '%s'", defaultLanguage, message)
+		return "", false
+	}
 
-			value, err = messageConfig.String(region, message)
-			if err != nil {
-				glog.Warningf("Unknown message '%s' for default locale '%s'", message, locale)
-				return fmt.Sprintf(unknownValueFormat, message)
-			}
-		} else {
-			glog.Warningf("Unable to find default language option (%s); messages for unsupported locales will never be translated", defaultLanguageOption)
-			return fmt.Sprintf(unknownValueFormat, message)
+	value, found := lookupMessage(source, region, keys(defaultLanguage))
+	if !found {
+		glog.Warningf("Unknown message '%s' for default locale '%s'", message, locale)
+	}
+	return value, found
+}
+
+func lookupMessage(source MessageSource, region string, keys []string) (string, bool) {
+	for _, key := range keys {
+		if value, found := source.Message(region, key); found && value != "" {
+			return value, true
 		}
 	}
+	return "", false
+}
+
+func formatMessage(value string, args []interface{}) string {
+	if named, ok := namedMessageArgs(args); ok {
+		glog.V(1).Infof("Named arguments detected, interpolating '%s' with %v", value, named)
+		return namedArgPattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+			if arg, found := named[placeholder[1:len(placeholder)-1]]; found {
+				return fmt.Sprint(arg)
+			}
+			return placeholder
+		})
+	}
 
 	if len(args) > 0 {
 		glog.V(1).Infof("Arguments detected, formatting '%s' with %v", value, args)
 		value = fmt.Sprintf(value, args...)
 	}
-
 	return value
 }
 
+// Return true if the value has a formatting verb for fmt.Sprintf, not counting "%%".
+func hasFormatVerb(value string) bool {
+	for i := 0; i < len(value)-1; i++ {
+		if value[i] == '%' {
+			if value[i+1] != '%' {
+				return true
+			}
+			i++
+		}
+	}
+	return false
+}
+
+// Return the named arguments, if the only argument is a map of them.
+func namedMessageArgs(args []interface{}) (map[string]interface{}, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+	switch named := args[0].(type) {
+	case map[string]interface{}:
+		return named, true
+	case map[string]string:
+		result := make(map[string]interface{}, len(named))
+		for name, arg := range named {
+			result[name] = arg
+		}
+		return result, true
+	}
+	return nil, false
+}
+
 func parseLocale(locale string) (language, region string) {
 	if strings.Contains(locale, "-") {
 		languageAndRegion := strings.Split(locale, "-")
@@ -99,7 +194,7 @@ func parseLocale(locale string) (language, region string) {
 
 // Recursively read and cache all available messages from all message files on the given path.
 func loadMessages(path string) {
-	messages = make(map[string]*config.Config)
+	messages = make(map[string]MessageSource)
 
 	if error := filepath.Walk(path, loadMessageFile); error != nil && !os.IsNotExist(error) {
 		glog.Errorln("Error reading messages files:", error)
@@ -115,32 +210,38 @@ func loadMessageFile(path string, info os.FileInfo, osError error) error {
 		return nil
 	}
 
+	var (
+		source   MessageSource
+		language string
+		error    error
+	)
 	if matched, _ := regexp.MatchString(messageFilePattern, info.Name()); matched {
-		if config, error := parseMessagesFile(path); error != nil {
-			return error
-		} else {
-			locale := parseLocaleFromFileName(info.Name())
-
-			// If we have already parsed a message file for this locale, merge both
-			if _, exists := messages[locale]; exists {
-				messages[locale].Merge(config)
-				glog.V(1).Infof("Successfully merged messages for locale '%s'", locale)
-			} else {
-				messages[locale] = config
-			}
-
-			glog.V(1).Infoln("Successfully loaded messages from file", info.Name())
+		language = parseLocaleFromFileName(info.Name())
+		source, error = LoadConfigMessages(path, language)
+	} else if match := loaderFilePattern.FindStringSubmatch(info.Name()); match != nil && MessageLoaders[match[3]] != nil {
+		language = strings.ToLower(match[1])
+		source, error = MessageLoaders[match[3]](path, language)
+		if region := match[2]; error == nil && region != "" {
+			source = regionMessageSource{strings.ToUpper(region), source}
 		}
 	} else {
 		glog.V(1).Infof("Ignoring file %s because it did not have a valid extension", info.Name())
+		return nil
+	}
+	if error != nil {
+		return error
 	}
 
-	return nil
-}
+	// If we have already parsed a message file for this language, merge both
+	if existing, exists := messages[language]; exists {
+		messages[language] = mergeMessageSources(existing, source)
+		glog.V(1).Infof("Successfully merged messages for locale '%s'", language)
+	} else {
+		messages[language] = source
+	}
 
-func parseMessagesFile(path string) (messageConfig *config.Config, error error) {
-	messageConfig, error = config.ReadDefault(path)
-	return
+	glog.V(1).Infoln("Successfully loaded messages from file", info.Name())
+	return nil
 }
 
 func parseLocaleFromFileName(file string) string {
@@ -154,17 +255,41 @@ func init() {
 	})
 }
 
+// I18nFilter resolves the locale of the request using the resolvers configured in
+// "i18n.resolvers" (see LocaleResolvers).
+//
+// Unless the "path" or "query" resolver is configured, the locale query parameter
+// of the links made by LocaleUrl switches the locale: it is kept by SetLocale for
+// the following requests, in the locale cookie or the session.
 func I18nFilter(c *Controller, fc []Filter) {
-	if foundCookie, cookieValue := hasLocaleCookie(c.Request); foundCookie {
-		glog.V(1).Infof("Found locale cookie value: %s", cookieValue)
-		setCurrentLocaleControllerArguments(c, cookieValue)
-	} else if foundHeader, headerValue := hasAcceptLanguageHeader(c.Request); foundHeader {
-		glog.V(1).Infof("Found Accept-Language header value: %s", headerValue)
-		setCurrentLocaleControllerArguments(c, headerValue)
-	} else {
-		glog.V(1).Info("Unable to find locale in cookie or header, using empty string")
-		setCurrentLocaleControllerArguments(c, "")
+	if !localeResolverEnabled("path") && !localeResolverEnabled("query") {
+		if locale, found := resolveQueryLocale(c); found {
+			glog.V(1).Infof("Switching to locale '%s' of the query parameter", locale)
+			c.SetLocale(locale)
+			c.RenderArgs[CurrentUrlRenderArg] = c.Request.URL
+			fc[0](c, fc[1:])
+			return
+		}
+	}
+
+	locale := ""
+	for _, name := range localeResolverNames() {
+		resolver, ok := LocaleResolvers[name]
+		if !ok {
+			glog.Warningf("Unknown locale resolver '%s' in %s", name, localeResolversConfigKey)
+			continue
+		}
+		if value, found := resolver(c); found {
+			glog.V(1).Infof("Found locale '%s' using the %s resolver", value, name)
+			locale = value
+			break
+		}
+	}
+	if locale == "" {
+		glog.V(1).Info("Unable to resolve the locale, using empty string")
 	}
+	setCurrentLocaleControllerArguments(c, locale)
+	c.RenderArgs[CurrentUrlRenderArg] = c.Request.URL
 	fc[0](c, fc[1:])
 }
 
@@ -2,6 +2,8 @@ package revel
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 )
@@ -82,6 +84,101 @@ func TestI18nMessageWithDefaultLocale(t *testing.T) {
 	}
 }
 
+func TestI18nMessageSources(t *testing.T) {
+	loadMessages(testDataPath)
+	loadTestI18nConfig(t)
+
+	// JSON messages are merged with the goconfig ones and nested keys are flattened
+	if message := Message("nl", "json.greeting"); message != "Hallo vanuit JSON" {
+		t.Errorf("Message 'json.greeting' for locale 'nl' (%s) does not have the expected value", message)
+	}
+	if message := Message("nl", "greeting"); message != "Hallo" {
+		t.Errorf("Message 'greeting' for locale 'nl' (%s) does not have the expected value", message)
+	}
+
+	// gettext messages
+	if message := Message("en", "po.greeting"); message != "Hello from gettext" {
+		t.Errorf("Message 'po.greeting' for locale 'en' (%s) does not have the expected value", message)
+	}
+	if message := Message("en", "po.multiline"); message != `Hello "world"` {
+		t.Errorf("Message 'po.multiline' for locale 'en' (%s) does not have the expected value", message)
+	}
+	if message := Message("en", "menu.open"); message != "Open file" {
+		t.Errorf("Message 'menu.open' for locale 'en' (%s) does not have the expected value", message)
+	}
+	if message := Message("en", "po.fuzzy"); message != "??? po.fuzzy ???" {
+		t.Errorf("Fuzzy message 'po.fuzzy' is not supposed to exist, got '%s'", message)
+	}
+	if message := Message("en", "po.untranslated"); message != "??? po.untranslated ???" {
+		t.Errorf("Untranslated message 'po.untranslated' is not supposed to exist, got '%s'", message)
+	}
+
+	// A region in the file name restricts the messages to that region
+	if message := Message("en-AU", "po.greeting"); message != "G'day from gettext" {
+		t.Errorf("Message 'po.greeting' for locale 'en-AU' (%s) does not have the expected value", message)
+	}
+	if message := Message("en-US", "po.greeting"); message != "Hello from gettext" {
+		t.Errorf("Message 'po.greeting' for locale 'en-US' (%s) does not have the expected value", message)
+	}
+	if message := Message("en-AU", "greeting"); message != "G'day" {
+		t.Errorf("Message 'greeting' for locale 'en-AU' (%s) does not have the expected value", message)
+	}
+}
+
+func TestI18nMessagePlural(t *testing.T) {
+	loadMessages(testDataPath)
+	loadTestI18nConfig(t)
+
+	for _, test := range []struct {
+		locale   string
+		count    int
+		expected string
+	}{
+		{"en", 0, "0 apples"},
+		{"en", 1, "1 apple"},
+		{"en", 2, "2 apples"},
+		{"nl", 1, "1 appel"},
+		{"nl", 5, "5 appels"},
+		{"unknown", 1, "1 apple"},
+	} {
+		if message := MessagePlural(test.locale, "apples", test.count); message != test.expected {
+			t.Errorf("Plural message 'apples' for locale '%s' and count %d: expected '%s', got '%s'",
+				test.locale, test.count, test.expected, message)
+		}
+	}
+
+	// Forms without a formatting verb are not given the count, but may name it
+	for count, expected := range map[int]string{1: "Eén peer", 3: "3 peren, 100%% rijp"} {
+		if message := MessagePlural("nl", "pears", count); message != expected {
+			t.Errorf("Plural message 'pears' for locale 'nl' and count %d: expected '%s', got '%s'", count, expected, message)
+		}
+	}
+
+	// Messages without plural forms are used as they are
+	if message := MessagePlural("en", "arguments.string", 3, "Vincent Hanna"); message != "My name is Vincent Hanna" {
+		t.Errorf("Message 'arguments.string' for locale 'en' (%s) does not have the expected value", message)
+	}
+	if message := MessagePlural("en", "unknown message", 3); message != "??? unknown message ???" {
+		t.Error("Message 'unknown message' is not supposed to exist")
+	}
+}
+
+func TestI18nMessageNamedArguments(t *testing.T) {
+	loadMessages(testDataPath)
+	loadTestI18nConfig(t)
+
+	args := map[string]interface{}{"name": "Rob", "count": 3}
+	if message := Message("en", "named.greeting", args); message != "Hello Rob, you have 3 messages" {
+		t.Errorf("Message 'named.greeting' for locale 'en' (%s) does not have the expected value", message)
+	}
+	if message := Message("nl", "named.greeting", map[string]string{"name": "Rob"}); message != "Hallo Rob, je hebt {count} berichten" {
+		t.Errorf("Message 'named.greeting' for locale 'nl' (%s) does not have the expected value", message)
+	}
+	if message := MessagePlural("nl", "named.greeting", 7, map[string]interface{}{"name": "Rob"}); message != "Hallo Rob, je hebt 7 berichten" {
+		t.Errorf("Plural message 'named.greeting' for locale 'nl' (%s) does not have the expected value", message)
+	}
+}
+
 func TestHasLocaleCookie(t *testing.T) {
 	loadTestI18nConfig(t)
 
@@ -134,6 +231,126 @@ func TestBeforeRequest(t *testing.T) {
 	}
 }
 
+func TestMatchLocale(t *testing.T) {
+	loadMessages(testDataPath)
+
+	for _, test := range []struct {
+		locales  []string
+		expected string
+		found    bool
+	}{
+		{[]string{"nl"}, "nl", true},
+		{[]string{"EN"}, "en", true},
+		{[]string{"en-au"}, "en-AU", true},
+		{[]string{"en_GB"}, "en-GB", true},
+		{[]string{"pt-BR", "en-US"}, "en-US", true},
+		{[]string{"fr", "de"}, "", false},
+		{[]string{"en-foo-bar", "*"}, "", false},
+	} {
+		if locale, found := MatchLocale(test.locales...); found != test.found || locale != test.expected {
+			t.Errorf("Expected %v to match '%s' (%v), got '%s' (%v)", test.locales, test.expected, test.found, locale, found)
+		}
+	}
+}
+
+func TestI18nFilterResolvers(t *testing.T) {
+	loadMessages(testDataPath)
+	loadTestI18nConfig(t)
+	Config.config.AddOption("DEFAULT", "i18n.resolvers", "path, query, session, cookie, header")
+
+	// The path prefix is removed before routing
+	c := NewController(buildRequestWithUrl("/nl/hotels?page=2"), nil, nil)
+	if I18nPathFilter(c, []Filter{I18nFilter, NilFilter}); c.Request.Locale != "nl" {
+		t.Errorf("Expected to find current language '%s' in controller, found '%s' instead", "nl", c.Request.Locale)
+	}
+	if c.Request.URL.Path != "/hotels" {
+		t.Errorf("Expected the locale to be removed from the path, got '%s'", c.Request.URL.Path)
+	}
+
+	// Paths that do not start with a supported locale are left alone
+	c = NewController(buildRequestWithUrl("/fr/hotels?lang=en-au"), nil, nil)
+	if I18nPathFilter(c, []Filter{I18nFilter, NilFilter}); c.Request.Locale != "en-AU" {
+		t.Errorf("Expected to find current language '%s' in controller, found '%s' instead", "en-AU", c.Request.Locale)
+	}
+	if c.Request.URL.Path != "/fr/hotels" {
+		t.Errorf("Expected the path to be unchanged, got '%s'", c.Request.URL.Path)
+	}
+
+	c = NewController(buildRequestWithUrl("/hotels"), nil, nil)
+	c.Session = Session{"locale": "nl"}
+	if I18nFilter(c, NilChain); c.Request.Locale != "nl" {
+		t.Errorf("Expected to find current language '%s' in controller, found '%s' instead", "nl", c.Request.Locale)
+	}
+
+	// Unsupported languages in the Accept-Language header are skipped
+	c = NewController(buildRequestWithAcceptLanguages("pt-BR", "nl-BE", "en"), nil, nil)
+	if I18nFilter(c, NilChain); c.Request.Locale != "nl-BE" {
+		t.Errorf("Expected to find current language '%s' in controller, found '%s' instead", "nl-BE", c.Request.Locale)
+	}
+	c = NewController(buildRequestWithAcceptLanguages("pt-BR"), nil, nil)
+	if I18nFilter(c, NilChain); c.Request.Locale != "" {
+		t.Errorf("Expected to find current language '%s' in controller, found '%s' instead", "", c.Request.Locale)
+	}
+}
+
+func TestLocaleUrl(t *testing.T) {
+	loadTestI18nConfig(t)
+
+	current, _ := url.Parse("/hotels?page=2&lang=en")
+	if localized := LocaleUrl(current, "nl"); localized != "/hotels?lang=nl&page=2" {
+		t.Errorf("Unexpected localized URL '%s'", localized)
+	}
+
+	Config.config.AddOption("DEFAULT", "i18n.resolvers", "path,cookie")
+	if localized := LocaleUrl(current, "nl"); localized != "/nl/hotels?page=2" {
+		t.Errorf("Unexpected localized URL '%s'", localized)
+	}
+	if current.String() != "/hotels?page=2&lang=en" {
+		t.Errorf("Expected the current URL to be unchanged, got '%s'", current)
+	}
+}
+
+// Test that the links of LocaleUrl switch the locale with the default resolvers,
+// which do not read the query.
+func TestLocaleUrlSwitch(t *testing.T) {
+	loadMessages(testDataPath)
+	loadTestI18nConfig(t)
+
+	current, _ := url.Parse("/hotels")
+	localized := LocaleUrl(current, "nl")
+	resp := httptest.NewRecorder()
+	c := NewController(buildRequestWithUrl(localized), NewResponse(resp), nil)
+	if I18nFilter(c, NilChain); c.Request.Locale != "nl" {
+		t.Errorf("Expected the locale of '%s', found '%s' instead", localized, c.Request.Locale)
+	}
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "APP_LANG" || cookies[0].Value != "nl" {
+		t.Errorf("Expected the locale to be kept in the locale cookie, got %v", cookies)
+	}
+
+	// Unsupported locales are ignored
+	resp = httptest.NewRecorder()
+	c = NewController(buildRequestWithUrl("/hotels?lang=fr"), NewResponse(resp), nil)
+	if I18nFilter(c, NilChain); c.Request.Locale != "" || len(resp.Result().Cookies()) != 0 {
+		t.Errorf("Expected the unsupported locale to be ignored, found '%s'", c.Request.Locale)
+	}
+
+	// In the session, if it is configured instead of the cookie
+	Config.config.AddOption("DEFAULT", "i18n.resolvers", "session,header")
+	c = NewController(buildRequestWithUrl(localized), NewResponse(httptest.NewRecorder()), nil)
+	c.Session = Session{}
+	if I18nFilter(c, NilChain); c.Request.Locale != "nl" || c.Session["locale"] != "nl" {
+		t.Errorf("Expected the locale to be kept in the session, found '%s' and %v", c.Request.Locale, c.Session)
+	}
+
+	// No link switches the locale of the header
+	Config.config.AddOption("DEFAULT", "i18n.resolvers", "header")
+	renderArgs := map[string]interface{}{CurrentUrlRenderArg: current}
+	if link, err := localeUrl(renderArgs, "nl"); err == nil {
+		t.Errorf("Expected an error for a link that cannot switch the locale, got '%s'", link)
+	}
+}
+
 func BenchmarkI18nLoadMessages(b *testing.B) {
 	// TODO: excludeFromTimer(b, func() { TRACE = log.New(ioutil.Discard, "", 0) })
 
@@ -202,6 +419,11 @@ func buildRequestWithAcceptLanguages(acceptLanguages ...string) *Request {
 	return request
 }
 
+func buildRequestWithUrl(url string) *Request {
+	httpRequest, _ := http.NewRequest("GET", url, nil)
+	return NewRequest(httpRequest)
+}
+
 func buildEmptyRequest() *Request {
 	httpRequest, _ := http.NewRequest("GET", "/", nil)
 	request := NewRequest(httpRequest)
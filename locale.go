@@ -0,0 +1,185 @@
+package revel
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+)
+
+const (
+	CurrentUrlRenderArg = "currentUrl" // The key for the (unlocalized) URL of the current request
+
+	localeResolversConfigKey  = "i18n.resolvers"
+	localeQueryParamConfigKey = "i18n.query_param"
+	localeSessionConfigKey    = "i18n.session_key"
+	defaultLocaleResolvers    = "cookie,header"
+	localePathArg             = "i18n.pathLocale" // Controller.Args key of the locale found by I18nPathFilter
+)
+
+// A locale, e.g. "en", "pt-BR" or "pt_br".
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(?:[-_](?:[a-zA-Z]{2}|[0-9]{3}))?$`)
+
+// A LocaleResolver determines the locale requested by the client, if it requested one.
+type LocaleResolver func(c *Controller) (locale string, found bool)
+
+// LocaleResolvers are the strategies that may be listed in "i18n.resolvers".
+// I18nFilter tries the configured resolvers in order, by default "cookie,header".
+//
+//   path    - a "/{locale}/..." path prefix, removed by I18nPathFilter before routing.
+//   query   - a query parameter, named by "i18n.query_param" (default "lang").
+//   session - a session value, named by "i18n.session_key" (default "locale").
+//   cookie  - the locale cookie, named by "i18n.cookie" (default "<cookie.prefix>_LANG").
+//   header  - the Accept-Language header.
+//
+// The path, query and header resolvers only accept locales with loaded messages.
+// Applications may register additional resolvers.
+var LocaleResolvers = map[string]LocaleResolver{
+	"path":    resolvePathLocale,
+	"query":   resolveQueryLocale,
+	"session": resolveSessionLocale,
+	"cookie":  resolveCookieLocale,
+	"header":  resolveHeaderLocale,
+}
+
+// Return the names of the configured locale resolvers, in order.
+func localeResolverNames() []string {
+	var names []string
+	for _, name := range strings.Split(Config.StringDefault(localeResolversConfigKey, defaultLocaleResolvers), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Return true if the links of LocaleUrl switch the locale with the configured
+// resolvers.
+func localeSwitchable() bool {
+	for _, name := range []string{"path", "query", "cookie", "session"} {
+		if localeResolverEnabled(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func localeResolverEnabled(name string) bool {
+	return ContainsString(localeResolverNames(), name)
+}
+
+// MatchLocale returns the first of the given locales whose language has messages.
+//
+// The locale is returned normalized ("pt_br" becomes "pt-BR").  Regions need not
+// have messages of their own: "pt-BR" matches if there are messages for "pt", and
+// Message falls back from the region to the language.
+func MatchLocale(locales ...string) (string, bool) {
+	for _, locale := range locales {
+		locale = strings.TrimSpace(locale)
+		if !localePattern.MatchString(locale) {
+			continue
+		}
+
+		language, region := parseLocale(strings.Replace(locale, "_", "-", 1))
+		language = strings.ToLower(language)
+		if _, found := messages[language]; !found {
+			continue
+		}
+		if region == "" {
+			return language, true
+		}
+		return language + "-" + strings.ToUpper(region), true
+	}
+	return "", false
+}
+
+// I18nPathFilter removes a leading locale from the request path ("/nl/hotels"
+// becomes "/hotels"), so that the routes need not include it.  The locale is
+// used by the "path" resolver of I18nFilter.
+//
+// It must run before RouterFilter, and does nothing unless the "path" resolver
+// is configured.
+func I18nPathFilter(c *Controller, fc []Filter) {
+	if localeResolverEnabled("path") {
+		segments := strings.SplitN(strings.TrimPrefix(c.Request.URL.Path, "/"), "/", 2)
+		if locale, found := MatchLocale(segments[0]); found {
+			glog.V(1).Infof("Found locale '%s' in path %s", locale, c.Request.URL.Path)
+			c.Args[localePathArg] = locale
+			c.Request.URL.Path = "/"
+			if len(segments) == 2 {
+				c.Request.URL.Path += segments[1]
+			}
+			c.Request.URL.RawPath = ""
+		}
+	}
+	fc[0](c, fc[1:])
+}
+
+func resolvePathLocale(c *Controller) (string, bool) {
+	locale, found := c.Args[localePathArg].(string)
+	return locale, found
+}
+
+func resolveQueryLocale(c *Controller) (string, bool) {
+	name := Config.StringDefault(localeQueryParamConfigKey, "lang")
+	if value := c.Request.URL.Query().Get(name); value != "" {
+		if locale, found := MatchLocale(value); found {
+			return locale, true
+		}
+		glog.V(1).Infof("Ignoring unsupported locale '%s' in query parameter '%s'", value, name)
+	}
+	return "", false
+}
+
+func resolveSessionLocale(c *Controller) (string, bool) {
+	if c.Session == nil {
+		return "", false
+	}
+	locale, found := c.Session[Config.StringDefault(localeSessionConfigKey, "locale")]
+	return locale, found && locale != ""
+}
+
+func resolveCookieLocale(c *Controller) (string, bool) {
+	found, locale := hasLocaleCookie(c.Request)
+	return locale, found
+}
+
+// Use the most qualified language of the Accept-Language header that has messages.
+// If no messages have been loaded at all, the most qualified language is used as is.
+func resolveHeaderLocale(c *Controller) (string, bool) {
+	if len(messages) == 0 {
+		found, locale := hasAcceptLanguageHeader(c.Request)
+		return locale, found
+	}
+
+	var languages []string
+	for _, acceptLanguage := range c.Request.AcceptLanguages {
+		if acceptLanguage.Quality > 0 {
+			languages = append(languages, acceptLanguage.Language)
+		}
+	}
+	return MatchLocale(languages...)
+}
+
+// LocaleUrl returns the given URL switched to the given locale: prefixed with it if
+// the "path" resolver is configured, and otherwise with the locale query parameter,
+// which I18nFilter keeps in the cookie or the session unless the "query" resolver
+// is configured.  The URL is expected without locale prefix, as is the current URL
+// after I18nPathFilter.
+func LocaleUrl(u *url.URL, locale string) string {
+	localized := *u
+	name := Config.StringDefault(localeQueryParamConfigKey, "lang")
+	query := localized.Query()
+
+	if localeResolverEnabled("path") {
+		localized.Path = "/" + locale + "/" + strings.TrimPrefix(localized.Path, "/")
+		localized.RawPath = ""
+		query.Del(name)
+	} else {
+		query.Set(name, locale)
+	}
+	localized.RawQuery = query.Encode()
+
+	return localized.RequestURI()
+}
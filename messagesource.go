@@ -0,0 +1,306 @@
+package revel
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/robfig/config"
+)
+
+// A MessageSource holds the translated messages of a single language.
+type MessageSource interface {
+	// Return the message for the given key in the given region (e.g. "US"), or
+	// in the language default when the region does not define it.
+	// The region may be empty, in which case only the language default is used.
+	Message(region, key string) (string, bool)
+}
+
+// A MessageLoader parses a message file into a MessageSource.
+// The language is the one taken from the file name, e.g. "en" for "app.en.po".
+type MessageLoader func(path, language string) (MessageSource, error)
+
+// MessageLoaders maps message file extensions to the loader able to read them.
+//
+// Files handled by a loader are named <name>.<locale><extension>, e.g. "app.en.json"
+// or "app.en-US.po".  A region in the file name restricts its messages to that region.
+// Files in the goconfig format are named <name>.<language> and do not need a loader.
+var MessageLoaders = map[string]MessageLoader{
+	".json": LoadJSONMessages,
+	".po":   LoadPOMessages,
+}
+
+// ConfigMessageSource is a MessageSource in the goconfig format: the DEFAULT
+// section holds the language default and every other section holds a region.
+type ConfigMessageSource struct {
+	*config.Config
+}
+
+// Load a message file in the goconfig format.
+func LoadConfigMessages(path, language string) (MessageSource, error) {
+	messageConfig, err := config.ReadDefault(path)
+	if err != nil {
+		return nil, err
+	}
+	return ConfigMessageSource{messageConfig}, nil
+}
+
+func (s ConfigMessageSource) Message(region, key string) (string, bool) {
+	// This works because unlike the goconfig documentation suggests it will actually
+	// try to resolve message in DEFAULT if it did not find it in the given section.
+	value, err := s.Config.String(region, key)
+	return value, err == nil
+}
+
+// MessageMap is a MessageSource without regions.
+type MessageMap map[string]string
+
+func (m MessageMap) Message(region, key string) (string, bool) {
+	value, ok := m[key]
+	return value, ok
+}
+
+// Load a message file in JSON format.
+//
+// The file contains a single object.  Nested objects are flattened using "." to
+// join the keys, so that plural forms can be given as an object of categories:
+//
+//   {
+//     "greeting": "Hello",
+//     "items": {"one": "%d item", "other": "%d items"}
+//   }
+func LoadJSONMessages(path, language string) (MessageSource, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err = json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	messages := make(MessageMap)
+	if err = flattenJSONMessages(messages, "", values); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return messages, nil
+}
+
+func flattenJSONMessages(messages MessageMap, prefix string, values map[string]interface{}) error {
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			messages[prefix+key] = v
+		case map[string]interface{}:
+			if err := flattenJSONMessages(messages, prefix+key+".", v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s%s: expected a string or an object, got %v", prefix, key, value)
+		}
+	}
+	return nil
+}
+
+// Load a message file in the gettext .po format.
+//
+// The msgid is used as the message key, prefixed by "<msgctxt>." if the entry has
+// a context.  Plural forms (msgstr[N]) are stored as <key>.<category>, where the
+// categories of the language's PluralRule are taken in order.  Fuzzy and
+// untranslated entries are ignored.
+func LoadPOMessages(path, language string) (MessageSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		messages = make(MessageMap)
+		entry    = &poEntry{}
+		field    *string // The field that continuation lines are appended to.
+		lineNum  int
+	)
+
+	flush := func() {
+		entry.addTo(messages, language)
+		entry, field = &poEntry{}, nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			flush()
+			continue
+		case strings.HasPrefix(line, "#"):
+			if entry.started() {
+				flush()
+			}
+			if strings.HasPrefix(line, "#,") && strings.Contains(line, "fuzzy") {
+				entry.fuzzy = true
+			}
+			continue
+		case strings.HasPrefix(line, `"`):
+			if field == nil {
+				return nil, fmt.Errorf("%s:%d: unexpected string", path, lineNum)
+			}
+			value, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", path, lineNum, err)
+			}
+			*field += value
+			continue
+		}
+
+		keyword, quoted := line, ""
+		if i := strings.IndexAny(line, " \t"); i > 0 {
+			keyword, quoted = line[:i], strings.TrimSpace(line[i:])
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, lineNum, err)
+		}
+
+		switch {
+		case keyword == "msgctxt":
+			if entry.started() {
+				flush()
+			}
+			entry.context = value
+			field = &entry.context
+		case keyword == "msgid":
+			if entry.translated() {
+				flush()
+			}
+			entry.id, entry.hasId = value, true
+			field = &entry.id
+		case keyword == "msgid_plural":
+			entry.plural = value
+			field = &entry.plural
+		case keyword == "msgstr":
+			entry.str = append(entry.str, value)
+			field = &entry.str[len(entry.str)-1]
+		case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+			index, err := strconv.Atoi(keyword[len("msgstr[") : len(keyword)-1])
+			if err != nil || index != len(entry.str) {
+				return nil, fmt.Errorf("%s:%d: unexpected plural form %s", path, lineNum, keyword)
+			}
+			entry.str = append(entry.str, value)
+			field = &entry.str[index]
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown keyword %s", path, lineNum, keyword)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return messages, nil
+}
+
+// A single entry of a .po file.
+type poEntry struct {
+	context, id, plural string
+	str                 []string
+	hasId, fuzzy        bool
+}
+
+func (e *poEntry) started() bool {
+	return e.hasId || e.context != ""
+}
+
+func (e *poEntry) translated() bool {
+	return len(e.str) > 0
+}
+
+func (e *poEntry) addTo(messages MessageMap, language string) {
+	// The entry with an empty msgid is the header.
+	if !e.hasId || e.id == "" || e.fuzzy {
+		return
+	}
+
+	key := e.id
+	if e.context != "" {
+		key = e.context + "." + key
+	}
+
+	if e.plural == "" {
+		if len(e.str) > 0 && e.str[0] != "" {
+			messages[key] = e.str[0]
+		}
+		return
+	}
+
+	categories := pluralRule(language).Categories
+	for i, value := range e.str {
+		if i >= len(categories) || value == "" {
+			continue
+		}
+		messages[key+"."+categories[i]] = value
+	}
+}
+
+// regionMessageSource restricts a MessageSource to a single region.
+type regionMessageSource struct {
+	region string
+	MessageSource
+}
+
+func (s regionMessageSource) Message(region, key string) (string, bool) {
+	if !strings.EqualFold(region, s.region) {
+		return "", false
+	}
+	return s.MessageSource.Message("", key)
+}
+
+// messageSources combines the sources of all message files of a language.
+// Sources added later take precedence, except that the sources restricted to a
+// region are consulted first.
+type messageSources []MessageSource
+
+func (sources messageSources) Message(region, key string) (string, bool) {
+	for _, regional := range []bool{true, false} {
+		for i := len(sources) - 1; i >= 0; i-- {
+			if _, ok := sources[i].(regionMessageSource); ok != regional {
+				continue
+			}
+			if value, ok := sources[i].Message(region, key); ok {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Merge the given source into the existing one, returning the result.
+// Sources of the same kind are merged directly, so that goconfig files keep
+// resolving folded values across files.
+func mergeMessageSources(existing, source MessageSource) MessageSource {
+	switch e := existing.(type) {
+	case ConfigMessageSource:
+		if s, ok := source.(ConfigMessageSource); ok {
+			e.Merge(s.Config)
+			return e
+		}
+	case MessageMap:
+		if s, ok := source.(MessageMap); ok {
+			for key, value := range s {
+				e[key] = value
+			}
+			return e
+		}
+	case messageSources:
+		return append(e, source)
+	}
+	return messageSources{existing, source}
+}
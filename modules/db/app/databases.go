@@ -0,0 +1,120 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// Besides the default database (db.driver and db.spec), Init opens the named
+// databases configured in app.conf, which are returned by Get:
+//
+//	db.replica.driver = postgres
+//	db.replica.spec   = host=replica dbname=app
+//
+// The pool of connections of each database is configured by:
+//
+//	db.maxopen         = 0    (the limit of open connections, none if 0)
+//	db.maxidle         = 2    (the limit of idle connections)
+//	db.connmaxlifetime = 1h   (how long a connection is reused, forever if 0)
+//
+// which a named database may override, e.g. db.replica.maxopen.
+//
+// The read-only actions of Transactional controllers (see ReadOnly) run on one
+// of the read replicas, which are named databases, in turn:
+//
+//	db.replicas = replica, replica2
+
+var (
+	// The named databases, by name.
+	databases = make(map[string]*sql.DB)
+
+	// The read replicas, and the index of the last one used.
+	replicas    []*sql.DB
+	replicaNext uint32
+)
+
+// Get returns the named database, the default one for "" or "default", or nil
+// if there is none.
+func Get(name string) *sql.DB {
+	if name == "" || name == "default" {
+		return Db
+	}
+	return databases[name]
+}
+
+// Open a database, and configure its pool from the options with the given
+// prefix ("db" or "db.<name>"), or else those of the default database.
+func open(prefix, driver, spec string) *sql.DB {
+	database, err := openInstrumented(driver, spec)
+	if err != nil {
+		glog.Fatal(err)
+	}
+
+	maxOpen := revel.Config.IntDefault("db.maxopen", 0)
+	maxIdle := revel.Config.IntDefault("db.maxidle", 2)
+	maxLifetime := configDuration("db.connmaxlifetime", 0)
+	if prefix != "db" {
+		maxOpen = revel.Config.IntDefault(prefix+".maxopen", maxOpen)
+		maxIdle = revel.Config.IntDefault(prefix+".maxidle", maxIdle)
+		maxLifetime = configDuration(prefix+".connmaxlifetime", maxLifetime)
+	}
+	database.SetMaxOpenConns(maxOpen)
+	database.SetMaxIdleConns(maxIdle)
+	database.SetConnMaxLifetime(maxLifetime)
+	return database
+}
+
+// Open the named databases, and the read replicas among them.
+func openNamed() {
+	for _, option := range revel.Config.Options("db.") {
+		name := strings.TrimPrefix(option, "db.")
+		if !strings.HasSuffix(name, ".driver") || strings.Count(name, ".") != 1 {
+			continue
+		}
+		name = strings.TrimSuffix(name, ".driver")
+		driver, _ := revel.Config.String(option)
+		spec, found := revel.Config.String("db." + name + ".spec")
+		if !found {
+			glog.Fatalf("No db.%s.spec found.", name)
+		}
+		databases[name] = open("db."+name, driver, spec)
+	}
+
+	replicas = nil
+	for _, name := range strings.Split(revel.Config.StringDefault("db.replicas", ""), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		replica, ok := databases[name]
+		if !ok {
+			glog.Fatalf("The replica %s is not a named database (db.%s.driver).", name, name)
+		}
+		replicas = append(replicas, replica)
+	}
+}
+
+// Return the next read replica, or nil if there is none.
+func replica() *sql.DB {
+	if len(replicas) == 0 {
+		return nil
+	}
+	return replicas[int(atomic.AddUint32(&replicaNext, 1)-1)%len(replicas)]
+}
+
+// Return the duration configured by the given key, or the default.
+func configDuration(key string, defaultDuration time.Duration) time.Duration {
+	value, found := revel.Config.String(key)
+	if !found {
+		return defaultDuration
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		glog.Fatalf("Could not parse %s %s: %s", key, value, err)
+	}
+	return duration
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Init the module with an in-memory SQLite database and a replica, with the
+// given options added to the configuration, until the returned function is
+// called.
+func initTestDatabases(t *testing.T, options map[string]string) func() {
+	oldConfig, oldDb, oldDriver, oldSpec := revel.Config, Db, Driver, Spec
+	revel.Config = revel.NewEmptyConfig()
+	for name, value := range map[string]string{
+		"db.driver":         "sqlite3",
+		"db.spec":           "file:primary?mode=memory&cache=shared",
+		"db.replica.driver": "sqlite3",
+		"db.replica.spec":   "file:replica?mode=memory&cache=shared",
+		"db.replicas":       "replica",
+	} {
+		revel.Config.SetOption(name, value)
+	}
+	for name, value := range options {
+		revel.Config.SetOption(name, value)
+	}
+
+	Init()
+	return func() {
+		for name, database := range databases {
+			database.Close()
+			delete(databases, name)
+		}
+		Db.Close()
+		replicas = nil
+		revel.Config, Db, Driver, Spec = oldConfig, oldDb, oldDriver, oldSpec
+	}
+}
+
+func TestGet(t *testing.T) {
+	defer initTestDatabases(t, nil)()
+
+	for _, name := range []string{"", "default"} {
+		if Get(name) != Db {
+			t.Errorf("Expected the default database for %q", name)
+		}
+	}
+	if replica := Get("replica"); replica == nil || replica == Db || replica != replicas[0] {
+		t.Errorf("Expected the replica database, got %v", replica)
+	}
+	if missing := Get("archive"); missing != nil {
+		t.Errorf("Expected no archive database, got %v", missing)
+	}
+}
+
+// Hold n connections of the database at once, then release them.
+func useConnections(t *testing.T, database *sql.DB, n int) {
+	var conns []*sql.Conn
+	for i := 0; i < n; i++ {
+		conn, err := database.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+func TestPoolOptions(t *testing.T) {
+	defer initTestDatabases(t, map[string]string{
+		"db.maxopen":                 "5",
+		"db.maxidle":                 "1",
+		"db.connmaxlifetime":         "1h",
+		"db.replica.maxidle":         "3",
+		"db.replica.connmaxlifetime": "50ms",
+	})()
+
+	// The replica has the options of the default database it does not override.
+	for name, maxOpen := range map[string]int{"default": 5, "replica": 5} {
+		if stats := Get(name).Stats(); stats.MaxOpenConnections != maxOpen {
+			t.Errorf("%s: expected %d open connections at most, got %d", name, maxOpen, stats.MaxOpenConnections)
+		}
+	}
+
+	// The idle connections beyond the limit are closed.
+	for name, maxIdle := range map[string]int{"default": 1, "replica": 3} {
+		database := Get(name)
+		useConnections(t, database, 4)
+		if stats := database.Stats(); stats.Idle != maxIdle || stats.MaxIdleClosed != int64(4-maxIdle) {
+			t.Errorf("%s: expected %d idle connections, got %d (%d closed)", name, maxIdle, stats.Idle, stats.MaxIdleClosed)
+		}
+	}
+
+	// The connections are not reused past their lifetime.
+	time.Sleep(100 * time.Millisecond)
+	for _, name := range []string{"default", "replica"} {
+		useConnections(t, Get(name), 1)
+	}
+	if closed := Db.Stats().MaxLifetimeClosed; closed != 0 {
+		t.Errorf("Expected no connection of the default database closed by its lifetime, got %d", closed)
+	}
+	if closed := Get("replica").Stats().MaxLifetimeClosed; closed == 0 {
+		t.Error("Expected the connections of the replica closed by their lifetime")
+	}
+}
+
+func TestReadOnlyOnReplica(t *testing.T) {
+	defer initTestDatabases(t, nil)()
+	if _, err := Get("replica").Exec("CREATE TABLE hotels (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	TransactionAction(ReplicaTest.Show).ReadOnly()
+	defer func() {
+		transactionConfigsMutex.Lock()
+		delete(transactionConfigs, "ReplicaTest.Show")
+		transactionConfigsMutex.Unlock()
+	}()
+
+	// Only the replica has the hotels table.
+	for action, onReplica := range map[string]bool{"Show": true, "Book": false} {
+		c := revel.NewController(nil, nil, nil)
+		c.Name, c.MethodType = "ReplicaTest", &revel.MethodType{Name: action}
+		txn, err := beginTransaction(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = txn.Exec("SELECT COUNT(*) FROM hotels")
+		if (err == nil) != onReplica {
+			t.Errorf("%s: expected the transaction on the replica %v, got error %v", action, onReplica, err)
+		}
+		txn.Rollback()
+	}
+}
+
+type ReplicaTest struct {
+	*revel.Controller
+}
+
+func (c ReplicaTest) Show() revel.Result { return nil }
+func (c ReplicaTest) Book() revel.Result { return nil }
+
+func TestHealth(t *testing.T) {
+	defer initTestDatabases(t, nil)()
+
+	statuses, healthy := Health(time.Second)
+	if !healthy || len(statuses) != 2 {
+		t.Fatalf("Expected 2 healthy databases, got %+v", statuses)
+	}
+	if statuses[0].Name != "default" || statuses[1].Name != "replica" || statuses[0].OpenConnections == 0 {
+		t.Errorf("Unexpected statuses %+v", statuses)
+	}
+
+	// A closed database is unhealthy.
+	Get("replica").Close()
+	statuses, healthy = Health(time.Second)
+	if healthy {
+		t.Error("Expected the databases unhealthy with the replica closed")
+	}
+	if status := statuses[1]; status.Healthy || status.Error == "" {
+		t.Errorf("Expected the replica unhealthy, got %+v", status)
+	}
+	if !statuses[0].Healthy {
+		t.Errorf("Expected the default database healthy, got %+v", statuses[0])
+	}
+}
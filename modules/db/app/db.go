@@ -5,8 +5,25 @@
 // that manage the transaction
 //
 // In particular, a transaction is begun before each request and committed on
-// success.  If a panic occurred during the request, the transaction is rolled
-// back.  (The application may also roll the transaction back itself.)
+// success.  If a panic occurred during the request, or the action returned an
+// error (a status of 400 or above), the transaction is rolled back.  (The
+// application may also roll the transaction back itself.)  The transaction of
+// each action may be configured (see TransactionConfigurator), and helpers may
+// use savepoints (see Savepoint).
+//
+// For tests, fixture files may be loaded into the database with LoadFixtures.
+// When db.test.transactional is set, each test runs in a transaction shared by
+// all its requests, which is rolled back after the test.
+//
+// Several named databases may be configured, along with their pools of
+// connections, and the read-only actions may run on read replicas.  (See Get
+// and TransactionConfigurator)  Health checks their connections.
+//
+// The queries of each request are recorded, and the slow ones logged.  (See
+// QueryLog)
+//
+// The schema is versioned by SQL migrations, applied on startup when db.migrate
+// is set, or by the "revel migrate" command.  (See Migrator)
 package db
 
 import (
@@ -32,11 +49,26 @@ func Init() {
 		glog.Fatal("No db.spec found.")
 	}
 
-	// Open a connection.
-	var err error
-	Db, err = sql.Open(Driver, Spec)
-	if err != nil {
-		glog.Fatal(err)
+	// Open the databases, recording their queries.
+	configureQueryLog()
+	Db = open("db", Driver, Spec)
+	openNamed()
+
+	// Apply the pending migrations, if enabled.
+	migrateOnStartup()
+
+	// Roll back the changes of every test?
+	if revel.Config.BoolDefault("db.test.transactional", false) {
+		revel.BeforeTestHooks = append(revel.BeforeTestHooks, func() {
+			if err := BeginTestTransaction(); err != nil {
+				panic(err)
+			}
+		})
+		revel.AfterTestHooks = append(revel.AfterTestHooks, func() {
+			if err := RollbackTestTransaction(); err != nil {
+				glog.Error("db: failed to roll back the test transaction: ", err)
+			}
+		})
 	}
 }
 
@@ -47,7 +79,13 @@ type Transactional struct {
 
 // Begin a transaction
 func (c *Transactional) Begin() revel.Result {
-	txn, err := Db.Begin()
+	// While a test runs in transactional mode, all requests share its transaction.
+	if testTxn != nil {
+		c.Txn = testTxn
+		return nil
+	}
+
+	txn, err := beginTransaction(c.Controller)
 	if err != nil {
 		panic(err)
 	}
@@ -57,7 +95,7 @@ func (c *Transactional) Begin() revel.Result {
 
 // Rollback if it's still going (must have panicked).
 func (c *Transactional) Rollback() revel.Result {
-	if c.Txn != nil {
+	if c.Txn != nil && c.Txn != testTxn {
 		if err := c.Txn.Rollback(); err != nil {
 			if err != sql.ErrTxDone {
 				panic(err)
@@ -68,9 +106,12 @@ func (c *Transactional) Rollback() revel.Result {
 	return nil
 }
 
-// Commit the transaction.
+// Commit the transaction, or roll it back if the action returned an error.
 func (c *Transactional) Commit() revel.Result {
-	if c.Txn != nil {
+	if c.Txn != nil && c.Txn != testTxn {
+		if failed(c.Controller) {
+			return c.Rollback()
+		}
 		if err := c.Txn.Commit(); err != nil {
 			if err != sql.ErrTxDone {
 				panic(err)
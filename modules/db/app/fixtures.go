@@ -0,0 +1,298 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/BSP-Mosaic/teltech-revel"
+	"gopkg.in/yaml.v2"
+)
+
+// FixturesPath is the directory holding the fixture files, relative to the
+// application base path.
+//
+// A fixture file lists rows to insert by table, in YAML (.yml, .yaml) or JSON
+// (.json), e.g.
+//
+//	users:
+//	  - id: 1
+//	    name: Alice
+//	bookings:
+//	  - id: 1
+//	    user_id: 1
+//
+// Tables are filled in the order they appear, and emptied in the reverse order.
+var FixturesPath = filepath.Join("tests", "fixtures")
+
+// The extensions of fixture files, in the order they are looked up.
+var fixtureExtensions = []string{".yml", ".yaml", ".json"}
+
+// The rows of a table, as read from a fixture file.
+type fixtureTable struct {
+	name string
+	rows []map[string]interface{}
+}
+
+// The test transaction, shared by all requests while a test runs in
+// transactional test mode (see BeginTestTransaction).
+var testTxn *sql.Tx
+
+// LoadFixtures empties the tables of the named fixture files (by name, without
+// extension) and inserts their rows.  With no names, all the fixture files are
+// loaded, in alphabetical order.
+//
+// Called from a test suite's Before(), it resets the data of the tables between
+// tests.
+func LoadFixtures(names ...string) error {
+	paths, err := fixturePaths(names)
+	if err != nil {
+		return err
+	}
+
+	var tables []fixtureTable
+	for _, path := range paths {
+		fileTables, err := readFixtureFile(path)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, fileTables...)
+	}
+
+	tableNames := make([]string, len(tables))
+	for i, table := range tables {
+		tableNames[i] = table.name
+	}
+	if err = Truncate(tableNames...); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		for _, row := range table.rows {
+			if err = insertRow(table.name, row); err != nil {
+				return err
+			}
+		}
+		glog.V(1).Infof("db: loaded %d rows into %s", len(table.rows), table.name)
+	}
+	return nil
+}
+
+// Truncate deletes all the rows of the given tables, in reverse order (so that
+// tables referencing others may be listed after them).  A table listed several
+// times is emptied at the place of its first listing.
+func Truncate(tables ...string) error {
+	var ordered []string
+	listed := make(map[string]bool)
+	for _, table := range tables {
+		if !listed[table] {
+			ordered = append(ordered, table)
+			listed[table] = true
+		}
+	}
+
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if _, err := executor().Exec("DELETE FROM " + ordered[i]); err != nil {
+			return fmt.Errorf("db: failed to empty %s: %s", ordered[i], err)
+		}
+	}
+	return nil
+}
+
+// BeginTestTransaction begins the transaction used by all requests (through
+// Transactional) and fixtures until RollbackTestTransaction is called, so that
+// a test leaves the database as it found it.
+//
+// It is called before every test when db.test.transactional is set.
+func BeginTestTransaction() error {
+	if testTxn != nil {
+		return fmt.Errorf("db: a test transaction is already in progress")
+	}
+	txn, err := Db.Begin()
+	if err != nil {
+		return err
+	}
+	testTxn = txn
+	return nil
+}
+
+// RollbackTestTransaction rolls back the test transaction begun by
+// BeginTestTransaction, if any.
+func RollbackTestTransaction() error {
+	if testTxn == nil {
+		return nil
+	}
+	err := testTxn.Rollback()
+	testTxn = nil
+	return err
+}
+
+// Return the test transaction if there is one, or the database.
+func executor() interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+} {
+	if testTxn != nil {
+		return testTxn
+	}
+	return Db
+}
+
+// Return the paths of the named fixture files, or of all of them.
+func fixturePaths(names []string) ([]string, error) {
+	dir := filepath.Join(revel.BasePath, FixturesPath)
+	if len(names) == 0 {
+		var paths []string
+		for _, ext := range fixtureExtensions {
+			matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, matches...)
+		}
+		sort.Strings(paths)
+		return paths, nil
+	}
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		for _, ext := range fixtureExtensions {
+			path := filepath.Join(dir, name+ext)
+			if _, err := os.Stat(path); err == nil {
+				paths[i] = path
+				break
+			}
+		}
+		if paths[i] == "" {
+			return nil, fmt.Errorf("db: fixture %s not found in %s", name, dir)
+		}
+	}
+	return paths, nil
+}
+
+// Read the tables of a fixture file, in order.
+func readFixtureFile(path string) ([]fixtureTable, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []fixtureTable
+	if filepath.Ext(path) == ".json" {
+		tables, err = parseJsonFixtures(content)
+	} else {
+		tables, err = parseYamlFixtures(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("db: invalid fixture file %s: %s", path, err)
+	}
+	return tables, nil
+}
+
+func parseYamlFixtures(content []byte) ([]fixtureTable, error) {
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	var tables []fixtureTable
+	for _, item := range document {
+		table := fixtureTable{name: fmt.Sprint(item.Key)}
+		rows, ok := item.Value.([]interface{})
+		if !ok && item.Value != nil {
+			return nil, fmt.Errorf("the rows of %s are not a list", table.name)
+		}
+		for _, row := range rows {
+			columns := make(map[string]interface{})
+			switch row := row.(type) {
+			case yaml.MapSlice:
+				for _, column := range row {
+					columns[fmt.Sprint(column.Key)] = column.Value
+				}
+			case map[interface{}]interface{}:
+				for key, value := range row {
+					columns[fmt.Sprint(key)] = value
+				}
+			default:
+				return nil, fmt.Errorf("a row of %s is not a mapping of columns to values", table.name)
+			}
+			table.rows = append(table.rows, columns)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func parseJsonFixtures(content []byte) ([]fixtureTable, error) {
+	// Decode the top-level object key by key, to keep the order of the tables.
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object of tables")
+	}
+
+	var tables []fixtureTable
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		table := fixtureTable{name: token.(string)}
+		if err = decoder.Decode(&table.rows); err != nil {
+			return nil, fmt.Errorf("the rows of %s: %s", table.name, err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func insertRow(table string, row map[string]interface{}) error {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	placeholders := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		placeholders[i] = Placeholder(i + 1)
+		switch value := row[column].(type) {
+		case json.Number:
+			if n, err := value.Int64(); err == nil {
+				args[i] = n
+			} else {
+				args[i], _ = value.Float64()
+			}
+		case map[string]interface{}, map[interface{}]interface{}, yaml.MapSlice, []interface{}:
+			return fmt.Errorf("db: fixture value of %s.%s is not a scalar", table, column)
+		default:
+			args[i] = value
+		}
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if _, err := executor().Exec(query, args...); err != nil {
+		return fmt.Errorf("db: failed to insert fixture into %s: %s", table, err)
+	}
+	return nil
+}
+
+// Placeholder returns the placeholder of the n-th query argument (from 1) for
+// the driver, e.g. "?" or "$1".
+func Placeholder(n int) string {
+	switch Driver {
+	case "postgres", "pgx":
+		return fmt.Sprintf("$%d", n)
+	case "oci8", "goracle":
+		return fmt.Sprintf(":%d", n)
+	}
+	return "?"
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Make an in-memory SQLite database with users and their bookings the database
+// of the module, until the returned function is called.
+func useTestDb(t *testing.T) func() {
+	database, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.SetMaxOpenConns(1)
+	_, err = database.Exec(`PRAGMA foreign_keys = ON;
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, admin BOOLEAN, rating REAL);
+		CREATE TABLE bookings (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users (id));`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldDb, oldDriver := Db, Driver
+	Db, Driver = database, "sqlite3"
+	return func() {
+		Db, Driver = oldDb, oldDriver
+		database.Close()
+	}
+}
+
+func countRows(t *testing.T, db interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, table string) int {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestParseYamlFixtures(t *testing.T) {
+	tables, err := parseYamlFixtures([]byte(`
+users:
+  - id: 1
+    name: Alice
+    admin: true
+  - {id: 2, name: Bob, rating: 4.5}
+bookings:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fixtureTable{
+		{name: "users", rows: []map[string]interface{}{
+			{"id": 1, "name": "Alice", "admin": true},
+			{"id": 2, "name": "Bob", "rating": 4.5},
+		}},
+		{name: "bookings"},
+	}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Errorf("Expected %v, got %v", expected, tables)
+	}
+
+	for _, content := range []string{"users: Alice", "users:\n  - Alice", "- users"} {
+		if _, err = parseYamlFixtures([]byte(content)); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+func TestParseJsonFixtures(t *testing.T) {
+	tables, err := parseJsonFixtures([]byte(`{
+		"users": [{"id": 1, "name": "Alice", "rating": 4.5}],
+		"bookings": [{"id": 1, "user_id": 1}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fixtureTable{
+		{name: "users", rows: []map[string]interface{}{
+			{"id": json.Number("1"), "name": "Alice", "rating": json.Number("4.5")},
+		}},
+		{name: "bookings", rows: []map[string]interface{}{
+			{"id": json.Number("1"), "user_id": json.Number("1")},
+		}},
+	}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Errorf("Expected %v, got %v", expected, tables)
+	}
+
+	for _, content := range []string{`[]`, `{"users": {"id": 1}}`, `{"users": [1]}`} {
+		if _, err = parseJsonFixtures([]byte(content)); err == nil {
+			t.Errorf("Expected an error for %s", content)
+		}
+	}
+}
+
+func TestInsertRow(t *testing.T) {
+	defer useTestDb(t)()
+
+	err := insertRow("users", map[string]interface{}{"id": json.Number("1"), "name": "Alice", "rating": json.Number("4.5")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		name   string
+		rating float64
+	)
+	if err = Db.QueryRow("SELECT name, rating FROM users WHERE id = 1").Scan(&name, &rating); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Alice" || rating != 4.5 {
+		t.Errorf("Expected Alice rated 4.5, got %s rated %v", name, rating)
+	}
+
+	if err = insertRow("users", map[string]interface{}{"id": 2, "name": []interface{}{"Bob"}}); err == nil {
+		t.Error("Expected an error for a value that is not a scalar")
+	}
+	if err = insertRow("users", map[string]interface{}{"id": 1}); err == nil {
+		t.Error("Expected an error for a duplicate row")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	defer useTestDb(t)()
+	if _, err := Db.Exec("INSERT INTO users (id) VALUES (1); INSERT INTO bookings (id, user_id) VALUES (1, 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bookings reference the users, so must be emptied first, although the
+	// users are listed again after them.
+	if err := Truncate("users", "bookings", "users"); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, Db, "users") + countRows(t, Db, "bookings"); n != 0 {
+		t.Errorf("Expected no rows left, got %d", n)
+	}
+	if err := Truncate("hotels"); err == nil {
+		t.Error("Expected an error for a missing table")
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	defer useTestDb(t)()
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldBasePath := revel.BasePath
+	revel.BasePath = dir
+	defer func() { revel.BasePath = oldBasePath }()
+
+	fixtures := map[string]string{
+		"users.yml":     "users:\n  - {id: 1, name: Alice}\n  - {id: 2, name: Bob}\n",
+		"bookings.json": `{"users": [{"id": 3, "name": "Carol"}], "bookings": [{"id": 1, "user_id": 3}]}`,
+	}
+	if err = os.MkdirAll(filepath.Join(dir, FixturesPath), 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range fixtures {
+		if err = ioutil.WriteFile(filepath.Join(dir, FixturesPath, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = Db.Exec("INSERT INTO users (id, name) VALUES (9, 'Mallory')"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loading a fixture replaces the rows of its tables.
+	if err = LoadFixtures("users"); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, Db, "users"); n != 2 {
+		t.Errorf("Expected the 2 users of the fixture, got %d", n)
+	}
+
+	// All the fixtures are loaded, in alphabetical order: the users table, listed
+	// by both, is emptied before the bookings referencing it.
+	if err = LoadFixtures(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, Db, "users"); n != 3 {
+		t.Errorf("Expected the 3 users of the fixtures, got %d", n)
+	}
+	if n := countRows(t, Db, "bookings"); n != 1 {
+		t.Errorf("Expected 1 booking, got %d", n)
+	}
+
+	if err = LoadFixtures("hotels"); err == nil {
+		t.Error("Expected an error for a missing fixture")
+	}
+}
+
+func TestBeginTestTransaction(t *testing.T) {
+	defer useTestDb(t)()
+	if err := RollbackTestTransaction(); err != nil {
+		t.Errorf("Expected no error without a test transaction, got %s", err)
+	}
+
+	if err := BeginTestTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	if err := BeginTestTransaction(); err == nil {
+		t.Error("Expected an error when a test transaction is in progress")
+	}
+
+	// Fixtures go into the test transaction, and are gone once it is rolled back.
+	txn := testTxn
+	if err := insertRow("users", map[string]interface{}{"id": 1, "name": "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, txn, "users"); n != 1 {
+		t.Errorf("Expected 1 user in the test transaction, got %d", n)
+	}
+	if err := RollbackTestTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	if testTxn != nil {
+		t.Error("Expected no test transaction after the rollback")
+	}
+	if n := countRows(t, Db, "users"); n != 0 {
+		t.Errorf("Expected no users after the rollback, got %d", n)
+	}
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// The health of a database, as checked by Health.
+type HealthStatus struct {
+	Name    string        // The name of the database, "default" for Db.
+	Healthy bool          // The database answered the ping.
+	Error   string        // The error of the ping, if any.
+	Latency time.Duration // How long the ping took.
+
+	// The connections of the pool.
+	OpenConnections int
+	InUse           int
+	Idle            int
+}
+
+// Health pings the default database and the named ones, by name, each within
+// the timeout.  An app may serve it on a health endpoint, e.g.
+//
+//	func (c App) Health() revel.Result {
+//		statuses, healthy := db.Health(time.Second)
+//		if !healthy {
+//			c.Response.Status = http.StatusServiceUnavailable
+//		}
+//		return c.RenderJson(statuses)
+//	}
+func Health(timeout time.Duration) (statuses []HealthStatus, healthy bool) {
+	names := make([]string, 0, len(databases))
+	for name := range databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	healthy = true
+	if Db != nil {
+		statuses = append(statuses, ping("default", Db, timeout))
+	}
+	for _, name := range names {
+		statuses = append(statuses, ping(name, databases[name], timeout))
+	}
+	for _, status := range statuses {
+		healthy = healthy && status.Healthy
+	}
+	return statuses, healthy && Db != nil
+}
+
+// Ping a database within the timeout.
+func ping(name string, database *sql.DB, timeout time.Duration) HealthStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := database.PingContext(ctx)
+	stats := database.Stats()
+	status := HealthStatus{
+		Name:            name,
+		Healthy:         err == nil,
+		Latency:         time.Since(start),
+		OpenConnections: stats.OpenConnections,
+		InUse:           stats.InUse,
+		Idle:            stats.Idle,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
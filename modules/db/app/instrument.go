@@ -0,0 +1,367 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"time"
+)
+
+// The databases are opened through a wrapper of their driver, which records
+// the statements in the query log of their request.  (See QueryLog)
+
+// Open an instrumented database, given the driver name and spec of sql.Open.
+func openInstrumented(driverName, spec string) (*sql.DB, error) {
+	// Find the driver by opening the database, which does not connect.
+	raw, err := sql.Open(driverName, spec)
+	if err != nil {
+		return nil, err
+	}
+	d := raw.Driver()
+	raw.Close()
+
+	var connector driver.Connector = dsnConnector{d, spec}
+	if driverContext, ok := d.(driver.DriverContext); ok {
+		if connector, err = driverContext.OpenConnector(spec); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(instrumentedConnector{connector}), nil
+}
+
+// A connector of a driver without its own.
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+type instrumentedConnector struct {
+	driver.Connector
+}
+
+func (c instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{conn: conn}, nil
+}
+
+// A connection, which records its statements in the log of the context, or of
+// its transaction.
+type instrumentedConn struct {
+	conn  driver.Conn
+	txLog *QueryLog // The log of the transaction in progress, if any.
+}
+
+// Return the log of a statement.
+func (c *instrumentedConn) log(ctx context.Context) *QueryLog {
+	if log := queryLogFrom(ctx); log != nil {
+		return log
+	}
+	return c.txLog
+}
+
+func (c *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{stmt, c, query}, nil
+}
+
+func (c *instrumentedConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *instrumentedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if beginner, ok := c.conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("db: the driver does not support transaction options")
+	} else {
+		tx, err = c.conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.txLog = queryLogFrom(ctx)
+	return instrumentedTx{tx, c}, nil
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	recordExec(c.log(ctx), query, args, start, result, err)
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	return recordRows(c.log(ctx), query, args, start, rows, err)
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *instrumentedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type instrumentedTx struct {
+	tx   driver.Tx
+	conn *instrumentedConn
+}
+
+func (t instrumentedTx) Commit() error {
+	t.conn.txLog = nil
+	return t.tx.Commit()
+}
+
+func (t instrumentedTx) Rollback() error {
+	t.conn.txLog = nil
+	return t.tx.Rollback()
+}
+
+type instrumentedStmt struct {
+	stmt  driver.Stmt
+	conn  *instrumentedConn
+	query string
+}
+
+func (s *instrumentedStmt) Close() error  { return s.stmt.Close() }
+func (s *instrumentedStmt) NumInput() int { return s.stmt.NumInput() }
+
+func (s *instrumentedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *instrumentedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		result driver.Result
+		err    error
+	)
+	if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = s.stmt.Exec(driverValues(args))
+	}
+	recordExec(s.conn.log(ctx), s.query, args, start, result, err)
+	return result, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.stmt.Query(driverValues(args))
+	}
+	return recordRows(s.conn.log(ctx), s.query, args, start, rows, err)
+}
+
+func (s *instrumentedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return s.conn.CheckNamedValue(value)
+}
+
+// Rows which record their statement when they are closed, with the number of
+// rows read.
+type instrumentedRows struct {
+	driver.Rows
+	log   *QueryLog
+	query Query
+}
+
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.query.Rows++
+	} else if err != io.EOF && r.query.Error == "" {
+		r.query.Error = err.Error()
+	}
+	return err
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	r.query.Duration = time.Since(r.query.Start)
+	recordQuery(r.log, r.query)
+	return err
+}
+
+func (r *instrumentedRows) HasNextResultSet() bool {
+	next, ok := r.Rows.(driver.RowsNextResultSet)
+	return ok && next.HasNextResultSet()
+}
+
+func (r *instrumentedRows) NextResultSet() error {
+	if next, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return next.NextResultSet()
+	}
+	return io.EOF
+}
+
+// The types of the columns, given by the driver, or else the defaults of
+// database/sql.
+
+func (r *instrumentedRows) ColumnTypeScanType(index int) reflect.Type {
+	if types, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return types.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *instrumentedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if types, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return types.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *instrumentedRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if types, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return types.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *instrumentedRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if types, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return types.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *instrumentedRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if types, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return types.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+// Record an executed statement.
+func recordExec(log *QueryLog, sql string, args []driver.NamedValue, start time.Time, result driver.Result, err error) {
+	query := newQuery(sql, args, start)
+	query.Duration = time.Since(start)
+	if err != nil {
+		query.Error = err.Error()
+	} else if affected, err := result.RowsAffected(); err == nil {
+		query.Rows = affected
+	}
+	recordQuery(log, query)
+}
+
+// Return the rows of a query, which record it when closed, or record its error.
+func recordRows(log *QueryLog, sql string, args []driver.NamedValue, start time.Time, rows driver.Rows, err error) (driver.Rows, error) {
+	query := newQuery(sql, args, start)
+	if err != nil {
+		query.Duration = time.Since(start)
+		query.Error = err.Error()
+		recordQuery(log, query)
+		return nil, err
+	}
+	query.Rows = 0
+	return &instrumentedRows{rows, log, query}, nil
+}
+
+func newQuery(sql string, args []driver.NamedValue, start time.Time) Query {
+	return Query{SQL: sql, Args: formatArgs(values(args)), Start: start, Rows: -1}
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+func values(args []driver.NamedValue) []interface{} {
+	vals := make([]interface{}, len(args))
+	for i, arg := range args {
+		vals[i] = arg.Value
+	}
+	return vals
+}
+
+func driverValues(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vals[i] = arg.Value
+	}
+	return vals
+}
@@ -0,0 +1,124 @@
+// Package migrate reads and creates the migration files of the db module.  It
+// has no dependency, so that the revel command does not import the db module,
+// with its database drivers, to create a migration or to run one.
+package migrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is the directory holding the migrations, relative to the application
+// base path.
+//
+// A migration is a pair of SQL files, named after its version and name, e.g.
+//
+//	db/migrations/001_create_users.up.sql
+//	db/migrations/001_create_users.down.sql
+//
+// The up file applies the migration, and the optional down file reverts it.
+var Path = filepath.Join("db", "migrations")
+
+// Env is the environment variable which makes db.Init run a migration command,
+// and exit, as set by "revel migrate", e.g. "up", "down 2" or "status".
+const Env = "REVEL_DB_MIGRATE"
+
+// The name of a migration file: version, name and direction.
+var fileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// A Migration is a version of the schema, read from its files.
+type Migration struct {
+	Version  int64
+	Name     string
+	UpPath   string
+	DownPath string // The path of the down file, or "" if there is none.
+}
+
+// Read returns the migrations of the directory, by version.  A missing
+// directory has none.
+func Read(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		match := fileRegexp.FindStringSubmatch(file.Name())
+		if file.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("db: invalid migration version %s: %s", file.Name(), err)
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("db: migrations %s and %s have the same version",
+				migration.Name, file.Name())
+		}
+		path := filepath.Join(dir, file.Name())
+		if match[3] == "up" {
+			migration.UpPath = path
+		} else {
+			migration.DownPath = path
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpPath == "" {
+			return nil, fmt.Errorf("db: migration %03d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Sort(byVersionOrder(migrations))
+	return migrations, nil
+}
+
+type byVersionOrder []*Migration
+
+func (m byVersionOrder) Len() int           { return len(m) }
+func (m byVersionOrder) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byVersionOrder) Less(i, j int) bool { return m[i].Version < m[j].Version }
+
+// Create creates the empty up and down files of a new migration in the
+// directory, with the version after the last one, and returns their paths.
+func Create(dir, name string) (upPath, downPath string, err error) {
+	name = strings.Trim(regexp.MustCompile(`\W+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("db: invalid migration name")
+	}
+	migrations, err := Read(dir)
+	if err != nil {
+		return "", "", err
+	}
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%03d_%s", version, name))
+	upPath, downPath = base+".up.sql", base+".down.sql"
+	if err = ioutil.WriteFile(upPath, []byte("-- Apply "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err = ioutil.WriteFile(downPath, []byte("-- Revert "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
@@ -0,0 +1,318 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/BSP-Mosaic/teltech-revel/modules/db/app/migrate"
+)
+
+// A Migration is a version of the schema, read from its files.
+type Migration = migrate.Migration
+
+// The status of a migration.
+type MigrationStatus struct {
+	*Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// A Migrator applies and reverts the migrations of a directory, recording the
+// applied versions in a table, which it creates if needed.
+//
+// The migrations are the pairs of SQL files of the migrate.Path directory of the
+// app, named after their version and name.  (See the migrate package)  Each file
+// is executed as a whole, in a transaction with the update of the schema
+// version table: the driver must accept several statements in one Exec (e.g.
+// with multiStatements=true for MySQL).
+//
+// The pending migrations are applied by Init when db.migrate is set, and by the
+// "revel migrate" command:
+//
+//	db.migrate       = true               (apply the pending migrations on startup)
+//	db.migrate.table = schema_migrations  (the table of the applied versions)
+//
+// On startup, the instances of the app take an advisory lock of the database
+// while they apply the migrations, so that those started together apply each
+// once: with PostgreSQL and MySQL only.  With the other drivers, db.migrate must
+// only be set on one instance, or the migrations applied by "revel migrate".
+type Migrator struct {
+	Db    *sql.DB
+	Dir   string // The directory of the migrations.
+	Table string // The schema version table.
+}
+
+// NewMigrator returns a Migrator of the migrations of the app, on db.Db.
+func NewMigrator() *Migrator {
+	return &Migrator{
+		Db:    Db,
+		Dir:   filepath.Join(revel.BasePath, migrate.Path),
+		Table: revel.Config.StringDefault("db.migrate.table", "schema_migrations"),
+	}
+}
+
+// Migrations returns the migrations of the directory, by version.
+func (m *Migrator) Migrations() ([]*Migration, error) {
+	return migrate.Read(m.Dir)
+}
+
+// Status returns the migrations, by version, and whether they are applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = MigrationStatus{migration, ok, appliedAt}
+	}
+	return statuses, nil
+}
+
+// Up applies the first n pending migrations, or all if n <= 0, in the order
+// of their versions.  It returns the applied migrations.
+func (m *Migrator) Up(n int) ([]*Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		if n > 0 && len(done) == n {
+			break
+		}
+		if err = m.run(status.Migration, status.UpPath, true); err != nil {
+			return done, err
+		}
+		done = append(done, status.Migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last n applied migrations, or the last one if n <= 0, in
+// the reverse order of their versions.  It returns the reverted migrations.
+func (m *Migrator) Down(n int) ([]*Migration, error) {
+	if n <= 0 {
+		n = 1
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for i := len(statuses) - 1; i >= 0 && len(done) < n; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		if status.DownPath == "" {
+			return done, fmt.Errorf("db: migration %03d_%s has no down file", status.Version, status.Name)
+		}
+		if err = m.run(status.Migration, status.DownPath, false); err != nil {
+			return done, err
+		}
+		done = append(done, status.Migration)
+	}
+	return done, nil
+}
+
+// Run a migration file, and record its version as applied (up) or not (down),
+// in a transaction.
+func (m *Migrator) run(migration *Migration, path string, up bool) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	txn, err := m.Db.Begin()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(content)) != "" {
+		if _, err = txn.Exec(string(content)); err != nil {
+			txn.Rollback()
+			return fmt.Errorf("db: migration %s failed: %s", filepath.Base(path), err)
+		}
+	}
+	if up {
+		_, err = txn.Exec(fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)",
+			m.Table, Placeholder(1), Placeholder(2), Placeholder(3)),
+			migration.Version, migration.Name, time.Now().Unix())
+	} else {
+		_, err = txn.Exec(fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.Table, Placeholder(1)),
+			migration.Version)
+	}
+	if err != nil {
+		txn.Rollback()
+		return fmt.Errorf("db: failed to update %s: %s", m.Table, err)
+	}
+	return txn.Commit()
+}
+
+const migrationsTableSchema = `CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at BIGINT NOT NULL
+)`
+
+// Return the applied versions, and when they were applied.
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	if m.Db == nil {
+		return nil, fmt.Errorf("db: the database is not open (db.Init)")
+	}
+	if _, err := m.Db.Exec(fmt.Sprintf(migrationsTableSchema, m.Table)); err != nil {
+		return nil, fmt.Errorf("db: failed to create the table %s: %s", m.Table, err)
+	}
+
+	rows, err := m.Db.Query(fmt.Sprintf("SELECT version, applied_at FROM %s", m.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version, appliedAt int64
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = time.Unix(appliedAt, 0)
+	}
+	return applied, rows.Err()
+}
+
+// Apply the pending migrations if db.migrate is set, or run the migration
+// command of "revel migrate" and exit.
+func migrateOnStartup() {
+	if command := os.Getenv(migrate.Env); command != "" {
+		if err := runMigrateCommand(NewMigrator(), strings.Fields(command)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if revel.Config.BoolDefault("db.migrate", false) {
+		m := NewMigrator()
+		unlock, err := lockMigrations(m.Db, m.Table)
+		if err != nil {
+			glog.Fatal("db: failed to lock the migrations: ", err)
+		}
+		applied, err := m.Up(0)
+		unlock()
+		if err != nil {
+			glog.Fatal(err)
+		}
+		for _, migration := range applied {
+			glog.Infof("db: applied migration %03d_%s", migration.Version, migration.Name)
+		}
+	}
+}
+
+// Take the advisory lock of the migrations on a connection of the database, for
+// the drivers which have one, until the returned function is called.
+func lockMigrations(database *sql.DB, table string) (unlock func(), err error) {
+	var (
+		lock, release string
+		key           interface{} = "revel." + table
+	)
+	switch Driver {
+	case "postgres", "pgx":
+		// The keys of the advisory locks are numbers.
+		hash := fnv.New64a()
+		hash.Write([]byte("revel." + table))
+		lock, release, key = "SELECT pg_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", int64(hash.Sum64())
+	case "mysql":
+		lock, release = "SELECT GET_LOCK(?, -1)", "SELECT RELEASE_LOCK(?)"
+	default:
+		return func() {}, nil
+	}
+	if database == nil {
+		return nil, fmt.Errorf("db: the database is not open (db.Init)")
+	}
+
+	ctx := context.Background()
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = conn.ExecContext(ctx, lock, key); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() {
+		if _, err := conn.ExecContext(ctx, release, key); err != nil {
+			glog.Error("db: failed to unlock the migrations: ", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// Run a migration command: up [n], down [n] or status.
+func runMigrateCommand(m *Migrator, args []string) error {
+	n := 0
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("db: invalid number of migrations %s", args[1])
+		}
+	}
+
+	var (
+		done []*Migration
+		err  error
+		verb string
+	)
+	switch args[0] {
+	case "up":
+		done, err = m.Up(n)
+		verb = "Applied"
+	case "down":
+		done, err = m.Down(n)
+		verb = "Reverted"
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(table, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(table, "%03d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return table.Flush()
+	default:
+		return fmt.Errorf("db: unknown migration command %s", args[0])
+	}
+
+	for _, migration := range done {
+		fmt.Printf("%s %03d_%s\n", verb, migration.Version, migration.Name)
+	}
+	if err == nil && len(done) == 0 {
+		fmt.Println("No migration to run.")
+	}
+	return err
+}
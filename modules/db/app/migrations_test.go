@@ -0,0 +1,152 @@
+package db
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BSP-Mosaic/teltech-revel/modules/db/app/migrate"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Return a Migrator of an in-memory SQLite database, with the given migration
+// files (name => content) in a temporary directory.
+func newTestMigrator(t *testing.T, files map[string]string) *Migrator {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	database, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each connection has its own in-memory database.
+	database.SetMaxOpenConns(1)
+	return &Migrator{Db: database, Dir: dir, Table: "schema_migrations"}
+}
+
+func tableExists(t *testing.T, m *Migrator, table string) bool {
+	var n int
+	err := m.Db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+var testMigrations = map[string]string{
+	"001_create_users.up.sql":    "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);",
+	"001_create_users.down.sql":  "DROP TABLE users;",
+	"002_create_hotels.up.sql":   "CREATE TABLE hotels (id INTEGER PRIMARY KEY); CREATE TABLE rooms (id INTEGER PRIMARY KEY);",
+	"002_create_hotels.down.sql": "DROP TABLE rooms; DROP TABLE hotels;",
+	"README":                     "Not a migration.",
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	m := newTestMigrator(t, testMigrations)
+	defer os.RemoveAll(m.Dir)
+
+	applied, err := m.Up(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0].Version != 1 || applied[0].Name != "create_users" {
+		t.Errorf("Expected to apply 001_create_users, got %v", applied)
+	}
+	if !tableExists(t, m, "users") || tableExists(t, m, "hotels") {
+		t.Errorf("Expected only the users table")
+	}
+
+	if applied, err = m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("Expected to apply 002_create_hotels, got %v", applied)
+	}
+	if !tableExists(t, m, "hotels") || !tableExists(t, m, "rooms") {
+		t.Errorf("Expected the hotels and rooms tables")
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || !statuses[0].Applied || !statuses[1].Applied {
+		t.Errorf("Expected 2 applied migrations, got %v", statuses)
+	}
+
+	reverted, err := m.Down(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Errorf("Expected to revert 002_create_hotels, got %v", reverted)
+	}
+	if tableExists(t, m, "hotels") || !tableExists(t, m, "users") {
+		t.Errorf("Expected only the users table")
+	}
+
+	if statuses, err = m.Status(); err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("Expected 001 applied and 002 pending, got %v", statuses)
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	m := newTestMigrator(t, map[string]string{
+		"001_broken.up.sql": "CREATE TABLE broken (id INTEGER); NOT SQL;",
+	})
+	defer os.RemoveAll(m.Dir)
+
+	if _, err := m.Up(0); err == nil {
+		t.Fatal("Expected the migration to fail")
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].Applied {
+		t.Errorf("Expected the failed migration to be pending")
+	}
+}
+
+func TestMigrationWithoutUpFile(t *testing.T) {
+	m := newTestMigrator(t, map[string]string{
+		"001_orphan.down.sql": "DROP TABLE orphan;",
+	})
+	defer os.RemoveAll(m.Dir)
+
+	if _, err := m.Migrations(); err == nil {
+		t.Error("Expected an error for a migration without up file")
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	m := newTestMigrator(t, testMigrations)
+	defer os.RemoveAll(m.Dir)
+
+	upPath, downPath, err := migrate.Create(m.Dir, "Add Bookings!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(upPath) != "003_add_bookings.up.sql" || filepath.Base(downPath) != "003_add_bookings.down.sql" {
+		t.Errorf("Unexpected migration files %s, %s", upPath, downPath)
+	}
+
+	migrations, err := m.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 3 || migrations[2].Name != "add_bookings" {
+		t.Errorf("Expected 3 migrations, got %v", migrations)
+	}
+}
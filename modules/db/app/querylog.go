@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// The statements executed on the databases are recorded in the query log of
+// the request which executed them: those of the transaction of a Transactional
+// controller, and those given the context of the request (see Context).  The
+// log is shown on the error page in dev mode, and the logs of the last requests
+// on the /@db/queries page, if the db module is loaded (module.db) along with
+// its routes (module:db).
+//
+// The arguments of the statements are redacted, except for numbers, booleans,
+// times and NULL.  The statements slower than a threshold are logged:
+//
+//	db.slowquery       = 100ms  (logged as a warning, never if 0)
+//	db.slowquery.error = 1s     (logged as an error, never if 0)
+//	db.querylog.redact = true   (redact the arguments)
+//	db.querylog.keep   = 50     (the number of request logs kept, in dev mode)
+
+// A statement executed on a database.
+type Query struct {
+	SQL      string
+	Args     []string // The arguments, redacted.
+	Start    time.Time
+	Duration time.Duration // Including the reading of the rows.
+	Rows     int64         // The rows affected or read, or -1 if unknown.
+	Error    string
+}
+
+// The statements executed for a request.
+type QueryLog struct {
+	RequestId string
+	Method    string
+	URL       string
+	Start     time.Time
+
+	mutex   sync.Mutex
+	queries []Query
+}
+
+const (
+	DEFAULT_SLOW_QUERY       = 100 * time.Millisecond
+	DEFAULT_SLOW_QUERY_ERROR = time.Second
+	DEFAULT_QUERY_LOG_KEEP   = 50
+)
+
+var (
+	slowQuery      = DEFAULT_SLOW_QUERY
+	slowQueryError = DEFAULT_SLOW_QUERY_ERROR
+	redactArgs     = true
+
+	// The logs of the last requests, newest first, in dev mode.
+	recentLogs      []*QueryLog
+	recentLogsMutex sync.Mutex
+	recentLogsKeep  = DEFAULT_QUERY_LOG_KEEP
+)
+
+// The key of the query log in the Args of the controller, and in the context.
+const queryLogKey = "db.querylog"
+
+type queryLogContextKey struct{}
+
+// Read the query log configuration.
+func configureQueryLog() {
+	slowQuery = configDuration("db.slowquery", DEFAULT_SLOW_QUERY)
+	slowQueryError = configDuration("db.slowquery.error", DEFAULT_SLOW_QUERY_ERROR)
+	redactArgs = revel.Config.BoolDefault("db.querylog.redact", true)
+	recentLogsKeep = revel.Config.IntDefault("db.querylog.keep", DEFAULT_QUERY_LOG_KEEP)
+}
+
+// QueryLogOf returns the query log of the request, creating it if needed.
+func QueryLogOf(c *revel.Controller) *QueryLog {
+	if log, ok := c.Args[queryLogKey].(*QueryLog); ok {
+		return log
+	}
+	log := &QueryLog{Start: time.Now()}
+	if c.Request != nil && c.Request.Request != nil {
+		log.RequestId, log.Method, log.URL = c.Request.Id, c.Request.Method, c.Request.URL.String()
+	}
+	c.Args[queryLogKey] = log
+
+	if revel.DevMode && recentLogsKeep > 0 {
+		recentLogsMutex.Lock()
+		recentLogs = append([]*QueryLog{log}, recentLogs...)
+		if len(recentLogs) > recentLogsKeep {
+			recentLogs = recentLogs[:recentLogsKeep]
+		}
+		recentLogsMutex.Unlock()
+	}
+	return log
+}
+
+// Context returns a context which ties the statements executed with it to the
+// request, e.g.
+//
+//	rows, err := db.Db.QueryContext(db.Context(c.Controller), "SELECT ...")
+//
+// It is not cancelled with the request.
+func Context(c *revel.Controller) context.Context {
+	return context.WithValue(context.Background(), queryLogContextKey{}, QueryLogOf(c))
+}
+
+// Return the query log of the context, or nil.
+func queryLogFrom(ctx context.Context) *QueryLog {
+	log, _ := ctx.Value(queryLogContextKey{}).(*QueryLog)
+	return log
+}
+
+// Queries returns the statements executed for the request so far.
+func (l *QueryLog) Queries() []Query {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]Query(nil), l.queries...)
+}
+
+// Summary returns the number of statements, and the time spent on them.
+func (l *QueryLog) Summary() string {
+	var (
+		total time.Duration
+		slow  int
+	)
+	queries := l.Queries()
+	for _, query := range queries {
+		total += query.Duration
+		if slowQuery > 0 && query.Duration >= slowQuery {
+			slow++
+		}
+	}
+	return fmt.Sprintf("%d queries in %s, %d slow", len(queries), total, slow)
+}
+
+// Record a statement in the log of its request, if any, and log it if it is
+// slow.
+func recordQuery(log *QueryLog, query Query) {
+	if log != nil {
+		log.mutex.Lock()
+		log.queries = append(log.queries, query)
+		log.mutex.Unlock()
+	}
+
+	requestId := "-"
+	if log != nil {
+		requestId = log.RequestId
+	}
+	switch {
+	case slowQueryError > 0 && query.Duration >= slowQueryError:
+		glog.Errorf("db: [%s] slow query (%s): %s %v", requestId, query.Duration, query.SQL, query.Args)
+	case slowQuery > 0 && query.Duration >= slowQuery:
+		glog.Warningf("db: [%s] slow query (%s): %s %v", requestId, query.Duration, query.SQL, query.Args)
+	default:
+		glog.V(2).Infof("db: [%s] query (%s): %s %v", requestId, query.Duration, query.SQL, query.Args)
+	}
+}
+
+// Return the arguments of a statement, redacted.
+func formatArgs(args []interface{}) []string {
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case nil:
+			formatted[i] = "NULL"
+		case int64, float64, bool:
+			formatted[i] = fmt.Sprint(arg)
+		case time.Time:
+			formatted[i] = arg.Format(time.RFC3339Nano)
+		default:
+			if redactArgs {
+				formatted[i] = "[redacted]"
+			} else {
+				formatted[i] = fmt.Sprintf("%q", fmt.Sprint(arg))
+			}
+		}
+	}
+	return formatted
+}
+
+// Return the debug panel of the queries of a request, for the error page.
+func queryLogPanel(c *revel.Controller) *revel.DebugPanel {
+	log, ok := c.Args[queryLogKey].(*QueryLog)
+	if !ok {
+		return nil
+	}
+	panel := &revel.DebugPanel{
+		Title:   "Queries",
+		Summary: log.Summary(),
+		Columns: []string{"Duration", "Rows", "Query", "Args", "Error"},
+	}
+	for _, query := range log.Queries() {
+		panel.Rows = append(panel.Rows, []string{
+			query.Duration.String(), fmt.Sprint(query.Rows), query.SQL,
+			strings.Join(query.Args, ", "), query.Error,
+		})
+	}
+	return panel
+}
+
+func init() {
+	revel.DebugPanels = append(revel.DebugPanels, queryLogPanel)
+}
+
+// DbQueries shows the query logs of the last requests, in dev mode.
+type DbQueries struct {
+	*revel.Controller
+}
+
+func (c DbQueries) Index() revel.Result {
+	if !revel.DevMode {
+		return c.NotFound("The query logs are only kept in dev mode")
+	}
+	recentLogsMutex.Lock()
+	logs := append([]*QueryLog(nil), recentLogs...)
+	recentLogsMutex.Unlock()
+	if c.Request.Format == "json" {
+		type requestQueries struct {
+			RequestId, Method, URL string
+			Start                  time.Time
+			Queries                []Query
+		}
+		var requests []requestQueries
+		for _, log := range logs {
+			requests = append(requests, requestQueries{log.RequestId, log.Method, log.URL, log.Start, log.Queries()})
+		}
+		return c.RenderJson(requests)
+	}
+	return c.Render(logs)
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestController(t *testing.T) *revel.Controller {
+	req, err := http.NewRequest("GET", "/hotels", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Request-Id", "req-1")
+	return revel.NewController(revel.NewRequest(req), nil, nil)
+}
+
+func TestQueryLog(t *testing.T) {
+	database, err := openInstrumented("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.SetMaxOpenConns(1)
+	defer database.Close()
+	if _, err = database.Exec("CREATE TABLE hotels (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The statements of the transaction are recorded in the log of the request.
+	c := newTestController(t)
+	txn, err := database.BeginTx(Context(c), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = txn.Exec("INSERT INTO hotels (id, name) VALUES (?, ?), (?, ?)", 1, "Ritz", 2, "Savoy"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := txn.Query("SELECT id FROM hotels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if err = txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not the statements after the transaction.
+	if _, err = database.Exec("DELETE FROM hotels"); err != nil {
+		t.Fatal(err)
+	}
+
+	log := QueryLogOf(c)
+	if log.RequestId != "req-1" {
+		t.Errorf("Expected the request id req-1, got %s", log.RequestId)
+	}
+	queries := log.Queries()
+	if len(queries) != 2 {
+		t.Fatalf("Expected 2 queries, got %v", queries)
+	}
+	if queries[0].Rows != 2 || queries[1].Rows != 2 {
+		t.Errorf("Expected 2 rows inserted and read, got %d and %d", queries[0].Rows, queries[1].Rows)
+	}
+	if args := queries[0].Args; len(args) != 4 || args[0] != "1" || args[1] != "[redacted]" {
+		t.Errorf("Expected the redacted arguments, got %v", args)
+	}
+}
+
+// The description of a column type.
+type columnType struct {
+	Name, DatabaseTypeName string
+	ScanType               reflect.Type
+	Nullable, HasNullable  bool
+	Length                 int64
+	HasLength              bool
+	Precision, Scale       int64
+	HasDecimalSize         bool
+}
+
+// Return the types of the columns of the hotels, in the given database.
+func hotelColumnTypes(t *testing.T, database *sql.DB) []columnType {
+	_, err := database.Exec("CREATE TABLE hotels (id INTEGER PRIMARY KEY, name VARCHAR(40) NOT NULL, price DECIMAL(8, 2))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = database.Exec("INSERT INTO hotels (id, name, price) VALUES (1, 'Ritz', 300)"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := database.Query("SELECT id, name, price FROM hotels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var columns []columnType
+	for _, column := range types {
+		c := columnType{Name: column.Name(), DatabaseTypeName: column.DatabaseTypeName(), ScanType: column.ScanType()}
+		c.Nullable, c.HasNullable = column.Nullable()
+		c.Length, c.HasLength = column.Length()
+		c.Precision, c.Scale, c.HasDecimalSize = column.DecimalSize()
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+func TestColumnTypes(t *testing.T) {
+	database, err := openInstrumented("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.SetMaxOpenConns(1)
+	defer database.Close()
+	raw, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.SetMaxOpenConns(1)
+	defer raw.Close()
+
+	// The column types are those of the driver.
+	columns, expected := hotelColumnTypes(t, database), hotelColumnTypes(t, raw)
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected the column types %+v, got %+v", expected, columns)
+	}
+	if len(columns) != 3 || columns[0].DatabaseTypeName != "INTEGER" || columns[1].DatabaseTypeName != "VARCHAR(40)" {
+		t.Errorf("Expected the database types of the columns, got %+v", columns)
+	}
+}
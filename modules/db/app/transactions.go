@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// TransactionConfigurator configures the transaction of the actions of a
+// Transactional controller, on a per-controller or per-action basis, like the
+// FilterConfigurator of the filters.  By default, each action runs in a
+// read-write transaction with the default isolation level of the database.
+// For example:
+//
+//	db.TransactionController(Hotels{}).
+//		ReadOnly()
+//	db.TransactionAction(Hotels.Book).
+//		Isolation(sql.LevelSerializable)
+//	db.TransactionAction(Hotels.Search).
+//		None()
+//
+// The configuration of an action overrides the one of its controller.
+type TransactionConfigurator struct {
+	key string // e.g. "Hotels", "Hotels.Book"
+}
+
+// The transaction of an action.
+type transactionConfig struct {
+	none      bool // No transaction is begun.
+	readOnly  bool // The transaction is read-only, on a read replica if any.
+	isolation sql.IsolationLevel
+}
+
+var (
+	// The transaction configurations, by "Controller" or "Controller.Action".
+	transactionConfigs      = make(map[string]transactionConfig)
+	transactionConfigsMutex sync.RWMutex
+
+	// The number of savepoints, to name them.
+	savepoints uint64
+)
+
+// TransactionController returns a configurator for the transactions of all the
+// actions of the given controller instance.  For example:
+//
+//	db.TransactionController(Hotels{})
+func TransactionController(controllerInstance interface{}) TransactionConfigurator {
+	t := reflect.TypeOf(controllerInstance)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return TransactionConfigurator{t.Name()}
+}
+
+// TransactionAction returns a configurator for the transaction of the given
+// controller method.  For example:
+//
+//	db.TransactionAction(Hotels.Book)
+func TransactionAction(methodRef interface{}) TransactionConfigurator {
+	return TransactionConfigurator{actionName(methodRef)}
+}
+
+// None runs the actions without transaction: Txn is nil.
+func (conf TransactionConfigurator) None() TransactionConfigurator {
+	conf.apply(func(config *transactionConfig) { config.none = true })
+	return conf
+}
+
+// ReadOnly runs the actions in a read-only transaction, on one of the read
+// replicas if any (see db.replicas).
+func (conf TransactionConfigurator) ReadOnly() TransactionConfigurator {
+	conf.apply(func(config *transactionConfig) { config.none, config.readOnly = false, true })
+	return conf
+}
+
+// Isolation runs the actions in a transaction with the given isolation level.
+func (conf TransactionConfigurator) Isolation(level sql.IsolationLevel) TransactionConfigurator {
+	conf.apply(func(config *transactionConfig) { config.none, config.isolation = false, level })
+	return conf
+}
+
+// Update the configuration of the controller or action.
+func (conf TransactionConfigurator) apply(f func(*transactionConfig)) {
+	transactionConfigsMutex.Lock()
+	defer transactionConfigsMutex.Unlock()
+	config := transactionConfigs[conf.key]
+	f(&config)
+	transactionConfigs[conf.key] = config
+}
+
+// ReadOnly declares the given actions of Transactional controllers as
+// read-only.  It is short for TransactionAction(action).ReadOnly().
+// For example:
+//
+//	db.ReadOnly(Hotels.Index, Hotels.Show)
+func ReadOnly(actions ...interface{}) {
+	for _, action := range actions {
+		TransactionAction(action).ReadOnly()
+	}
+}
+
+// Return the transaction configuration of the action of the controller.
+func transactionConfigFor(controllerName, methodName string) transactionConfig {
+	transactionConfigsMutex.RLock()
+	defer transactionConfigsMutex.RUnlock()
+	if config, ok := transactionConfigs[controllerName+"."+methodName]; ok {
+		return config
+	}
+	return transactionConfigs[controllerName]
+}
+
+// Begin the transaction of an action, or return nil if it has none.
+func beginTransaction(c *revel.Controller) (*sql.Tx, error) {
+	config := transactionConfigFor(c.Name, c.MethodType.Name)
+	if config.none {
+		return nil, nil
+	}
+	database := Db
+	if config.readOnly {
+		if r := replica(); r != nil {
+			database = r
+		}
+	}
+	return database.BeginTx(Context(c), &sql.TxOptions{
+		Isolation: config.isolation,
+		ReadOnly:  config.readOnly,
+	})
+}
+
+// Return whether the result of the action is an error: a status of 400 or
+// above, or an error page.
+func failed(c *revel.Controller) bool {
+	if c.Response.Status >= 400 {
+		return true
+	}
+	_, isError := c.Result.(revel.ErrorResult)
+	return isError
+}
+
+// Return the name of an action method ("Controller.Action"), given a reference
+// to it (e.g. Hotels.Show).
+func actionName(methodRef interface{}) string {
+	methodValue := reflect.ValueOf(methodRef)
+	methodType := methodValue.Type()
+	if methodType.Kind() != reflect.Func || methodType.NumIn() == 0 {
+		panic("Expecting a controller method reference (e.g. Controller.Action), got a " +
+			methodType.String())
+	}
+	controllerType := methodType.In(0)
+	method := revel.FindMethod(controllerType, methodValue)
+	if method == nil {
+		panic("Action not found on controller " + controllerType.Name())
+	}
+	for controllerType.Kind() == reflect.Ptr {
+		controllerType = controllerType.Elem()
+	}
+	return controllerType.Name() + "." + method.Name
+}
+
+// Savepoint runs f within a savepoint of the transaction, so that a helper
+// may undo its own changes without rolling back the whole transaction: the
+// changes of f are rolled back if it returns an error or panics.  Savepoints
+// may be nested.  For example:
+//
+//	err := db.Savepoint(c.Txn, func() error {
+//		return reserveRoom(c.Txn, booking)
+//	})
+func Savepoint(txn *sql.Tx, f func() error) (err error) {
+	if txn == nil {
+		return fmt.Errorf("db: no transaction for the savepoint")
+	}
+	name := fmt.Sprintf("revel_sp_%d", atomic.AddUint64(&savepoints, 1))
+	if _, err = txn.Exec("SAVEPOINT " + name); err != nil {
+		return err
+	}
+
+	released := false
+	defer func() {
+		if released {
+			return
+		}
+		// Roll back to the savepoint on error or panic.
+		if _, rollbackErr := txn.Exec("ROLLBACK TO SAVEPOINT " + name); rollbackErr != nil && err == nil {
+			err = rollbackErr
+		}
+	}()
+
+	if err = f(); err != nil {
+		return err
+	}
+	if _, err = txn.Exec("RELEASE SAVEPOINT " + name); err != nil {
+		return err
+	}
+	released = true
+	return nil
+}
+
+// Savepoint runs f within a savepoint of the transaction of the action.
+// (See Savepoint)
+func (c *Transactional) Savepoint(f func() error) error {
+	return Savepoint(c.Txn, f)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSavepoint(t *testing.T) {
+	database, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.SetMaxOpenConns(1)
+	defer database.Close()
+	if _, err = database.Exec("CREATE TABLE rooms (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err := database.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert := func(id int) error {
+		_, err := txn.Exec("INSERT INTO rooms (id) VALUES (?)", id)
+		return err
+	}
+
+	// A successful savepoint keeps its changes, a failed one only undoes its own.
+	if err = Savepoint(txn, func() error { return insert(1) }); err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("no room")
+	err = Savepoint(txn, func() error {
+		if err := insert(2); err != nil {
+			return err
+		}
+		// Nested, and kept until the outer savepoint fails.
+		if err := Savepoint(txn, func() error { return insert(3) }); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Errorf("Expected the error of the savepoint, got %v", err)
+	}
+	if err = txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err = database.QueryRow("SELECT COUNT(*) FROM rooms").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 room, got %d", n)
+	}
+}
+
+func TestSavepointWithoutTransaction(t *testing.T) {
+	if err := Savepoint(nil, func() error { return nil }); err == nil {
+		t.Error("Expected an error without transaction")
+	}
+}
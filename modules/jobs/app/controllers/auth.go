@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// AuthFilter protects the actions of the Jobs controller.  It is set on startup
+// from app.conf, unless the app sets its own, e.g. to check its session:
+//
+//    jobs.auth          = local|basic|deny|none  (by default local in dev mode,
+//                                                 and deny otherwise)
+//    jobs.auth.user     = admin                  (the credentials of basic auth)
+//    jobs.auth.password = secret
+//
+// Local only allows the requests from the loopback, which all are behind a
+// reverse proxy on the same host: it is only safe without one.
+var AuthFilter revel.Filter
+
+func init() {
+	revel.OnAppStart(func() {
+		if AuthFilter == nil {
+			defaultAuth := "deny"
+			if revel.DevMode {
+				defaultAuth = "local"
+			}
+			switch auth := revel.Config.StringDefault("jobs.auth", defaultAuth); auth {
+			case "local":
+				AuthFilter = LocalFilter
+			case "deny":
+				AuthFilter = DenyFilter
+			case "basic":
+				AuthFilter = BasicAuthFilter(
+					revel.Config.StringDefault("jobs.auth.user", ""),
+					revel.Config.StringDefault("jobs.auth.password", ""))
+			case "none":
+				AuthFilter = func(c *revel.Controller, fc []revel.Filter) { fc[0](c, fc[1:]) }
+			default:
+				panic("Unknown jobs.auth " + auth)
+			}
+		}
+		revel.FilterController(Jobs{}).
+			Add(func(c *revel.Controller, fc []revel.Filter) { AuthFilter(c, fc) }).
+			Add(RequestedWithFilter)
+	})
+}
+
+// DenyFilter allows no request.
+func DenyFilter(c *revel.Controller, fc []revel.Filter) {
+	c.Result = c.Forbidden("The jobs are not accessible (see jobs.auth)")
+}
+
+// RequestedWithFilter only allows the requests which change the jobs, other
+// than GET and HEAD, with an X-Requested-With header.  The browsers do not let
+// the pages of other sites send it (without CORS allowing them), while they send
+// the cookies and the credentials of basic auth with any form, so that it
+// protects the jobs from cross-site request forgery.
+func RequestedWithFilter(c *revel.Controller, fc []revel.Filter) {
+	if c.Request.Method != "GET" && c.Request.Method != "HEAD" && c.Request.Header.Get("X-Requested-With") == "" {
+		c.Result = c.Forbidden("The X-Requested-With header is required")
+		return
+	}
+	fc[0](c, fc[1:])
+}
+
+// LocalFilter only allows the requests from the loopback.
+func LocalFilter(c *revel.Controller, fc []revel.Filter) {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		c.Result = c.Forbidden("%s is not local", c.Request.RemoteAddr)
+		return
+	}
+	fc[0](c, fc[1:])
+}
+
+// BasicAuthFilter returns a filter which only allows the requests with the
+// given credentials, by HTTP basic authentication.  An empty password allows
+// none.
+func BasicAuthFilter(user, password string) revel.Filter {
+	return func(c *revel.Controller, fc []revel.Filter) {
+		u, p, ok := c.Request.BasicAuth()
+		if !ok || password == "" ||
+			subtle.ConstantTimeCompare([]byte(u), []byte(user)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			c.Response.Out.Header().Set("WWW-Authenticate", `Basic realm="jobs"`)
+			c.Response.Status = http.StatusUnauthorized
+			c.Result = c.RenderText("Unauthorized")
+			return
+		}
+		fc[0](c, fc[1:])
+	}
+}
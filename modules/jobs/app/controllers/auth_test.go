@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// Apply the filter to a request, returning the controller and whether the
+// filter let the request through.
+func applyFilter(t *testing.T, filter revel.Filter, req *http.Request) (*revel.Controller, bool) {
+	c := revel.NewController(revel.NewRequest(req), revel.NewResponse(httptest.NewRecorder()), nil)
+	passed := false
+	filter(c, []revel.Filter{func(c *revel.Controller, fc []revel.Filter) { passed = true }})
+	return c, passed
+}
+
+func TestLocalFilter(t *testing.T) {
+	for _, test := range []struct {
+		remoteAddr string
+		allowed    bool
+	}{
+		{"127.0.0.1:52000", true},
+		{"[::1]:52000", true},
+		{"10.0.0.5:52000", false},
+		{"[2001:db8::1]:52000", false},
+		{"127.0.0.1", false},
+		{"", false},
+	} {
+		req := httptest.NewRequest("GET", "/@jobs", nil)
+		req.RemoteAddr = test.remoteAddr
+		c, passed := applyFilter(t, LocalFilter, req)
+		if passed != test.allowed {
+			t.Errorf("%q: expected allowed %v", test.remoteAddr, test.allowed)
+		}
+		if !test.allowed && (c.Result == nil || c.Response.Status != http.StatusForbidden) {
+			t.Errorf("%q: expected a forbidden result, got %d %v", test.remoteAddr, c.Response.Status, c.Result)
+		}
+	}
+}
+
+func TestBasicAuthFilter(t *testing.T) {
+	for _, test := range []struct {
+		password             string // Of the filter
+		user, given          string // Of the request
+		credentials, allowed bool
+	}{
+		{"secret", "admin", "secret", true, true},
+		{"secret", "admin", "wrong", true, false},
+		{"secret", "root", "secret", true, false},
+		{"secret", "admin", "", true, false},
+		{"secret", "", "", false, false},
+		// An empty password allows none, even the empty one.
+		{"", "admin", "", true, false},
+		{"", "admin", "secret", true, false},
+	} {
+		req := httptest.NewRequest("GET", "/@jobs", nil)
+		if test.credentials {
+			req.SetBasicAuth(test.user, test.given)
+		}
+		c, passed := applyFilter(t, BasicAuthFilter("admin", test.password), req)
+		if passed != test.allowed {
+			t.Errorf("%+v: expected allowed %v", test, test.allowed)
+		}
+		if test.allowed {
+			continue
+		}
+		if c.Result == nil || c.Response.Status != http.StatusUnauthorized {
+			t.Errorf("%+v: expected an unauthorized result, got %d %v", test, c.Response.Status, c.Result)
+		}
+		if challenge := c.Response.Out.Header().Get("WWW-Authenticate"); challenge != `Basic realm="jobs"` {
+			t.Errorf("%+v: expected the basic auth challenge, got %q", test, challenge)
+		}
+	}
+}
+
+func TestDenyFilter(t *testing.T) {
+	req := httptest.NewRequest("GET", "/@jobs", nil)
+	req.RemoteAddr = "127.0.0.1:52000"
+	c, passed := applyFilter(t, DenyFilter, req)
+	if passed || c.Result == nil || c.Response.Status != http.StatusForbidden {
+		t.Errorf("Expected a forbidden result, got %d %v", c.Response.Status, c.Result)
+	}
+}
+
+func TestRequestedWithFilter(t *testing.T) {
+	for _, test := range []struct {
+		method, requestedWith string
+		allowed               bool
+	}{
+		{"GET", "", true},
+		{"HEAD", "", true},
+		{"POST", "", false},
+		{"POST", "XMLHttpRequest", true},
+		{"DELETE", "", false},
+	} {
+		req := httptest.NewRequest(test.method, "/@jobs/1/run", nil)
+		if test.requestedWith != "" {
+			req.Header.Set("X-Requested-With", test.requestedWith)
+		}
+		c, passed := applyFilter(t, RequestedWithFilter, req)
+		if passed != test.allowed {
+			t.Errorf("%+v: expected allowed %v", test, test.allowed)
+		}
+		if !test.allowed && (c.Result == nil || c.Response.Status != http.StatusForbidden) {
+			t.Errorf("%+v: expected a forbidden result, got %d %v", test, c.Response.Status, c.Result)
+		}
+	}
+}
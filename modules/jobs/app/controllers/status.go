@@ -1,26 +1,92 @@
 package controllers
 
 import (
-	"github.com/robfig/cron"
+	"time"
+
 	"github.com/BSP-Mosaic/teltech-revel"
 	"github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs"
-	"strings"
 )
 
+// Jobs shows the status of the scheduled jobs, and pauses, resumes, triggers or
+// cancels them.  Each action renders HTML, or JSON if it is requested by the Accept
+// header.  The actions are protected by the AuthFilter, and those which change the
+// jobs require an X-Requested-With header.
 type Jobs struct {
 	*revel.Controller
 }
 
+// The status of a scheduled job.
+type JobStatus struct {
+	Id      int
+	Name    string
+	Status  string
+	Prev    time.Time // The last scheduled run, if any.
+	Next    time.Time
+	Lock    *jobs.LockHolder // The holder of the lock, in locking mode.
+	History []jobs.Run       // The last runs, newest first.
+}
+
 func (c Jobs) Status() revel.Result {
-	if !strings.HasPrefix(c.Request.RemoteAddr, "127.0.0.1:") {
-		return c.Forbidden("%s is not local", c.Request.RemoteAddr)
+	statuses := jobStatuses()
+	if c.Request.Format == "json" {
+		return c.RenderJson(statuses)
+	}
+	locker := jobs.JobLocker != nil
+	instance := jobs.Instance
+	return c.Render(statuses, locker, instance)
+}
+
+func (c Jobs) Trigger(id int) revel.Result {
+	return c.apply(id, (*jobs.Job).Trigger)
+}
+
+func (c Jobs) Cancel(id int) revel.Result {
+	return c.apply(id, func(job *jobs.Job) { job.Cancel() })
+}
+
+func (c Jobs) Pause(id int) revel.Result {
+	return c.apply(id, (*jobs.Job).Pause)
+}
+
+func (c Jobs) Resume(id int) revel.Result {
+	return c.apply(id, (*jobs.Job).Resume)
+}
+
+// Apply the action to the job with the given id, and render its status, or
+// redirect to the status page.
+func (c Jobs) apply(id int, action func(*jobs.Job)) revel.Result {
+	job := jobs.Find(id)
+	if job == nil {
+		return c.NotFound("No scheduled job %d", id)
+	}
+	action(job)
+	if c.Request.Format == "json" {
+		for _, status := range jobStatuses() {
+			if status.Id == id {
+				return c.RenderJson(status)
+			}
+		}
 	}
-	entries := jobs.MainCron.Entries()
-	return c.Render(entries)
+	return c.Redirect(Jobs.Status)
 }
 
-func init() {
-	revel.TemplateFuncs["castjob"] = func(job cron.Job) *jobs.Job {
-		return job.(*jobs.Job)
+// Return the statuses of the scheduled jobs, in the order of their next run.
+func jobStatuses() []JobStatus {
+	var statuses []JobStatus
+	for _, entry := range jobs.MainCron.Entries() {
+		job, ok := entry.Job.(*jobs.Job)
+		if !ok {
+			continue
+		}
+		statuses = append(statuses, JobStatus{
+			Id:      job.Id,
+			Name:    job.Name,
+			Status:  job.Status(),
+			Prev:    entry.Prev,
+			Next:    entry.Next,
+			Lock:    job.Lock(),
+			History: job.History(),
+		})
 	}
+	return statuses
 }
@@ -0,0 +1,5 @@
+package jobs
+
+// Exported for the tests of package jobs_test, which run against the stores and
+// lockers of the sqljobs package.
+var RunQueuedJob = runQueuedJob
@@ -1,10 +1,13 @@
 package jobs
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"runtime/debug"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/robfig/cron"
 	"github.com/BSP-Mosaic/teltech-glog"
@@ -12,17 +15,54 @@ import (
 )
 
 type Job struct {
+	Id      int // The id of a scheduled job, in the order they were scheduled.
 	Name    string
 	inner   cron.Job
 	status  uint32
+	paused  uint32
 	running sync.Mutex
+
+	// In locking mode, the name of the lock of a scheduled job, and its schedule.
+	lockName string
+	schedule cron.Schedule
+
+	// The last runs, newest first.
+	history      []Run
+	historyMutex sync.Mutex
+
+	// The cancel funcs of the current runs.
+	cancels     map[*Run]context.CancelFunc
+	cancelMutex sync.Mutex
+}
+
+// A run of a job.
+type Run struct {
+	Start    time.Time
+	Duration time.Duration
+	Outcome  string // OK, ERROR, TIMEOUT, CANCELLED, PANIC, or SKIPPED if another instance held the lock.
+	Error    string // The error returned, or the panic message.
+	Manual   bool   // The run was triggered by hand.
 }
 
 const UNNAMED = "(unnamed)"
 
+const (
+	RUN_OK        = "OK"
+	RUN_ERROR     = "ERROR"
+	RUN_TIMEOUT   = "TIMEOUT"
+	RUN_CANCELLED = "CANCELLED"
+	RUN_PANIC     = "PANIC"
+	RUN_SKIPPED   = "SKIPPED"
+)
+
+const DEFAULT_JOB_HISTORY = 20
+
+// The number of runs kept in the history of each job, set from jobs.history.
+var historySize = DEFAULT_JOB_HISTORY
+
 func New(job cron.Job) *Job {
 	name := reflect.TypeOf(job).Name()
-	if name == "Func" {
+	if name == "Func" || name == "ContextFunc" {
 		name = UNNAMED
 	}
 	return &Job{
@@ -35,12 +75,86 @@ func (j *Job) Status() string {
 	if atomic.LoadUint32(&j.status) > 0 {
 		return "RUNNING"
 	}
+	if j.Paused() {
+		return "PAUSED"
+	}
 	return "IDLE"
 }
 
+// Pause stops the scheduled runs of the job, on this instance, until it is
+// resumed.  It may still be triggered.
+func (j *Job) Pause() {
+	atomic.StoreUint32(&j.paused, 1)
+}
+
+// Resume restarts the scheduled runs of a paused job.
+func (j *Job) Resume() {
+	atomic.StoreUint32(&j.paused, 0)
+}
+
+func (j *Job) Paused() bool {
+	return atomic.LoadUint32(&j.paused) > 0
+}
+
+// Trigger runs the job right now, even if it is paused.
+func (j *Job) Trigger() {
+	go j.run(true)
+}
+
+// Cancel cancels the context of the current runs of the job.  It returns false
+// if the job is not running.  Only the jobs which implement ContextJob or
+// FencedJob stop.
+func (j *Job) Cancel() bool {
+	j.cancelMutex.Lock()
+	defer j.cancelMutex.Unlock()
+	for _, cancel := range j.cancels {
+		cancel()
+	}
+	return len(j.cancels) > 0
+}
+
+// Set (or with nil, remove) the cancel func of a run.
+func (j *Job) setCancel(run *Run, cancel context.CancelFunc) {
+	j.cancelMutex.Lock()
+	defer j.cancelMutex.Unlock()
+	if cancel == nil {
+		delete(j.cancels, run)
+		return
+	}
+	if j.cancels == nil {
+		j.cancels = make(map[*Run]context.CancelFunc)
+	}
+	j.cancels[run] = cancel
+}
+
+// History returns the last runs of the job, newest first.
+func (j *Job) History() []Run {
+	j.historyMutex.Lock()
+	defer j.historyMutex.Unlock()
+	return append([]Run(nil), j.history...)
+}
+
+// Record a run in the history.
+func (j *Job) record(run Run) {
+	j.historyMutex.Lock()
+	defer j.historyMutex.Unlock()
+	j.history = append([]Run{run}, j.history...)
+	if len(j.history) > historySize {
+		j.history = j.history[:historySize]
+	}
+}
+
 func (j *Job) Run() {
+	if j.Paused() {
+		return
+	}
+	j.run(false)
+}
+
+func (j *Job) run(manual bool) {
 	// If the job panics, just print a stack trace.
 	// Don't let the whole process die.
+	var record *Run
 	defer func() {
 		if err := recover(); err != nil {
 			if revelError := revel.NewErrorFromPanic(err); revelError != nil {
@@ -48,6 +162,13 @@ func (j *Job) Run() {
 			} else {
 				glog.Error(err, "\n", string(debug.Stack()))
 			}
+			if record != nil {
+				record.Outcome, record.Error = RUN_PANIC, fmt.Sprint(err)
+			}
+		}
+		if record != nil {
+			record.Duration = time.Since(record.Start)
+			j.record(*record)
 		}
 	}()
 
@@ -61,8 +182,70 @@ func (j *Job) Run() {
 		defer func() { <-workPermits }()
 	}
 
+	// Do not start new runs once the app is stopping.
+	if appContext.Err() != nil {
+		return
+	}
+
+	// The context of the run, also cancelled if the lock of the job is lost.
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout := jobTimeout(j.Name); timeout > 0 {
+		ctx, cancel = context.WithTimeout(appContext, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(appContext)
+	}
+	defer cancel()
+
+	run := func(context.Context) error {
+		j.inner.Run()
+		return nil
+	}
+	if contextJob, ok := j.inner.(ContextJob); ok {
+		run = contextJob.RunContext
+	}
+	if JobLocker != nil && j.lockName != "" {
+		fenced, isFenced := j.inner.(FencedJob)
+		if isFenced && !JobLocker.MonotonicTokens() {
+			glog.Errorf("jobs: %s needs fencing tokens, which the Locker %T does not keep", j.Name, JobLocker)
+			j.record(Run{Start: time.Now(), Outcome: RUN_ERROR, Error: "fencing requires a Locker with monotonic tokens", Manual: manual})
+			return
+		}
+		token, unlock, ok := j.lock(cancel)
+		if !ok {
+			j.record(Run{Start: time.Now(), Outcome: RUN_SKIPPED, Manual: manual})
+			return
+		}
+		defer unlock()
+		if isFenced {
+			run = func(ctx context.Context) error {
+				return fenced.RunFenced(ctx, token)
+			}
+		}
+	}
+
 	atomic.StoreUint32(&j.status, 1)
 	defer atomic.StoreUint32(&j.status, 0)
 
-	j.inner.Run()
+	runningJobs.Add(1)
+	defer runningJobs.Done()
+
+	record = &Run{Start: time.Now(), Outcome: RUN_OK, Manual: manual}
+	j.setCancel(record, cancel)
+	defer j.setCancel(record, nil)
+
+	if err := run(ctx); err != nil {
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			record.Outcome = RUN_TIMEOUT
+		case context.Canceled:
+			record.Outcome = RUN_CANCELLED
+		default:
+			record.Outcome = RUN_ERROR
+		}
+		record.Error = err.Error()
+		glog.Errorf("jobs: %s failed (%s): %s", j.Name, record.Outcome, err)
+	}
 }
@@ -0,0 +1,384 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/robfig/cron"
+)
+
+// Set the configuration of the app to the given options, until the returned
+// function is called.
+func setTestConfig(options map[string]string) func() {
+	oldConfig := revel.Config
+	revel.Config = revel.NewEmptyConfig()
+	for name, value := range options {
+		revel.Config.SetOption(name, value)
+	}
+	return func() { revel.Config = oldConfig }
+}
+
+// A Locker which always grants the lock, and may fail to renew it.
+type testLocker struct {
+	mutex    sync.Mutex
+	renew    bool
+	released []int64
+	until    []time.Time
+}
+
+func (l *testLocker) Acquire(name, owner string, lease time.Duration) (int64, bool, error) {
+	return 7, true, nil
+}
+
+func (l *testLocker) Renew(name, owner string, token int64, lease time.Duration) (bool, error) {
+	return l.renew, nil
+}
+
+func (l *testLocker) Release(name, owner string, token int64, until time.Time) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.released = append(l.released, token)
+	l.until = append(l.until, until)
+	return nil
+}
+
+func (l *testLocker) Holder(name string) (*LockHolder, error) {
+	return nil, nil
+}
+
+func (l *testLocker) MonotonicTokens() bool { return true }
+
+// A FencedJob which runs until its context is done.
+type fencedJob struct {
+	tokens chan int64
+}
+
+func (j fencedJob) Run() {}
+
+func (j fencedJob) RunFenced(ctx context.Context, token int64) error {
+	j.tokens <- token
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Run a fenced job with the given Locker, returning its last run.
+func runFenced(t *testing.T, locker Locker, job fencedJob) Run {
+	oldLocker, oldLease := JobLocker, lockLease
+	JobLocker, lockLease = locker, 30*time.Millisecond
+	defer func() { JobLocker, lockLease = oldLocker, oldLease }()
+
+	j := New(job)
+	j.lockName = "fencedJob @every 1m"
+	j.run(false)
+	history := j.History()
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 run, got %v", history)
+	}
+	return history[0]
+}
+
+func TestFencedJobLosingItsLock(t *testing.T) {
+	defer setTestConfig(nil)()
+	locker := &testLocker{}
+	job := fencedJob{make(chan int64, 1)}
+
+	// The run is cancelled when the renewal of the lock fails.
+	run := runFenced(t, locker, job)
+	if run.Outcome != RUN_CANCELLED {
+		t.Errorf("Expected the run cancelled with the lock lost, got %+v", run)
+	}
+	if token := <-job.tokens; token != 7 {
+		t.Errorf("Expected the fencing token of the lock, got %d", token)
+	}
+	if len(locker.released) != 1 || locker.released[0] != 7 {
+		t.Errorf("Expected the lock released with its token, got %v", locker.released)
+	}
+}
+
+func TestFencedJobWithTimeout(t *testing.T) {
+	defer setTestConfig(map[string]string{"jobs.timeout.fencedJob": "50ms"})()
+	job := fencedJob{make(chan int64, 1)}
+
+	// While the lock is renewed, the run ends with its timeout.
+	if run := runFenced(t, &testLocker{renew: true}, job); run.Outcome != RUN_TIMEOUT {
+		t.Errorf("Expected the run to time out, got %+v", run)
+	}
+}
+
+func TestFencedJobWithCacheLocker(t *testing.T) {
+	defer setTestConfig(nil)()
+	job := fencedJob{make(chan int64, 1)}
+
+	// The tokens of the cache may start over, so the job is not run.
+	run := runFenced(t, CacheLocker{}, job)
+	if run.Outcome != RUN_ERROR || run.Error == "" {
+		t.Errorf("Expected the run refused, got %+v", run)
+	}
+	if len(job.tokens) != 0 {
+		t.Error("Expected the job not to run")
+	}
+}
+
+// A schedule which runs every interval, from any time.
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time { return t.Add(time.Duration(s)) }
+
+func TestLockHold(t *testing.T) {
+	defer setTestConfig(nil)()
+	oldLocker, oldHold := JobLocker, lockHold
+	defer func() { JobLocker, lockHold = oldLocker, oldHold }()
+	locker := &testLocker{renew: true}
+	JobLocker, lockHold = locker, time.Minute
+
+	// The lock is kept after the end of the run, at most half the way to the
+	// next one.
+	for _, test := range []struct {
+		schedule cron.Schedule
+		hold     time.Duration
+	}{
+		{nil, time.Minute},
+		{everySchedule(time.Hour), time.Minute},
+		{everySchedule(time.Second), 500 * time.Millisecond},
+	} {
+		j := New(Func(func() { time.Sleep(50 * time.Millisecond) }))
+		j.lockName, j.schedule = "sleeping", test.schedule
+		j.run(false)
+		run := j.History()[0]
+		until := locker.until[len(locker.until)-1]
+		if earliest := run.Start.Add(50*time.Millisecond + test.hold); until.Before(earliest) {
+			t.Errorf("%v: expected the lock kept %s after the run, until %s at least, got %s", test.schedule, test.hold, earliest, until)
+		}
+		if latest := run.Start.Add(run.Duration + test.hold); until.After(latest) {
+			t.Errorf("%v: expected the lock kept %s after the run, until %s at most, got %s", test.schedule, test.hold, latest, until)
+		}
+	}
+}
+
+func TestJobHistory(t *testing.T) {
+	oldSize := historySize
+	historySize = 3
+	defer func() { historySize = oldSize }()
+
+	calls := 0
+	j := New(ContextFunc(func(ctx context.Context) error {
+		calls++
+		switch calls {
+		case 2, 5:
+			return fmt.Errorf("error %d", calls)
+		case 3:
+			panic("out of rooms")
+		}
+		return nil
+	}))
+	for i := 0; i < 5; i++ {
+		j.run(false)
+	}
+
+	// Only the last runs are kept, newest first.
+	history := j.History()
+	var outcomes []string
+	for _, run := range history {
+		outcomes = append(outcomes, run.Outcome+" "+run.Error)
+	}
+	if expected := []string{"ERROR error 5", "OK ", "PANIC out of rooms"}; !reflect.DeepEqual(outcomes, expected) {
+		t.Errorf("Expected the outcomes %q, got %q", expected, outcomes)
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].Start.After(history[i-1].Start) {
+			t.Errorf("Expected the runs newest first, got %v", history)
+		}
+	}
+	if j.Status() != "IDLE" {
+		t.Errorf("Expected the job idle after a panic, got %s", j.Status())
+	}
+}
+
+func TestPauseAndTrigger(t *testing.T) {
+	runs := make(chan struct{}, 1)
+	j := New(Func(func() { runs <- struct{}{} }))
+
+	// A paused job skips its scheduled runs.
+	j.Pause()
+	j.Run()
+	if len(runs) != 0 || len(j.History()) != 0 {
+		t.Error("Expected the paused job not to run")
+	}
+	if j.Status() != "PAUSED" {
+		t.Errorf("Expected the job paused, got %s", j.Status())
+	}
+
+	// But is run when triggered.
+	j.Trigger()
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the triggered job to run")
+	}
+	for deadline := time.Now().Add(time.Second); len(j.History()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if history := j.History(); len(history) != 1 || !history[0].Manual || history[0].Outcome != RUN_OK {
+		t.Errorf("Expected a manual run, got %v", history)
+	}
+
+	// Resumed, it runs on schedule again.
+	j.Resume()
+	j.Run()
+	if len(runs) != 1 {
+		t.Error("Expected the resumed job to run")
+	}
+	if history := j.History(); len(history) != 2 || history[0].Manual {
+		t.Errorf("Expected a scheduled run, got %v", history)
+	}
+}
+
+// Replace the context of the jobs, which stopJobs cancels, and the runs it
+// waits for, until the returned function is called.
+func newAppContext() func() {
+	oldContext, oldCancel, oldRunning := appContext, cancelJobs, runningJobs
+	appContext, cancelJobs = context.WithCancel(context.Background())
+	runningJobs = new(sync.WaitGroup)
+	return func() { appContext, cancelJobs, runningJobs = oldContext, oldCancel, oldRunning }
+}
+
+func TestContextFunc(t *testing.T) {
+	defer setTestConfig(nil)()
+	failure := fmt.Errorf("no rooms")
+	var got context.Context
+	j := New(ContextFunc(func(ctx context.Context) error {
+		got = ctx
+		return failure
+	}))
+	if j.Name != UNNAMED {
+		t.Errorf("Expected a ContextFunc to be unnamed, got %s", j.Name)
+	}
+	j.run(false)
+
+	if got == nil || got.Err() == nil {
+		t.Error("Expected the context of the run, done once it ended")
+	}
+	if history := j.History(); len(history) != 1 || history[0].Outcome != RUN_ERROR || history[0].Error != "no rooms" {
+		t.Errorf("Expected the error of the run, got %v", history)
+	}
+}
+
+// A job which runs until its context is done, telling when it started.
+func waitingJob(started chan<- struct{}) ContextFunc {
+	return func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	defer setTestConfig(nil)()
+	oldTimeout := defaultTimeout
+	defaultTimeout = 20 * time.Millisecond
+	defer func() { defaultTimeout = oldTimeout }()
+
+	j := New(waitingJob(make(chan struct{}, 1)))
+	j.run(false)
+	run := j.History()[0]
+	if run.Outcome != RUN_TIMEOUT || run.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected the run to time out, got %+v", run)
+	}
+	if run.Duration < 20*time.Millisecond {
+		t.Errorf("Expected the run to last until the timeout, got %s", run.Duration)
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	defer setTestConfig(nil)()
+	started := make(chan struct{}, 1)
+	j := New(waitingJob(started))
+	if j.Cancel() {
+		t.Error("Expected no run to cancel")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		j.run(false)
+		close(done)
+	}()
+	<-started
+	if !j.Cancel() {
+		t.Error("Expected the run to be cancelled")
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the cancelled run to end")
+	}
+	if run := j.History()[0]; run.Outcome != RUN_CANCELLED {
+		t.Errorf("Expected the run cancelled, got %+v", run)
+	}
+}
+
+func TestStopJobs(t *testing.T) {
+	defer setTestConfig(map[string]string{"jobs.shutdown.timeout": "1s"})()
+	defer newAppContext()()
+
+	// The running jobs are cancelled, and waited for.
+	started := make(chan struct{}, 1)
+	var ended int32
+	j := New(ContextFunc(func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&ended, 1)
+		return ctx.Err()
+	}))
+	j.Trigger()
+	<-started
+	stopJobs()
+	if atomic.LoadInt32(&ended) != 1 {
+		t.Error("Expected stopJobs to wait for the running job")
+	}
+
+	// No run starts once the jobs are stopped.
+	j.run(false)
+	for deadline := time.Now().Add(time.Second); len(j.History()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if history := j.History(); len(history) != 1 || history[0].Outcome != RUN_CANCELLED {
+		t.Errorf("Expected only the cancelled run, got %v", history)
+	}
+}
+
+func TestStopJobsTimeout(t *testing.T) {
+	defer setTestConfig(map[string]string{"jobs.shutdown.timeout": "50ms"})()
+	defer newAppContext()()
+
+	// A job which ignores its context is not waited for past the timeout.
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	j := New(Func(func() {
+		started <- struct{}{}
+		<-release
+	}))
+	j.Trigger()
+	<-started
+	defer func() {
+		// Let the job end before the next test.
+		close(release)
+		for deadline := time.Now().Add(time.Second); len(j.History()) == 0 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	stopJobs()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected stopJobs to wait for the shutdown timeout, waited %s", elapsed)
+	}
+	if j.Status() != "RUNNING" {
+		t.Errorf("Expected the job still running, got %s", j.Status())
+	}
+}
@@ -7,17 +7,32 @@
 // 3. (Optional) Protection against multiple instances of a single job running
 //    concurrently.  If one execution runs into the next, the next will be queued.
 // 4. Cron expressions may be defined in app.conf and are reusable across jobs.
-// 5. Job status reporting.
+// 5. Job status reporting, with the history of the runs, and the pausing and
+//    triggering of the scheduled jobs.  (See the /@jobs page)
+// 6. A persistent queue of jobs, which are retried until they succeed.  (See Enqueue)
+// 7. Context-aware jobs, which return their errors, are stopped by a timeout,
+//    and are cancelled when the app stops.  (See ContextFunc)
+// 8. (Optional) Locking of the scheduled jobs, so that only one instance of the
+//    app runs each occurrence.  (See Locker)
 package jobs
 
 import (
+	"context"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron"
+	"github.com/BSP-Mosaic/teltech-glog"
 	"github.com/BSP-Mosaic/teltech-revel"
 )
 
+var (
+	// The scheduled jobs, by id - 1.
+	scheduled      []*Job
+	scheduledMutex sync.RWMutex
+)
+
 // Callers can use jobs.Func to wrap a raw func.
 // (Copying the type to this package makes it more visible)
 //
@@ -27,6 +42,36 @@ type Func func()
 
 func (r Func) Run() { r() }
 
+// A ContextJob is run with a context, which is done when the job times out,
+// is cancelled, or the app stops.  The error it returns is recorded in the
+// history of the job.
+type ContextJob interface {
+	cron.Job
+	RunContext(ctx context.Context) error
+}
+
+// Callers can use jobs.ContextFunc to wrap a raw func that takes a context.
+//
+// For example:
+//    jobs.Every(time.Hour, jobs.ContextFunc(func(ctx context.Context) error {
+//        return syncAccounts(ctx)
+//    }))
+//
+// The timeout of a job is configured in app.conf by:
+//
+//    jobs.timeout         = 0    (the default timeout, none if 0)
+//    jobs.timeout.JobName = 5m   (the timeout of the job of the given type)
+type ContextFunc func(ctx context.Context) error
+
+func (r ContextFunc) RunContext(ctx context.Context) error { return r(ctx) }
+
+// Run runs the func with the context of the app, when it is not run by a Job.
+func (r ContextFunc) Run() {
+	if err := r(appContext); err != nil {
+		glog.Error("jobs: ", err)
+	}
+}
+
 func Schedule(spec string, job cron.Job) error {
 	// Look to see if given spec is a key from the Config.
 	if strings.HasPrefix(spec, "cron.") {
@@ -40,7 +85,7 @@ func Schedule(spec string, job cron.Job) error {
 	if err != nil {
 		return err
 	}
-	MainCron.Schedule(sched, New(job))
+	MainCron.Schedule(sched, newScheduled(spec, sched, job))
 	return nil
 }
 
@@ -48,18 +93,57 @@ func Schedule(spec string, job cron.Job) error {
 // The interval provided is the time between the job ending and the job being run again.
 // The time that the job takes to run is not included in the interval.
 func Every(duration time.Duration, job cron.Job) {
-	MainCron.Schedule(cron.Every(duration), New(job))
+	sched := cron.Every(duration)
+	MainCron.Schedule(sched, newScheduled("@every "+duration.String(), sched, job))
+}
+
+// Return the Job of a scheduled job, named after its spec for locking mode.
+// Unnamed jobs are not locked, since their locks could not be told apart.
+func newScheduled(spec string, sched cron.Schedule, job cron.Job) *Job {
+	j := New(job)
+	j.schedule = sched
+	if j.Name != UNNAMED {
+		j.lockName = j.Name + " " + spec
+	}
+
+	scheduledMutex.Lock()
+	defer scheduledMutex.Unlock()
+	scheduled = append(scheduled, j)
+	j.Id = len(scheduled)
+	return j
+}
+
+// Scheduled returns the jobs scheduled by Schedule and Every.
+func Scheduled() []*Job {
+	scheduledMutex.RLock()
+	defer scheduledMutex.RUnlock()
+	return append([]*Job(nil), scheduled...)
+}
+
+// Find returns the scheduled job with the given id, or nil.
+func Find(id int) *Job {
+	scheduledMutex.RLock()
+	defer scheduledMutex.RUnlock()
+	if id < 1 || id > len(scheduled) {
+		return nil
+	}
+	return scheduled[id-1]
 }
 
 // Run the given job right now.
+// It is lost if the process exits first: use Enqueue to run it reliably.
 func Now(job cron.Job) {
 	go New(job).Run()
 }
 
 // Run the given job once, after the given delay.
+// It is lost if the process exits first: use Enqueue to run it reliably.
 func In(duration time.Duration, job cron.Job) {
 	go func() {
-		time.Sleep(duration)
-		New(job).Run()
+		select {
+		case <-time.After(duration):
+			New(job).Run()
+		case <-appContext.Done():
+		}
 	}()
 }
@@ -0,0 +1,252 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/BSP-Mosaic/teltech-revel/cache"
+	"github.com/robfig/cron"
+)
+
+// In locking mode, when several instances of the app run the same schedule,
+// only the instance that takes the lock of a job runs it.  The lock is a lease,
+// renewed while the job runs, and kept for a moment after it ends, so that the
+// instances with clocks behind do not run the same occurrence again.  Each time
+// it is taken, the lock gets a greater fencing token, which is given to the
+// jobs that implement FencedJob.
+//
+// Locking mode is enabled in app.conf by:
+//
+//    jobs.lock          = sql|cache  (the Locker, by default none)
+//    jobs.lock.lease    = 1m         (renewed every third of it)
+//    jobs.lock.hold     = 5s         (how long the lock is kept after a run, at most
+//                                     half the interval to the next run)
+//    jobs.lock.instance = name       (the name of this instance, by default host:pid)
+//
+// The sql Locker is in the sqljobs package, which must be imported to use it.
+
+// A Locker manages the locks of the jobs, shared by the instances of the app.
+type Locker interface {
+	// Acquire takes the named lock for the lease, if it is free or expired,
+	// and returns its new fencing token.  It returns false if another holds it.
+	Acquire(name, owner string, lease time.Duration) (token int64, acquired bool, err error)
+
+	// Renew extends the lease of a held lock.  It returns false if the lock
+	// was lost.
+	Renew(name, owner string, token int64, lease time.Duration) (bool, error)
+
+	// Release frees a held lock at the given time, which may be now.
+	Release(name, owner string, token int64, until time.Time) error
+
+	// Holder returns the holder of the lock, or nil if it is free.
+	Holder(name string) (*LockHolder, error)
+
+	// MonotonicTokens returns whether the fencing tokens of a lock only ever
+	// increase.  The FencedJobs are only run with such a Locker.
+	MonotonicTokens() bool
+}
+
+// The holder of a lock.
+type LockHolder struct {
+	Owner   string // The instance holding the lock.
+	Token   int64  // The fencing token.
+	Expires time.Time
+}
+
+// A FencedJob is given the fencing token of its lock when it is run in locking
+// mode, instead of being Run.  The storage the job writes to may reject tokens
+// lower than the last it saw, from an instance which lost the lock.  As with a
+// ContextJob, the context is done when the job times out, is cancelled, or the
+// app stops, and also when the lock is lost.
+//
+// A FencedJob is only run with a Locker whose tokens only increase, like the
+// sqljobs.Locker, and not with the CacheLocker, whose tokens are lost when the
+// cache evicts them.
+type FencedJob interface {
+	cron.Job
+	RunFenced(ctx context.Context, token int64) error
+}
+
+// The Lockers selectable by jobs.lock, by name.
+var lockerFactories = map[string]func() Locker{
+	"cache": func() Locker { return CacheLocker{} },
+}
+
+// RegisterLocker makes a Locker selectable by name in jobs.lock.  The factory
+// is called on startup, and may read its own configuration.
+func RegisterLocker(name string, factory func() Locker) {
+	lockerFactories[name] = factory
+}
+
+const (
+	DEFAULT_LOCK_LEASE = time.Minute
+	DEFAULT_LOCK_HOLD  = 5 * time.Second
+)
+
+var (
+	// The Locker of the scheduled jobs, set on startup from jobs.lock.  If nil,
+	// every instance runs them.
+	JobLocker Locker
+
+	// The name of this instance in the locks, set on startup.
+	Instance string
+
+	lockLease = DEFAULT_LOCK_LEASE
+	lockHold  = DEFAULT_LOCK_HOLD
+)
+
+// Read the locking configuration.
+func configureLocking() {
+	lockLease = configDuration("jobs.lock.lease", DEFAULT_LOCK_LEASE)
+	lockHold = configDuration("jobs.lock.hold", DEFAULT_LOCK_HOLD)
+
+	hostname, _ := os.Hostname()
+	Instance = revel.Config.StringDefault("jobs.lock.instance", fmt.Sprintf("%s:%d", hostname, os.Getpid()))
+
+	if JobLocker != nil {
+		return
+	}
+	switch locker := revel.Config.StringDefault("jobs.lock", "none"); locker {
+	case "none":
+	default:
+		factory, ok := lockerFactories[locker]
+		if !ok {
+			panic("Unknown jobs.lock " + locker + " (is the package of its Locker imported?)")
+		}
+		JobLocker = factory()
+	}
+}
+
+// Take the lock of the job, and renew it until the returned func releases it,
+// calling lost if a renewal fails.  Returns false if the job must not run.
+func (j *Job) lock(lost func()) (token int64, unlock func(), ok bool) {
+	token, acquired, err := JobLocker.Acquire(j.lockName, Instance, lockLease)
+	if err != nil {
+		glog.Errorf("jobs: failed to lock %s: %s", j.lockName, err)
+		return 0, nil, false
+	}
+	if !acquired {
+		glog.V(1).Infof("jobs: %s is locked by another instance", j.lockName)
+		return 0, nil, false
+	}
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				renewed, err := JobLocker.Renew(j.lockName, Instance, token, lockLease)
+				if err != nil || !renewed {
+					glog.Errorf("jobs: lost the lock of %s (token %d): %v", j.lockName, token, err)
+					lost()
+					return
+				}
+			}
+		}
+	}()
+
+	unlock = func() {
+		// Stop renewing the lock before releasing it.
+		close(done)
+		<-stopped
+		// Keep the lock for a moment, at most half the way to the next run.
+		end := time.Now()
+		hold := lockHold
+		if j.schedule != nil {
+			if interval := j.schedule.Next(end).Sub(end); interval/2 < hold {
+				hold = interval / 2
+			}
+		}
+		if err := JobLocker.Release(j.lockName, Instance, token, end.Add(hold)); err != nil {
+			glog.Errorf("jobs: failed to release the lock of %s: %s", j.lockName, err)
+		}
+	}
+	return token, unlock, true
+}
+
+// Lock returns the holder of the lock of the job, or nil if it is free or not
+// locked.
+func (j *Job) Lock() *LockHolder {
+	if JobLocker == nil || j.lockName == "" {
+		return nil
+	}
+	holder, err := JobLocker.Holder(j.lockName)
+	if err != nil {
+		glog.Errorf("jobs: failed to get the lock of %s: %s", j.lockName, err)
+		return nil
+	}
+	return holder
+}
+
+// CacheLocker keeps the locks in the cache, as entries expiring with their
+// lease, which cache.Add only sets if they are absent.  The cache must be shared
+// by the instances, e.g. memcached.  Renewals are not atomic, so a lock may be
+// taken over while its lease is renewed, and the counters of the fencing tokens
+// may be evicted, so that the tokens start over: the FencedJobs are refused, and
+// must use the sqljobs.Locker.
+type CacheLocker struct{}
+
+func (CacheLocker) Acquire(name, owner string, lease time.Duration) (int64, bool, error) {
+	tokenKey := "jobs.lock.token." + name
+	if err := cache.Add(tokenKey, 0, cache.FOREVER); err != nil && err != cache.ErrNotStored {
+		return 0, false, err
+	}
+	token, err := cache.Increment(tokenKey, 1)
+	if err != nil {
+		return 0, false, err
+	}
+
+	holder := LockHolder{owner, int64(token), time.Now().Add(lease)}
+	switch err = cache.Add("jobs.lock."+name, holder, lease); err {
+	case nil:
+		return holder.Token, true, nil
+	case cache.ErrNotStored:
+		return 0, false, nil
+	}
+	return 0, false, err
+}
+
+func (l CacheLocker) Renew(name, owner string, token int64, lease time.Duration) (bool, error) {
+	holder, err := l.Holder(name)
+	if err != nil || holder == nil || holder.Owner != owner || holder.Token != token {
+		return false, err
+	}
+	holder.Expires = time.Now().Add(lease)
+	return true, cache.Set("jobs.lock."+name, *holder, lease)
+}
+
+func (l CacheLocker) Release(name, owner string, token int64, until time.Time) error {
+	holder, err := l.Holder(name)
+	if err != nil || holder == nil || holder.Owner != owner || holder.Token != token {
+		return err
+	}
+	if remaining := until.Sub(time.Now()); remaining > 0 {
+		holder.Expires = until
+		return cache.Replace("jobs.lock."+name, *holder, remaining)
+	}
+	return cache.Delete("jobs.lock." + name)
+}
+
+// MonotonicTokens returns false: the counters of the tokens may be evicted.
+func (CacheLocker) MonotonicTokens() bool { return false }
+
+func (CacheLocker) Holder(name string) (*LockHolder, error) {
+	var holder LockHolder
+	switch err := cache.Get("jobs.lock."+name, &holder); err {
+	case nil:
+		return &holder, nil
+	case cache.ErrCacheMiss:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,131 @@
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel/cache"
+	"github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs"
+	"github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs/sqljobs"
+)
+
+// The lockers under test, each new and without locks.
+var testLockers = []struct {
+	name   string
+	locker func(t *testing.T) jobs.Locker
+}{
+	{"sql", func(t *testing.T) jobs.Locker {
+		locker := sqljobs.NewLocker("revel_job_locks")
+		locker.Db = openTestDb(t)
+		return locker
+	}},
+	{"cache", func(t *testing.T) jobs.Locker {
+		oldInstance := cache.Instance
+		cache.Instance = cache.NewInMemoryCache(time.Hour)
+		t.Cleanup(func() { cache.Instance = oldInstance })
+		return jobs.CacheLocker{}
+	}},
+}
+
+func TestLockers(t *testing.T) {
+	for _, test := range testLockers {
+		t.Run(test.name, func(t *testing.T) { testLocker(t, test.locker(t)) })
+	}
+}
+
+func testLocker(t *testing.T, locker jobs.Locker) {
+	const name = "Report @every 1m"
+	acquire := func(owner string, lease time.Duration) (int64, bool) {
+		token, acquired, err := locker.Acquire(name, owner, lease)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token, acquired
+	}
+	holder := func() *jobs.LockHolder {
+		holder, err := locker.Holder(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return holder
+	}
+
+	if h := holder(); h != nil {
+		t.Errorf("Expected a free lock, got %+v", h)
+	}
+	first, acquired := acquire("a", time.Hour)
+	if !acquired {
+		t.Fatal("Expected to acquire a free lock")
+	}
+	if h := holder(); h == nil || h.Owner != "a" || h.Token != first || h.Expires.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("Expected a to hold the lock for an hour, got %+v", h)
+	}
+
+	// A held lock is not acquired, even by its holder.
+	for _, owner := range []string{"b", "a"} {
+		if _, acquired = acquire(owner, time.Hour); acquired {
+			t.Errorf("Expected %s not to acquire the held lock", owner)
+		}
+	}
+
+	// Only the holder renews and releases the lock, with its token.
+	for _, renewal := range []struct {
+		owner   string
+		token   int64
+		renewed bool
+	}{{"b", first, false}, {"a", first + 1, false}, {"a", first, true}} {
+		renewed, err := locker.Renew(name, renewal.owner, renewal.token, 2*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if renewed != renewal.renewed {
+			t.Errorf("Expected the renewal by %s with token %d to be %v", renewal.owner, renewal.token, renewal.renewed)
+		}
+	}
+	if h := holder(); h == nil || h.Expires.Before(time.Now().Add(119*time.Minute)) {
+		t.Errorf("Expected the lock renewed for 2 hours, got %+v", h)
+	}
+	if err := locker.Release(name, "b", first, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if h := holder(); h == nil || h.Owner != "a" {
+		t.Errorf("Expected a to hold the lock after the release by b, got %+v", h)
+	}
+
+	// Released with a hold, the lock stays held until it ends.
+	if err := locker.Release(name, "a", first, time.Now().Add(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, acquired = acquire("b", time.Hour); acquired {
+		t.Error("Expected b not to acquire the lock during the hold")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if h := holder(); h != nil {
+		t.Errorf("Expected a free lock after the hold, got %+v", h)
+	}
+	second, acquired := acquire("b", time.Hour)
+	if !acquired || second <= first {
+		t.Fatalf("Expected b to acquire the lock with a token greater than %d, got %d (%v)", first, second, acquired)
+	}
+
+	// Released now, the lock is free at once.
+	if err := locker.Release(name, "b", second, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if h := holder(); h != nil {
+		t.Errorf("Expected a free lock after the release, got %+v", h)
+	}
+
+	// An expired lease frees the lock.
+	third, acquired := acquire("a", 50*time.Millisecond)
+	if !acquired || third <= second {
+		t.Fatalf("Expected a to acquire the lock with a token greater than %d, got %d (%v)", second, third, acquired)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if h := holder(); h != nil {
+		t.Errorf("Expected a free lock after its lease expired, got %+v", h)
+	}
+	if fourth, acquired := acquire("b", time.Hour); !acquired || fourth <= third {
+		t.Errorf("Expected b to acquire the expired lock with a token greater than %d, got %d (%v)", third, fourth, acquired)
+	}
+}
@@ -1,12 +1,20 @@
 package jobs
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
+
 	"github.com/robfig/cron"
+	"github.com/BSP-Mosaic/teltech-glog"
 	"github.com/BSP-Mosaic/teltech-revel"
 )
 
-const DEFAULT_JOB_POOL_SIZE = 10
+const (
+	DEFAULT_JOB_POOL_SIZE    = 10
+	DEFAULT_SHUTDOWN_TIMEOUT = 10 * time.Second
+)
 
 var (
 	// Singleton instance of the underlying job scheduler.
@@ -17,6 +25,15 @@ var (
 
 	// Is a single job allowed to run concurrently with itself?
 	selfConcurrent bool
+
+	// The context of the jobs, cancelled when the app stops.
+	appContext, cancelJobs = context.WithCancel(context.Background())
+
+	// The runs in progress, waited for when the app stops.
+	runningJobs = new(sync.WaitGroup)
+
+	// The default timeout of the jobs, set from jobs.timeout.
+	defaultTimeout time.Duration
 )
 
 func init() {
@@ -26,7 +43,39 @@ func init() {
 			workPermits = make(chan struct{}, size)
 		}
 		selfConcurrent = revel.Config.BoolDefault("jobs.selfconcurrent", false)
+		historySize = revel.Config.IntDefault("jobs.history", DEFAULT_JOB_HISTORY)
+		defaultTimeout = configDuration("jobs.timeout", 0)
+		configureLocking()
 		MainCron.Start()
+		startQueue()
 		fmt.Println("Go to /@jobs to see job status.")
 	})
+	revel.OnAppStop(stopJobs)
+}
+
+// Stop scheduling the jobs, cancel the running ones, and wait for them to end,
+// for at most jobs.shutdown.timeout.
+func stopJobs() {
+	MainCron.Stop()
+	cancelJobs()
+
+	done, running := make(chan struct{}), runningJobs
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	timeout := configDuration("jobs.shutdown.timeout", DEFAULT_SHUTDOWN_TIMEOUT)
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		glog.Warningf("jobs: some jobs are still running after %s, exiting anyway", timeout)
+	}
+}
+
+// Return the timeout of the jobs of the given name, or 0 for none.
+func jobTimeout(name string) time.Duration {
+	if name == UNNAMED {
+		return defaultTimeout
+	}
+	return configDuration("jobs.timeout."+name, defaultTimeout)
 }
@@ -0,0 +1,283 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-glog"
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// The queue runs jobs in the background, like Now and In, but keeps them in a
+// Store until they succeed, so that they survive restarts and are retried when
+// they fail.  Jobs are run by named handlers, so that the queue only holds
+// their name and payload.
+//
+// For example:
+//
+//    func init() {
+//        jobs.Handle("mail.welcome", func(payload []byte) error {
+//            var userId int
+//            if err := json.Unmarshal(payload, &userId); err != nil {
+//                return err
+//            }
+//            return sendWelcomeMail(userId)
+//        })
+//    }
+//
+//    jobs.Enqueue("mail.welcome", user.Id, jobs.Options{Delay: time.Hour})
+//
+// A job is retried after a delay that doubles on each failure, until it fails
+// its last attempt, and is moved to the dead letters.
+//
+// Queued jobs count towards the jobs.pool limit.  The queue is configured in
+// app.conf by:
+//
+//    jobs.queue.store    = memory|sql  (the jobs are lost on restart in memory)
+//    jobs.queue.attempts = 5           (the default MaxAttempts)
+//    jobs.queue.backoff  = 10s         (the default Backoff)
+//    jobs.queue.poll     = 1s          (how often to look for due jobs)
+//    jobs.queue.lease    = 10m         (how long a job may run before it is retried)
+//
+// The sql store is in the sqljobs package, which must be imported to use it, so
+// that the apps which do not need it do not depend on the db module.
+
+// A Handler runs the jobs with its name, given their payload.  The job is
+// retried if it returns an error, or panics.
+type Handler func(payload []byte) error
+
+// Options of a queued job.  Zero values select the defaults of app.conf.
+type Options struct {
+	Delay       time.Duration // How long to wait before running the job.
+	MaxAttempts int           // The number of runs before the job is dead.
+	Backoff     time.Duration // The delay before the first retry, doubled on each.
+}
+
+const (
+	DEFAULT_QUEUE_ATTEMPTS = 5
+	DEFAULT_QUEUE_BACKOFF  = 10 * time.Second
+	DEFAULT_QUEUE_POLL     = time.Second
+	DEFAULT_QUEUE_LEASE    = 10 * time.Minute
+
+	// The number of jobs claimed at once if the pool is unlimited.
+	queueBatchSize = 10
+
+	// The longest delay between retries.
+	maxQueueBackoff = 24 * time.Hour
+)
+
+var (
+	// The store of the queued jobs, set on startup from jobs.queue.store.  It
+	// may be replaced by the app, e.g. with a MemoryStore in tests.
+	QueueStore Store
+
+	handlers      = make(map[string]Handler)
+	handlersMutex sync.RWMutex
+
+	queueAttempts = DEFAULT_QUEUE_ATTEMPTS
+	queueBackoff  = DEFAULT_QUEUE_BACKOFF
+	queuePoll     = DEFAULT_QUEUE_POLL
+	queueLease    = DEFAULT_QUEUE_LEASE
+
+	// Wakes the queue up to run a job enqueued for now.
+	queueWake = make(chan struct{}, 1)
+)
+
+// Handle registers the handler of the jobs with the given name.
+func Handle(name string, handler Handler) {
+	handlersMutex.Lock()
+	defer handlersMutex.Unlock()
+	handlers[name] = handler
+}
+
+// Enqueue adds a job to the queue, to be run by the handler with the given
+// name, with the JSON encoding of the payload.  It returns the id of the job.
+func Enqueue(name string, payload interface{}, opts Options) (string, error) {
+	if QueueStore == nil {
+		return "", fmt.Errorf("jobs: the queue is not started")
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("jobs: failed to encode the payload of %s: %s", name, err)
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = queueAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = queueBackoff
+	}
+
+	now := time.Now()
+	job := &QueuedJob{
+		Id:          newJobId(),
+		Name:        name,
+		Payload:     encoded,
+		MaxAttempts: opts.MaxAttempts,
+		Backoff:     opts.Backoff,
+		RunAt:       now.Add(opts.Delay),
+		CreatedAt:   now,
+	}
+	if err = QueueStore.Add(job); err != nil {
+		return "", err
+	}
+	if opts.Delay <= 0 {
+		select {
+		case queueWake <- struct{}{}:
+		default:
+		}
+	}
+	return job.Id, nil
+}
+
+// Read the queue configuration and start running the queued jobs.
+func startQueue() {
+	queueAttempts = revel.Config.IntDefault("jobs.queue.attempts", DEFAULT_QUEUE_ATTEMPTS)
+	queueBackoff = configDuration("jobs.queue.backoff", DEFAULT_QUEUE_BACKOFF)
+	queuePoll = configDuration("jobs.queue.poll", DEFAULT_QUEUE_POLL)
+	queueLease = configDuration("jobs.queue.lease", DEFAULT_QUEUE_LEASE)
+
+	if QueueStore == nil {
+		store := revel.Config.StringDefault("jobs.queue.store", "memory")
+		factory, ok := storeFactories[store]
+		if !ok {
+			panic("Unknown jobs.queue.store " + store + " (is the package of its Store imported?)")
+		}
+		QueueStore = factory()
+	}
+	go runQueue()
+}
+
+// Run the due jobs, every poll interval or as they are enqueued.
+func runQueue() {
+	for {
+		runDueJobs()
+		select {
+		case <-queueWake:
+		case <-time.After(queuePoll):
+		case <-appContext.Done():
+			return
+		}
+	}
+}
+
+// Claim and run the due jobs, as permitted by the pool.
+func runDueJobs() {
+	for {
+		n := queueBatchSize
+		if workPermits != nil {
+			n = cap(workPermits) - len(workPermits)
+		}
+		if n <= 0 || appContext.Err() != nil {
+			return
+		}
+
+		store := QueueStore
+		claimed, err := store.Claim(time.Now(), n, queueLease)
+		if err != nil {
+			glog.Error("jobs: failed to claim the queued jobs: ", err)
+			return
+		}
+		for _, job := range claimed {
+			if workPermits != nil {
+				workPermits <- struct{}{}
+			}
+			runningJobs.Add(1)
+			go func(job *QueuedJob) {
+				defer runningJobs.Done()
+				if workPermits != nil {
+					defer func() { <-workPermits }()
+				}
+				runQueuedJob(store, job)
+			}(job)
+		}
+		if len(claimed) < n {
+			return
+		}
+	}
+}
+
+// Run a claimed job, and remove it, or schedule its retry.
+func runQueuedJob(store Store, job *QueuedJob) {
+	err := callHandler(job)
+	if err == nil {
+		if err = store.Done(job); err != nil {
+			glog.Errorf("jobs: failed to remove job %s (%s): %s", job.Id, job.Name, err)
+		}
+		return
+	}
+
+	job.LastError = err.Error()
+	if job.Attempts >= job.MaxAttempts {
+		job.Dead = true
+		job.RunAt = time.Now()
+		glog.Errorf("jobs: job %s (%s) failed %d times, and is dead: %s", job.Id, job.Name, job.Attempts, err)
+	} else {
+		job.RunAt = time.Now().Add(retryDelay(job.Backoff, job.Attempts))
+		glog.Warningf("jobs: job %s (%s) failed, retrying at %s: %s", job.Id, job.Name, job.RunAt.Format(time.RFC3339), err)
+	}
+	if err = store.Fail(job); err != nil {
+		glog.Errorf("jobs: failed to save job %s (%s): %s", job.Id, job.Name, err)
+	}
+}
+
+// Call the handler of the job, turning a panic into an error.
+func callHandler(job *QueuedJob) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if revelError := revel.NewErrorFromPanic(recovered); revelError != nil {
+				glog.Error(recovered, "\n", revelError.Stack)
+			} else {
+				glog.Error(recovered, "\n", string(debug.Stack()))
+			}
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+
+	handlersMutex.RLock()
+	handler, ok := handlers[job.Name]
+	handlersMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("no handler for %s", job.Name)
+	}
+	return handler(job.Payload)
+}
+
+// Return the delay before retrying a job after the given attempts: the backoff,
+// doubled for each attempt after the first.
+func retryDelay(backoff time.Duration, attempts int) time.Duration {
+	delay := backoff
+	for i := 1; i < attempts && delay < maxQueueBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxQueueBackoff {
+		delay = maxQueueBackoff
+	}
+	return delay
+}
+
+// Return the duration configured by the given key, or the default.
+func configDuration(key string, defaultDuration time.Duration) time.Duration {
+	value, found := revel.Config.String(key)
+	if !found {
+		return defaultDuration
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		panic("Could not parse " + key + " " + value + ": " + err.Error())
+	}
+	return duration
+}
+
+// Return a new random job id.
+func newJobId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+// Run the queue on a new MemoryStore, with the given pool, a new context and
+// the given handler, until the returned function is called.
+func newTestQueue(pool int, name string, handler Handler) func() {
+	restoreContext := newAppContext()
+	oldStore, oldPermits, oldPoll := QueueStore, workPermits, queuePoll
+	QueueStore, workPermits, queuePoll = NewMemoryStore(), nil, time.Hour
+	if pool > 0 {
+		workPermits = make(chan struct{}, pool)
+	}
+	Handle(name, handler)
+	select {
+	case <-queueWake:
+	default:
+	}
+	return func() {
+		cancelJobs()
+		runningJobs.Wait()
+		handlersMutex.Lock()
+		delete(handlers, name)
+		handlersMutex.Unlock()
+		QueueStore, workPermits, queuePoll = oldStore, oldPermits, oldPoll
+		restoreContext()
+	}
+}
+
+// Run the queue, returning a channel closed once it stops.
+func startTestQueue() <-chan struct{} {
+	stopped := make(chan struct{})
+	go func() {
+		runQueue()
+		close(stopped)
+	}()
+	return stopped
+}
+
+// Return the ids of the due jobs left in the queue.
+func dueJobs(t *testing.T) []string {
+	claimed, err := QueueStore.Claim(time.Now(), queueBatchSize, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, job := range claimed {
+		ids = append(ids, job.Id)
+	}
+	return ids
+}
+
+func TestQueueWakeUp(t *testing.T) {
+	runs := make(chan string, 1)
+	defer newTestQueue(0, "test.wake", func(payload []byte) error {
+		runs <- string(payload)
+		return nil
+	})()
+	stopped := startTestQueue()
+	defer func() {
+		cancelJobs()
+		<-stopped
+	}()
+
+	// A job enqueued for now runs without waiting for the poll interval.
+	if _, err := Enqueue("test.wake", "room 7", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-runs:
+		if payload != `"room 7"` {
+			t.Errorf("Expected the payload of the job, got %s", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the enqueued job to run")
+	}
+}
+
+func TestQueuePermits(t *testing.T) {
+	started, release := make(chan struct{}, 3), make(chan struct{})
+	defer newTestQueue(2, "test.permits", func(payload []byte) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})()
+	for i := 0; i < 3; i++ {
+		if _, err := Enqueue("test.permits", i, Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With a permit taken by another job, a single job is claimed.
+	workPermits <- struct{}{}
+	runDueJobs()
+	<-started
+	if len(workPermits) != 2 || len(started) != 0 {
+		t.Errorf("Expected 1 job running, got %d permits taken", len(workPermits))
+	}
+
+	// Once the permits are free, the others are.
+	close(release)
+	runningJobs.Wait()
+	<-workPermits
+	runDueJobs()
+	runningJobs.Wait()
+	if len(started) != 2 || len(workPermits) != 0 {
+		t.Errorf("Expected the other 2 jobs run, got %d", len(started))
+	}
+	if due := dueJobs(t); len(due) != 0 {
+		t.Errorf("Expected no job left, got %v", due)
+	}
+}
+
+func TestQueueShutdown(t *testing.T) {
+	runs := make(chan struct{}, 1)
+	defer newTestQueue(0, "test.shutdown", func(payload []byte) error {
+		runs <- struct{}{}
+		return nil
+	})()
+	stopped := startTestQueue()
+
+	// The queue stops with the jobs.
+	cancelJobs()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the queue to stop")
+	}
+
+	// And no longer claims the jobs.
+	id, err := Enqueue("test.shutdown", nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runDueJobs()
+	if len(runs) != 0 {
+		t.Error("Expected the job not to run")
+	}
+	if due := dueJobs(t); len(due) != 1 || due[0] != id {
+		t.Errorf("Expected the job left in the queue, got %v", due)
+	}
+}
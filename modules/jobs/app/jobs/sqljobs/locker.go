@@ -0,0 +1,114 @@
+package sqljobs
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs"
+)
+
+// Locker keeps the locks in a table of the database of the db module, with a
+// row by lock.
+type Locker struct {
+	sqlTable
+}
+
+// NewLocker returns a Locker in the given table of db.Db, which may be opened
+// later (by db.Init).
+func NewLocker(table string) *Locker {
+	return &Locker{sqlTable{Table: table, schema: lockTableSchema}}
+}
+
+const lockTableSchema = `CREATE TABLE IF NOT EXISTS %s (
+	name VARCHAR(255) NOT NULL PRIMARY KEY,
+	owner VARCHAR(255) NOT NULL,
+	token BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL
+)`
+
+func (l *Locker) Acquire(name, owner string, lease time.Duration) (int64, bool, error) {
+	database, err := l.db()
+	if err != nil {
+		return 0, false, err
+	}
+	now := time.Now()
+
+	// Take the lock if it expired, or else create it.  Either fails if another
+	// instance holds it.
+	result, err := database.Exec(l.query("UPDATE %s SET owner = ?, token = token + 1, expires_at = ? WHERE name = ? AND expires_at <= ?"),
+		owner, now.Add(lease).UnixNano(), name, now.UnixNano())
+	if err != nil {
+		return 0, false, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return 0, false, err
+	} else if rows == 0 {
+		_, err = database.Exec(l.query("INSERT INTO %s (name, owner, token, expires_at) VALUES (?, ?, 1, ?)"),
+			name, owner, now.Add(lease).UnixNano())
+		if err != nil {
+			// The lock exists (unless the database failed).
+			if holder, holderErr := l.Holder(name); holderErr != nil || holder == nil {
+				return 0, false, err
+			}
+			return 0, false, nil
+		}
+	}
+
+	var token int64
+	err = database.QueryRow(l.query("SELECT token FROM %s WHERE name = ? AND owner = ?"), name, owner).Scan(&token)
+	if err != nil {
+		return 0, false, err
+	}
+	return token, true, nil
+}
+
+func (l *Locker) Renew(name, owner string, token int64, lease time.Duration) (bool, error) {
+	return l.setExpiry(name, owner, token, time.Now().Add(lease))
+}
+
+func (l *Locker) Release(name, owner string, token int64, until time.Time) error {
+	_, err := l.setExpiry(name, owner, token, until)
+	return err
+}
+
+// Set the expiry of a held lock, returning false if it is not held.
+func (l *Locker) setExpiry(name, owner string, token int64, expires time.Time) (bool, error) {
+	database, err := l.db()
+	if err != nil {
+		return false, err
+	}
+	result, err := database.Exec(l.query("UPDATE %s SET expires_at = ? WHERE name = ? AND owner = ? AND token = ?"),
+		expires.UnixNano(), name, owner, token)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// MonotonicTokens returns true: the token of a lock is kept in its row, which is
+// never deleted.
+func (l *Locker) MonotonicTokens() bool { return true }
+
+func (l *Locker) Holder(name string) (*jobs.LockHolder, error) {
+	database, err := l.db()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		holder  jobs.LockHolder
+		expires int64
+	)
+	err = database.QueryRow(l.query("SELECT owner, token, expires_at FROM %s WHERE name = ?"), name).
+		Scan(&holder.Owner, &holder.Token, &expires)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	holder.Expires = time.Unix(0, expires)
+	if !holder.Expires.After(time.Now()) {
+		return nil, nil
+	}
+	return &holder, nil
+}
@@ -0,0 +1,68 @@
+// Package sqljobs keeps the queue and the locks of the jobs module in the
+// database of the db module.  Importing it makes them available in app.conf:
+//
+//	import _ "github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs/sqljobs"
+//
+//	jobs.queue.store = sql
+//	jobs.queue.table = revel_jobs       (the table of the queued jobs)
+//	jobs.lock        = sql
+//	jobs.lock.table  = revel_job_locks  (the table of the locks)
+package sqljobs
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/BSP-Mosaic/teltech-revel/modules/db/app"
+	"github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs"
+)
+
+func init() {
+	jobs.RegisterStore("sql", func() jobs.Store {
+		return NewStore(revel.Config.StringDefault("jobs.queue.table", "revel_jobs"))
+	})
+	jobs.RegisterLocker("sql", func() jobs.Locker {
+		return NewLocker(revel.Config.StringDefault("jobs.lock.table", "revel_job_locks"))
+	})
+}
+
+// A table of the database of the db module, which is created if needed.  Times
+// are stored as Unix nanoseconds, for portability.
+type sqlTable struct {
+	Table string  // The name of the table.
+	Db    *sql.DB // The database, db.Db by default.
+
+	schema     string // The CREATE TABLE statement, given the name.
+	createOnce sync.Once
+	createErr  error
+}
+
+// Return the database, creating the table on first use.
+func (t *sqlTable) db() (*sql.DB, error) {
+	database := t.Db
+	if database == nil {
+		database = db.Db
+	}
+	if database == nil {
+		return nil, fmt.Errorf("sqljobs: the database is not open (db.Init)")
+	}
+	t.createOnce.Do(func() {
+		if _, err := database.Exec(fmt.Sprintf(t.schema, t.Table)); err != nil {
+			t.createErr = fmt.Errorf("sqljobs: failed to create the table %s: %s", t.Table, err)
+		}
+	})
+	return database, t.createErr
+}
+
+// Return the query on the table (%s), with its "?" replaced by the placeholders
+// of the driver.
+func (t *sqlTable) query(query string) string {
+	parts := strings.Split(fmt.Sprintf(query, t.Table), "?")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = db.Placeholder(i) + parts[i]
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,158 @@
+package sqljobs
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs"
+)
+
+// Store keeps the queue in a table of the database of the db module.
+type Store struct {
+	sqlTable
+}
+
+// NewStore returns a store in the given table of db.Db, which may be opened
+// later (by db.Init).
+func NewStore(table string) *Store {
+	return &Store{sqlTable{Table: table, schema: jobsTableSchema}}
+}
+
+const jobsTableSchema = `CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(32) NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	payload TEXT,
+	attempts INTEGER NOT NULL,
+	max_attempts INTEGER NOT NULL,
+	backoff BIGINT NOT NULL,
+	run_at BIGINT NOT NULL,
+	last_error TEXT,
+	dead INTEGER NOT NULL,
+	created_at BIGINT NOT NULL
+)`
+
+const jobColumns = "id, name, payload, attempts, max_attempts, backoff, run_at, last_error, dead, created_at"
+
+func (s *Store) Add(job *jobs.QueuedJob) error {
+	database, err := s.db()
+	if err != nil {
+		return err
+	}
+	_, err = database.Exec(s.query("INSERT INTO %s ("+jobColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		job.Id, job.Name, string(job.Payload), job.Attempts, job.MaxAttempts, int64(job.Backoff),
+		job.RunAt.UnixNano(), job.LastError, boolInt(job.Dead), job.CreatedAt.UnixNano())
+	return err
+}
+
+func (s *Store) Claim(now time.Time, n int, lease time.Duration) ([]*jobs.QueuedJob, error) {
+	database, err := s.db()
+	if err != nil {
+		return nil, err
+	}
+	due, err := s.selectJobs(database, s.query("SELECT "+jobColumns+" FROM %s WHERE dead = 0 AND run_at <= ? ORDER BY run_at, created_at LIMIT ?"),
+		now.UnixNano(), n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another process may claim the same jobs: only those still due when
+	// updated are claimed.
+	var claimed []*jobs.QueuedJob
+	for _, job := range due {
+		leaseEnd := now.Add(lease)
+		result, err := database.Exec(s.query("UPDATE %s SET attempts = attempts + 1, run_at = ? WHERE id = ? AND run_at = ? AND dead = 0"),
+			leaseEnd.UnixNano(), job.Id, job.RunAt.UnixNano())
+		if err != nil {
+			return claimed, err
+		}
+		if rows, err := result.RowsAffected(); err != nil || rows != 1 {
+			continue
+		}
+		job.Attempts++
+		job.RunAt = leaseEnd
+		claimed = append(claimed, job)
+	}
+	return claimed, nil
+}
+
+func (s *Store) Done(job *jobs.QueuedJob) error {
+	database, err := s.db()
+	if err != nil {
+		return err
+	}
+	_, err = database.Exec(s.query("DELETE FROM %s WHERE id = ?"), job.Id)
+	return err
+}
+
+func (s *Store) Fail(job *jobs.QueuedJob) error {
+	database, err := s.db()
+	if err != nil {
+		return err
+	}
+	_, err = database.Exec(s.query("UPDATE %s SET run_at = ?, last_error = ?, dead = ? WHERE id = ?"),
+		job.RunAt.UnixNano(), job.LastError, boolInt(job.Dead), job.Id)
+	return err
+}
+
+func (s *Store) DeadLetters() ([]*jobs.QueuedJob, error) {
+	database, err := s.db()
+	if err != nil {
+		return nil, err
+	}
+	return s.selectJobs(database, s.query("SELECT "+jobColumns+" FROM %s WHERE dead = 1 ORDER BY run_at, created_at"))
+}
+
+func (s *Store) Requeue(id string) error {
+	database, err := s.db()
+	if err != nil {
+		return err
+	}
+	result, err := database.Exec(s.query("UPDATE %s SET dead = 0, attempts = 0, run_at = ? WHERE id = ? AND dead = 1"),
+		time.Now().UnixNano(), id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows != 1 {
+		return fmt.Errorf("sqljobs: no dead job %s", id)
+	}
+	return nil
+}
+
+// Return the jobs selected by the query.
+func (s *Store) selectJobs(database *sql.DB, query string, args ...interface{}) ([]*jobs.QueuedJob, error) {
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var selected []*jobs.QueuedJob
+	for rows.Next() {
+		var (
+			job                       jobs.QueuedJob
+			payload, lastError        sql.NullString
+			backoff, runAt, createdAt int64
+			dead                      int
+		)
+		if err = rows.Scan(&job.Id, &job.Name, &payload, &job.Attempts, &job.MaxAttempts,
+			&backoff, &runAt, &lastError, &dead, &createdAt); err != nil {
+			return nil, err
+		}
+		job.Payload = []byte(payload.String)
+		job.Backoff = time.Duration(backoff)
+		job.RunAt = time.Unix(0, runAt)
+		job.LastError = lastError.String
+		job.Dead = dead != 0
+		job.CreatedAt = time.Unix(0, createdAt)
+		selected = append(selected, &job)
+	}
+	return selected, rows.Err()
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A job in the queue.
+type QueuedJob struct {
+	Id          string
+	Name        string        // The name of the handler of the job.
+	Payload     []byte        // The JSON encoded payload.
+	Attempts    int           // The number of times the job was run.
+	MaxAttempts int           // The number of runs before the job is dead.
+	Backoff     time.Duration // The delay before the first retry, doubled on each.
+	RunAt       time.Time     // When the job is due, or its claim expires.
+	LastError   string        // The error of the last failed run.
+	Dead        bool          // The job failed its last attempt.
+	CreatedAt   time.Time
+}
+
+// Store persists the queued jobs.  Several processes may share a store, so a
+// job is claimed before it is run.
+type Store interface {
+	// Add adds a job to the queue.
+	Add(job *QueuedJob) error
+
+	// Claim takes up to n jobs due by now, incrementing their attempts.  The
+	// jobs are due again once the lease expires, unless they are done or fail
+	// by then, e.g. if the process died running them.
+	Claim(now time.Time, n int, lease time.Duration) ([]*QueuedJob, error)
+
+	// Done removes a job that ran successfully.
+	Done(job *QueuedJob) error
+
+	// Fail saves a job that failed: its RunAt to retry it, its LastError, and
+	// whether it is Dead.
+	Fail(job *QueuedJob) error
+
+	// DeadLetters returns the dead jobs, oldest first.
+	DeadLetters() ([]*QueuedJob, error)
+
+	// Requeue puts a dead job back in the queue, due now, with its attempts
+	// reset.
+	Requeue(id string) error
+}
+
+// The stores selectable by jobs.queue.store, by name.
+var storeFactories = map[string]func() Store{
+	"memory": func() Store { return NewMemoryStore() },
+}
+
+// RegisterStore makes a Store selectable by name in jobs.queue.store.  The
+// factory is called on startup, and may read its own configuration.
+func RegisterStore(name string, factory func() Store) {
+	storeFactories[name] = factory
+}
+
+// MemoryStore keeps the queue in memory, e.g. for tests.  Its jobs are lost
+// when the process exits.
+type MemoryStore struct {
+	mutex sync.Mutex
+	jobs  map[string]*QueuedJob
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]*QueuedJob)}
+}
+
+func (s *MemoryStore) Add(job *QueuedJob) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := *job
+	s.jobs[job.Id] = &stored
+	return nil
+}
+
+func (s *MemoryStore) Claim(now time.Time, n int, lease time.Duration) ([]*QueuedJob, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var due []*QueuedJob
+	for _, job := range s.jobs {
+		if !job.Dead && !job.RunAt.After(now) {
+			due = append(due, job)
+		}
+	}
+	sort.Sort(byRunAt(due))
+	if len(due) > n {
+		due = due[:n]
+	}
+
+	claimed := make([]*QueuedJob, len(due))
+	for i, job := range due {
+		job.Attempts++
+		job.RunAt = now.Add(lease)
+		copied := *job
+		claimed[i] = &copied
+	}
+	return claimed, nil
+}
+
+func (s *MemoryStore) Done(job *QueuedJob) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.jobs, job.Id)
+	return nil
+}
+
+func (s *MemoryStore) Fail(job *QueuedJob) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored, ok := s.jobs[job.Id]
+	if !ok {
+		return fmt.Errorf("jobs: no queued job %s", job.Id)
+	}
+	stored.RunAt, stored.LastError, stored.Dead = job.RunAt, job.LastError, job.Dead
+	return nil
+}
+
+func (s *MemoryStore) DeadLetters() ([]*QueuedJob, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var dead []*QueuedJob
+	for _, job := range s.jobs {
+		if job.Dead {
+			copied := *job
+			dead = append(dead, &copied)
+		}
+	}
+	sort.Sort(byRunAt(dead))
+	return dead, nil
+}
+
+func (s *MemoryStore) Requeue(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	job, ok := s.jobs[id]
+	if !ok || !job.Dead {
+		return fmt.Errorf("jobs: no dead job %s", id)
+	}
+	job.Dead, job.Attempts, job.RunAt = false, 0, time.Now()
+	return nil
+}
+
+// Sort jobs by RunAt, then by creation.
+type byRunAt []*QueuedJob
+
+func (jobs byRunAt) Len() int      { return len(jobs) }
+func (jobs byRunAt) Swap(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] }
+func (jobs byRunAt) Less(i, j int) bool {
+	if !jobs[i].RunAt.Equal(jobs[j].RunAt) {
+		return jobs[i].RunAt.Before(jobs[j].RunAt)
+	}
+	return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+}
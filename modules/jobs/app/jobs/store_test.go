@@ -0,0 +1,197 @@
+package jobs_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs"
+	"github.com/BSP-Mosaic/teltech-revel/modules/jobs/app/jobs/sqljobs"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Return an in-memory SQLite database, closed at the end of the test.
+func openTestDb(t *testing.T) *sql.DB {
+	database, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each connection has its own in-memory database.
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// The stores under test, each new and empty.
+var testStores = []struct {
+	name  string
+	store func(t *testing.T) jobs.Store
+}{
+	{"memory", func(t *testing.T) jobs.Store { return jobs.NewMemoryStore() }},
+	{"sql", func(t *testing.T) jobs.Store {
+		store := sqljobs.NewStore("revel_jobs")
+		store.Db = openTestDb(t)
+		return store
+	}},
+}
+
+func newQueuedJob(id, name string, runAt time.Time) *jobs.QueuedJob {
+	return &jobs.QueuedJob{
+		Id:          id,
+		Name:        name,
+		Payload:     []byte(`{"id":1}`),
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		RunAt:       runAt,
+		CreatedAt:   runAt,
+	}
+}
+
+func claimIds(t *testing.T, store jobs.Store, now time.Time, n int) []string {
+	claimed, err := store.Claim(now, n, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, job := range claimed {
+		ids = append(ids, job.Id)
+	}
+	return ids
+}
+
+func TestStores(t *testing.T) {
+	for _, test := range testStores {
+		t.Run(test.name, func(t *testing.T) {
+			t.Run("Lease", func(t *testing.T) { testStoreLease(t, test.store(t)) })
+			t.Run("ClaimLimit", func(t *testing.T) { testStoreClaimLimit(t, test.store(t)) })
+			t.Run("Backoff", func(t *testing.T) { testStoreBackoff(t, test.store(t)) })
+		})
+	}
+}
+
+// Enqueued jobs are claimed when due, leased until done.
+func testStoreLease(t *testing.T, store jobs.Store) {
+	now := time.Now()
+	for _, job := range []*jobs.QueuedJob{
+		newQueuedJob("late", "mail", now.Add(-time.Second)),
+		newQueuedJob("due", "mail", now),
+		newQueuedJob("later", "mail", now.Add(time.Minute)),
+	} {
+		if err := store.Add(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed, err := store.Claim(now, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 2 || claimed[0].Id != "late" || claimed[1].Id != "due" {
+		t.Fatalf("Expected the late and due jobs, got %v", claimed)
+	}
+	job := claimed[0]
+	if job.Attempts != 1 || !job.RunAt.Equal(now.Add(time.Hour)) || job.Name != "mail" || string(job.Payload) != `{"id":1}` {
+		t.Errorf("Unexpected claimed job %+v", job)
+	}
+
+	// The leased jobs are not claimed again until the lease expires.
+	if ids := claimIds(t, store, now.Add(time.Minute), 10); len(ids) != 1 || ids[0] != "later" {
+		t.Errorf("Expected only the later job while the others are leased, got %v", ids)
+	}
+	if err = store.Done(job); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err = store.Claim(now.Add(2*time.Hour), 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 2 || claimed[0].Id != "due" || claimed[0].Attempts != 2 {
+		t.Errorf("Expected the due job to be claimed again once its lease expired, got %v", claimed)
+	}
+}
+
+// Claim takes the n jobs due first.
+func testStoreClaimLimit(t *testing.T, store jobs.Store) {
+	now := time.Now()
+	for i := 4; i >= 0; i-- {
+		if err := store.Add(newQueuedJob(fmt.Sprint(i), "mail", now.Add(time.Duration(i-5)*time.Second))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range [][]string{{"0", "1"}, {"2", "3"}, {"4"}, {}} {
+		if ids := claimIds(t, store, now, 2); fmt.Sprint(ids) != fmt.Sprint(expected) {
+			t.Errorf("Expected %v, got %v", expected, ids)
+		}
+	}
+}
+
+// A failing job is retried after a doubling delay, until it is dead and requeued.
+func testStoreBackoff(t *testing.T, store jobs.Store) {
+	jobs.Handle("test.failing", func(payload []byte) error {
+		return errors.New("no mail server")
+	})
+	runAt := time.Now()
+	if err := store.Add(newQueuedJob("failing", "test.failing", runAt)); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt, delay := range []time.Duration{time.Minute, 2 * time.Minute, 0} {
+		claimed, err := store.Claim(runAt, 10, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(claimed) != 1 || claimed[0].Attempts != attempt+1 {
+			t.Fatalf("Expected attempt %d of the job, got %v", attempt+1, claimed)
+		}
+		job := claimed[0]
+		start := time.Now()
+		jobs.RunQueuedJob(store, job)
+		if job.LastError != "no mail server" {
+			t.Errorf("Expected the error of the handler, got %q", job.LastError)
+		}
+		if job.Dead != (delay == 0) {
+			t.Errorf("Attempt %d: expected dead %v", attempt+1, delay == 0)
+		}
+		if delay != 0 {
+			if retry := job.RunAt.Sub(start); retry < delay || retry > delay+time.Second {
+				t.Errorf("Attempt %d: expected a retry in %s, got %s", attempt+1, delay, retry)
+			}
+			if ids := claimIds(t, store, job.RunAt.Add(-time.Second), 10); len(ids) != 0 {
+				t.Errorf("Attempt %d: expected no job before the retry, got %v", attempt+1, ids)
+			}
+		}
+		runAt = job.RunAt
+	}
+
+	// The dead job is not run again, unless requeued.
+	if ids := claimIds(t, store, runAt.Add(48*time.Hour), 10); len(ids) != 0 {
+		t.Errorf("Expected no job to claim once dead, got %v", ids)
+	}
+	dead, err := store.DeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].Id != "failing" || dead[0].LastError != "no mail server" || dead[0].Attempts != 3 {
+		t.Fatalf("Expected the failing job in the dead letters, got %v", dead)
+	}
+
+	if err = store.Requeue("failing"); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Requeue("failing"); err == nil {
+		t.Error("Expected an error requeuing a job that is not dead")
+	}
+	if dead, err = store.DeadLetters(); err != nil || len(dead) != 0 {
+		t.Errorf("Expected no dead letters once requeued, got %v (%v)", dead, err)
+	}
+	claimed, err := store.Claim(time.Now(), 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Errorf("Expected the requeued job with its attempts reset, got %v", claimed)
+	}
+}
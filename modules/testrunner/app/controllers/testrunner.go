@@ -7,7 +7,10 @@ import (
 	"html"
 	"html/template"
 	"reflect"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"time"
 )
 
 type TestRunner struct {
@@ -24,9 +27,10 @@ type TestDesc struct {
 }
 
 type TestSuiteResult struct {
-	Name    string
-	Passed  bool
-	Results []TestResult
+	Name     string
+	Passed   bool
+	Results  []TestResult
+	Duration time.Duration
 }
 
 type TestResult struct {
@@ -34,10 +38,19 @@ type TestResult struct {
 	Passed       bool
 	ErrorHtml    template.HTML
 	ErrorSummary string
+	ErrorStack   string
+	Duration     time.Duration
 }
 
 var NONE = []reflect.Value{}
 
+// The suite instances their BeforeAll() ran on, by suite name, from which the
+// instances of their tests are copied until AfterAll().
+var (
+	sharedSuites     = make(map[string]reflect.Value)
+	sharedSuitesLock sync.Mutex
+)
+
 func (c TestRunner) Index() revel.Result {
 	var testSuites []TestSuiteDesc
 	for _, testSuite := range revel.TestSuites {
@@ -48,7 +61,67 @@ func (c TestRunner) Index() revel.Result {
 
 // Run runs a single test, given by the argument.
 func (c TestRunner) Run(suite, test string) revel.Result {
-	result := TestResult{Name: test}
+	return c.RenderJson(runSuiteMethod(suite, test, func(v reflect.Value) {
+		// Run the hooks registered around every test (e.g. to reset the
+		// database), even if the test fails.
+		for _, hook := range revel.BeforeTestHooks {
+			hook()
+		}
+		defer func() {
+			for _, hook := range revel.AfterTestHooks {
+				hook()
+			}
+		}()
+
+		// Call Before(), call the test, and call After().
+		if m := v.MethodByName("Before"); m.IsValid() {
+			m.Call(NONE)
+		}
+		v.MethodByName(test).Call(NONE)
+		if m := v.MethodByName("After"); m.IsValid() {
+			m.Call(NONE)
+		}
+	}))
+}
+
+// BeforeAll calls the BeforeAll() method of the suite, if it has one.  It is
+// called before running the tests of the suite, to prepare state shared by the
+// tests (e.g. in the database).  Each test runs on a copy of the suite instance
+// BeforeAll() ran on, so that it sees the fields it set.
+func (c TestRunner) BeforeAll(suite string) revel.Result {
+	return c.RenderJson(runSuiteMethod(suite, "BeforeAll", func(v reflect.Value) {
+		sharedSuitesLock.Lock()
+		sharedSuites[suite] = v
+		sharedSuitesLock.Unlock()
+		callSuiteHook(v, "BeforeAll")
+	}))
+}
+
+// AfterAll calls the AfterAll() method of the suite, if it has one.  It is
+// called after running the tests of the suite.
+func (c TestRunner) AfterAll(suite string) revel.Result {
+	defer func() {
+		sharedSuitesLock.Lock()
+		delete(sharedSuites, suite)
+		sharedSuitesLock.Unlock()
+	}()
+	return c.RenderJson(runSuiteMethod(suite, "AfterAll", func(v reflect.Value) {
+		callSuiteHook(v, "AfterAll")
+	}))
+}
+
+func callSuiteHook(v reflect.Value, hook string) {
+	if m := v.MethodByName(hook); m.IsValid() {
+		m.Call(NONE)
+	}
+}
+
+// Create an instance of the named suite (a copy of the one BeforeAll() ran on,
+// if any) and pass it to the given function, recording its failure if it
+// panics.
+func runSuiteMethod(suite, name string, run func(v reflect.Value)) TestResult {
+	result := TestResult{Name: name}
+	start := time.Now()
 	for _, testSuite := range revel.TestSuites {
 		t := reflect.TypeOf(testSuite).Elem()
 		if t.Name() != suite {
@@ -57,17 +130,25 @@ func (c TestRunner) Run(suite, test string) revel.Result {
 
 		// Found the suite, create a new instance and run the named method.
 		v := reflect.New(t)
+		sharedSuitesLock.Lock()
+		if shared, ok := sharedSuites[suite]; ok {
+			v.Elem().Set(shared.Elem())
+		}
+		sharedSuitesLock.Unlock()
 		func() {
 			defer func() {
 				if err := recover(); err != nil {
 					error := revel.NewErrorFromPanic(err)
 					if error == nil {
 						result.ErrorHtml = template.HTML(html.EscapeString(fmt.Sprint(err)))
+						result.ErrorSummary = fmt.Sprint(err)
+						result.ErrorStack = string(debug.Stack())
 					} else {
 						var buffer bytes.Buffer
 						tmpl, _ := revel.MainTemplateLoader.Template("TestRunner/FailureDetail.html")
 						tmpl.Execute(&buffer, error)
 						result.ErrorSummary = errorSummary(error)
+						result.ErrorStack = error.Stack
 						result.ErrorHtml = template.HTML(buffer.String())
 					}
 				}
@@ -77,21 +158,15 @@ func (c TestRunner) Run(suite, test string) revel.Result {
 			testSuiteInstance := v.Elem().FieldByName("TestSuite")
 			testSuiteInstance.Set(reflect.ValueOf(revel.NewTestSuite()))
 
-			// Call Before(), call the test, and call After().
-			if m := v.MethodByName("Before"); m.IsValid() {
-				m.Call(NONE)
-			}
-			v.MethodByName(test).Call(NONE)
-			if m := v.MethodByName("After"); m.IsValid() {
-				m.Call(NONE)
-			}
+			run(v)
 
 			// No panic means success.
 			result.Passed = true
 		}()
 		break
 	}
-	return c.RenderJson(result)
+	result.Duration = time.Since(start)
+	return result
 }
 
 // List returns a JSON list of test suites and tests.
@@ -1,9 +1,8 @@
 package revel
 
 import (
+	"net/http"
 	"runtime/debug"
-
-	"github.com/BSP-Mosaic/teltech-glog"
 )
 
 // PanicFilter wraps the action invocation in a protective defer blanket that
@@ -21,14 +20,11 @@ func PanicFilter(c *Controller, fc []Filter) {
 // It cleans up the stack trace, logs it, and displays an error page.
 func handleInvocationPanic(c *Controller, err interface{}) {
 	error := NewErrorFromPanic(err)
-	if error == nil && DevMode {
-		// Only show the sensitive information in the debug stack trace in development mode, not production
-		glog.Error(err, "\n", string(debug.Stack()))
-		c.Response.Out.WriteHeader(500)
-		c.Response.Out.Write(debug.Stack())
-		return
+	if error == nil {
+		error = NewErrorFromStack(err, string(debug.Stack()))
 	}
 
-	glog.Error(err, "\n", error.Stack)
+	// The stack is logged with the error.
+	c.Response.Status = http.StatusInternalServerError
 	c.Result = c.RenderError(error)
 }
@@ -154,6 +154,15 @@ func TestResolveAcceptLanguage(t *testing.T) {
 		}
 	}
 
+	request = buildHttpRequestWithAcceptLanguage("nl; q=0.6, en-GB , en;q=0.8")
+	if result := ResolveAcceptLanguage(request); len(result) != 3 {
+		t.Errorf("Unexpected Accept-Language values length of %d (expected %d)", len(result), 3)
+	} else {
+		if result[0].Language != "en-GB" || result[2].Language != "nl" || result[2].Quality != 0.6 {
+			t.Errorf("Expected whitespace to be ignored, got '%s'", result)
+		}
+	}
+
 	request = buildHttpRequestWithAcceptLanguage("en;q=0.8,nl;q=0.6,en-AU;q=malformed")
 	if result := ResolveAcceptLanguage(request); len(result) != 3 {
 		t.Errorf("Unexpected Accept-Language values length of %d (expected %d)", len(result), 3)
@@ -0,0 +1,155 @@
+package revel
+
+// CLDR plural categories.
+//
+// See http://unicode.org/reports/tr35/tr35-numbers.html#Language_Plural_Rules for details.
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// A PluralRule selects the CLDR plural category of a count for a language.
+type PluralRule struct {
+	// The categories used by the language, in the order gettext numbers its plural forms.
+	Categories []string
+	// Returns the category for the given (integer) count.
+	Category func(n int) string
+}
+
+var (
+	oneOtherRule = PluralRule{
+		Categories: []string{PluralOne, PluralOther},
+		Category: func(n int) string {
+			if n == 1 {
+				return PluralOne
+			}
+			return PluralOther
+		},
+	}
+	zeroOneOtherRule = PluralRule{
+		Categories: []string{PluralOne, PluralOther},
+		Category: func(n int) string {
+			if n == 0 || n == 1 {
+				return PluralOne
+			}
+			return PluralOther
+		},
+	}
+	otherRule = PluralRule{
+		Categories: []string{PluralOther},
+		Category:   func(n int) string { return PluralOther },
+	}
+	eastSlavicRule = PluralRule{
+		Categories: []string{PluralOne, PluralFew, PluralMany, PluralOther},
+		Category: func(n int) string {
+			n = abs(n)
+			switch mod10, mod100 := n%10, n%100; {
+			case mod10 == 1 && mod100 != 11:
+				return PluralOne
+			case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+				return PluralFew
+			}
+			return PluralMany
+		},
+	}
+	westSlavicRule = PluralRule{
+		Categories: []string{PluralOne, PluralFew, PluralOther},
+		Category: func(n int) string {
+			switch n {
+			case 1:
+				return PluralOne
+			case 2, 3, 4:
+				return PluralFew
+			}
+			return PluralOther
+		},
+	}
+
+	// PluralRules holds the plural rule of each language, keyed by language code.
+	// Languages without an entry use the English rule (one / other).
+	// Applications may register additional rules.
+	PluralRules = map[string]PluralRule{
+		"en": oneOtherRule, "de": oneOtherRule, "nl": oneOtherRule, "sv": oneOtherRule,
+		"da": oneOtherRule, "nb": oneOtherRule, "no": oneOtherRule, "fi": oneOtherRule,
+		"it": oneOtherRule, "es": oneOtherRule, "el": oneOtherRule, "hu": oneOtherRule,
+		"tr": oneOtherRule, "bg": oneOtherRule, "ca": oneOtherRule, "et": oneOtherRule,
+
+		"fr": zeroOneOtherRule, "pt": zeroOneOtherRule,
+
+		"ja": otherRule, "zh": otherRule, "ko": otherRule, "vi": otherRule,
+		"th": otherRule, "id": otherRule, "ms": otherRule,
+
+		"ru": eastSlavicRule, "uk": eastSlavicRule, "be": eastSlavicRule,
+
+		"cs": westSlavicRule, "sk": westSlavicRule,
+
+		"pl": {
+			Categories: []string{PluralOne, PluralFew, PluralMany, PluralOther},
+			Category: func(n int) string {
+				n = abs(n)
+				if n == 1 {
+					return PluralOne
+				}
+				if mod10, mod100 := n%10, n%100; mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14) {
+					return PluralFew
+				}
+				return PluralMany
+			},
+		},
+		"he": {
+			Categories: []string{PluralOne, PluralTwo, PluralOther},
+			Category: func(n int) string {
+				switch n {
+				case 1:
+					return PluralOne
+				case 2:
+					return PluralTwo
+				}
+				return PluralOther
+			},
+		},
+		"ar": {
+			Categories: []string{PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther},
+			Category: func(n int) string {
+				n = abs(n)
+				switch mod100 := n % 100; {
+				case n == 0:
+					return PluralZero
+				case n == 1:
+					return PluralOne
+				case n == 2:
+					return PluralTwo
+				case mod100 >= 3 && mod100 <= 10:
+					return PluralFew
+				case mod100 >= 11:
+					return PluralMany
+				}
+				return PluralOther
+			},
+		},
+	}
+)
+
+// Return the plural rule for the given language, defaulting to the English rule.
+func pluralRule(language string) PluralRule {
+	if rule, ok := PluralRules[language]; ok {
+		return rule
+	}
+	return oneOtherRule
+}
+
+// Return the plural category of the given count for the given language.
+func PluralCategory(language string, n int) string {
+	return pluralRule(language).Category(n)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,38 @@
+package revel
+
+import "testing"
+
+func TestPluralCategory(t *testing.T) {
+	for _, test := range []struct {
+		language string
+		counts   []int
+		expected string
+	}{
+		{"en", []int{1}, PluralOne},
+		{"en", []int{0, 2, 11, 101}, PluralOther},
+		{"fr", []int{0, 1}, PluralOne},
+		{"fr", []int{2, 100}, PluralOther},
+		{"ja", []int{0, 1, 2}, PluralOther},
+		{"ru", []int{1, 21, 101}, PluralOne},
+		{"ru", []int{2, 3, 4, 22, 104}, PluralFew},
+		{"ru", []int{0, 5, 11, 12, 14, 111}, PluralMany},
+		{"pl", []int{1}, PluralOne},
+		{"pl", []int{2, 24}, PluralFew},
+		{"pl", []int{0, 5, 12, 21}, PluralMany},
+		{"cs", []int{2, 4}, PluralFew},
+		{"cs", []int{5, 22}, PluralOther},
+		{"ar", []int{0}, PluralZero},
+		{"ar", []int{2}, PluralTwo},
+		{"ar", []int{3, 103}, PluralFew},
+		{"ar", []int{11, 99}, PluralMany},
+		{"ar", []int{100, 102}, PluralOther},
+		{"xx", []int{1}, PluralOne},
+		{"xx", []int{2}, PluralOther},
+	} {
+		for _, n := range test.counts {
+			if category := PluralCategory(test.language, n); category != test.expected {
+				t.Errorf("Plural category of %d in '%s': expected %s, got %s", n, test.language, test.expected, category)
+			}
+		}
+	}
+}
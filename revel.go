@@ -24,7 +24,7 @@ var (
 	AppPath    string // e.g. "/Users/robfig/gocode/src/corp/sample/app"
 	ViewsPath  string // e.g. "/Users/robfig/gocode/src/corp/sample/app/views"
 	ImportPath string // e.g. "corp/sample"
-	SourcePath string // e.g. "/Users/robfig/gocode/src"
+	SourcePath string // e.g. "/Users/robfig/gocode/src" (empty with Go modules)
 
 	Config  *MergedConfig
 	RunMode string // Application-defined (by default, "dev" or "prod")
@@ -33,6 +33,10 @@ var (
 	// Revel installation details
 	RevelPath string // e.g. "/Users/robfig/gocode/src/revel"
 
+	// If true, Revel, the app and its modules were found through the Go
+	// modules required by the app's go.mod, rather than in GOPATH.
+	GoModules bool
+
 	// Where to look for templates and configuration.
 	// Ordered by priority.  (Earlier paths take precedence over later paths.)
 	CodePaths     []string
@@ -74,25 +78,34 @@ var (
 //   importPath - the Go import path of the application.
 //   srcPath - the path to the source directory, containing Revel and the app.
 //     If not specified (""), then a functioning Go installation is required.
+//     When the working directory is within a Go module, Revel and the app are
+//     then found among its requirements, and SourcePath is left empty.
 func Init(mode, importPath, srcPath string) {
 	// Ignore trailing slashes.
 	ImportPath = strings.TrimRight(importPath, "/")
 	SourcePath = srcPath
 	RunMode = mode
 
-	// If the SourcePath is not specified, find it using build.Import.
-	var revelSourcePath string // may be different from the app source path
-	if SourcePath == "" {
-		revelSourcePath, SourcePath = findSrcPaths(importPath)
-	} else {
+	switch {
+	case SourcePath != "":
 		// If the SourcePath was specified, assume both Revel and the app are within it.
 		SourcePath = filepath.Clean(SourcePath)
-		revelSourcePath = SourcePath
+		RevelPath = filepath.Join(SourcePath, filepath.FromSlash(REVEL_IMPORT_PATH))
+		BasePath = filepath.Join(SourcePath, filepath.FromSlash(ImportPath))
 		packaged = true
+	case goModulesEnabled():
+		// Find the app and Revel in the module graph, wherever they are.
+		GoModules = true
+		RevelPath, BasePath = findModulePaths(ImportPath)
+	default:
+		// Find the SourcePath using build.Import.
+		// Revel's may be different from the app source path.
+		var revelSourcePath string
+		revelSourcePath, SourcePath = findSrcPaths(ImportPath)
+		RevelPath = filepath.Join(revelSourcePath, filepath.FromSlash(REVEL_IMPORT_PATH))
+		BasePath = filepath.Join(SourcePath, filepath.FromSlash(ImportPath))
 	}
 
-	RevelPath = filepath.Join(revelSourcePath, filepath.FromSlash(REVEL_IMPORT_PATH))
-	BasePath = filepath.Join(SourcePath, filepath.FromSlash(importPath))
 	AppPath = filepath.Join(BasePath, "app")
 	ViewsPath = filepath.Join(AppPath, "views")
 
@@ -247,6 +260,16 @@ func ResolveImportPath(importPath string) (string, error) {
 	if packaged {
 		return filepath.Join(SourcePath, filepath.FromSlash(importPath)), nil
 	}
+	return FindImportPath(importPath)
+}
+
+// FindImportPath returns the directory of the given import path, among the
+// requirements of the Go module of the working directory if there is one, or in
+// GOPATH.  Unlike ResolveImportPath, it does not require Init to be called.
+func FindImportPath(importPath string) (string, error) {
+	if goModulesEnabled() {
+		return goListDir(importPath)
+	}
 
 	modPkg, err := build.Import(importPath, "", build.FindOnly)
 	if err != nil {
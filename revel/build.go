@@ -42,10 +42,18 @@ func buildApp(args []string) {
 		revel.LoadModules()
 	}
 
+	buildAppTo(destPath, harness.BuildTarget{})
+}
+
+// Build the app for the target into destPath, with everything it needs to run:
+// its run scripts, the files of the app, Revel and its modules.  Returns the
+// name of the binary.
+func buildAppTo(destPath string, target harness.BuildTarget) string {
+	appImportPath := revel.ImportPath
 	os.RemoveAll(destPath)
 	os.MkdirAll(destPath, 0777)
 
-	app, reverr := harness.Build()
+	app, reverr := harness.BuildFor(target)
 	panicOnError(reverr, "Failed to build")
 
 	// Included are:
@@ -86,9 +94,15 @@ func buildApp(args []string) {
 		mustCopyDir(filepath.Join(srcPath, filepath.FromSlash(importPath)), fsPath, nil)
 	}
 
+	// The app runs in the mode it was built for, in prod by default.
+	runMode := revel.RunMode
+	if runMode == "" {
+		runMode = "prod"
+	}
 	tmplData := map[string]interface{}{
 		"BinName":    filepath.Base(app.BinaryPath),
 		"ImportPath": appImportPath,
+		"RunMode":    runMode,
 	}
 
 	mustRenderTemplate(
@@ -100,4 +114,6 @@ func buildApp(args []string) {
 		filepath.Join(destPath, "run.bat"),
 		filepath.Join(revel.RevelPath, "revel", "package_run.bat.template"),
 		tmplData)
+
+	return filepath.Base(app.BinaryPath)
 }
@@ -2,9 +2,10 @@ package main
 
 import (
 	"fmt"
-	"go/build"
 	"os"
 	"path/filepath"
+
+	"github.com/BSP-Mosaic/teltech-revel"
 )
 
 var cmdClean = &Command{
@@ -31,14 +32,14 @@ func cleanApp(args []string) {
 		return
 	}
 
-	appPkg, err := build.Import(args[0], "", build.FindOnly)
+	appDir, err := revel.FindImportPath(args[0])
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Abort: Failed to find import path:", err)
 		return
 	}
 
 	// Remove the app/tmp directory.
-	tmpDir := filepath.Join(appPkg.Dir, "app", "tmp")
+	tmpDir := filepath.Join(appDir, "app", "tmp")
 	fmt.Println("Removing:", tmpDir)
 	err = os.RemoveAll(tmpDir)
 	if err != nil {
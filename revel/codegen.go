@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/BSP-Mosaic/teltech-revel/harness"
+)
+
+var cmdCodegen = &Command{
+	UsageLine: "codegen [import path] [run mode]",
+	Short:     "generate the registrations of a Revel application",
+	Long: `
+Generate a main package for the Revel web application named by the given
+import path, which registers its controllers, test suites and validation keys,
+and the reverse routes of the application.
+
+For example:
+
+    revel codegen github.com/BSP-Mosaic/teltech-revel/samples/chat
+
+It writes main.go in the application directory, and app/routes/routes.go.
+Once committed, the application builds with the go tool alone:
+
+    go build github.com/BSP-Mosaic/teltech-revel/samples/chat
+
+The generated main.go runs "revel codegen" on "go generate", to update it
+after changing the controllers.
+
+The run mode selects the modules whose controllers are registered, and is the
+default run mode of the application.  It defaults to "dev".`,
+}
+
+func init() {
+	cmdCodegen.Run = codegenApp
+}
+
+func codegenApp(args []string) {
+	if len(args) == 0 {
+		errorf("No import path given.\nRun 'revel help codegen' for usage.\n")
+	}
+
+	mode := "dev"
+	if len(args) >= 2 {
+		mode = args[1]
+	}
+
+	revel.Init(mode, args[0], "")
+	revel.LoadModules()
+
+	mainPath, reverr := harness.Generate(mode)
+	panicOnError(reverr, "Failed to generate the registrations")
+	fmt.Println("Generated", mainPath)
+}
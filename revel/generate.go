@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+var cmdGenerate = &Command{
+	UsageLine: "generate [controller|scaffold] [name] [args...]",
+	Short:     "generate controllers, models, views and tests",
+	Long: `
+Generate code in the Revel web application of the current directory.
+
+To generate a controller with the given actions, their views, routes and a
+test suite:
+
+    revel generate controller Users index show create
+
+The actions index, new, show, edit, create, update and delete are routed
+RESTfully (e.g. "GET /users/{id}" for show), and others with "GET /users/name".
+
+To generate a model with the given fields, and a controller, views, routes and
+a test suite to list, show, create, edit and delete them:
+
+    revel generate scaffold Post title:string body:text
+
+The field types are string, text, int, int64, float, bool and time.  The model
+is stored with database/sql through the db module, which must be configured.
+
+The code is generated from the templates in the revel/generate directory.  An
+application may override them with its own, in conf/generate (e.g.
+conf/generate/scaffold/Index.html.template).  Existing files are not
+overwritten.`,
+}
+
+func init() {
+	cmdGenerate.Run = generate
+}
+
+// An action of a generated controller.
+type generateAction struct {
+	Name   string // e.g. "Show"
+	Method string // e.g. "GET"
+	Path   string // e.g. "/users/{id}"
+	HasId  bool   // The action takes the id in the path.
+	View   bool   // The action renders a view.
+}
+
+// A field of a generated model.
+type generateField struct {
+	Name     string // e.g. "CreatedAt"
+	Label    string // e.g. "Created at"
+	Column   string // e.g. "created_at"
+	Type     string // e.g. "time"
+	GoType   string // e.g. "time.Time"
+	SqlType  string // e.g. "TIMESTAMP"
+	Input    string // The form input type, e.g. "datetime-local"
+	Required bool   // The field is validated as required (strings).
+}
+
+// The types of the fields of a scaffold: Go type, SQL type, input type.
+var generateFieldTypes = map[string][3]string{
+	"string": {"string", "VARCHAR(255)", "text"},
+	"text":   {"string", "TEXT", "textarea"},
+	"int":    {"int", "INTEGER", "number"},
+	"int64":  {"int64", "INTEGER", "number"},
+	"float":  {"float64", "REAL", "number"},
+	"bool":   {"bool", "BOOLEAN", "checkbox"},
+	"time":   {"time.Time", "TIMESTAMP", "datetime-local"},
+}
+
+type generateModel struct {
+	Name       string // e.g. "Post"
+	Var        string // e.g. "post"
+	Label      string // e.g. "post"
+	Plural     string // e.g. "posts"
+	PluralName string // e.g. "Posts"
+	Table      string // e.g. "posts"
+	Fields     []generateField
+	NeedsTime  bool
+}
+
+// The arguments of the generator templates.
+type generateData struct {
+	AppImportPath string
+	Name          string // The controller, e.g. "Users"
+	Path          string // The path of its routes, e.g. "/users"
+	Actions       []generateAction
+	Model         *generateModel
+}
+
+func generate(args []string) {
+	if len(args) < 2 {
+		errorf("Nothing to generate.\nRun 'revel help generate' for usage.\n")
+	}
+
+	appDir, err := os.Getwd()
+	panicOnError(err, "Failed to get the working directory")
+	if _, err = os.Stat(filepath.Join(appDir, "conf", "app.conf")); err != nil {
+		errorf("Abort: %s is not a Revel application (no conf/app.conf).", appDir)
+	}
+	revelDir, err := revel.FindImportPath(revel.REVEL_IMPORT_PATH)
+	panicOnError(err, "Failed to find the Revel source code")
+
+	g := &generator{appDir: appDir, revelDir: revelDir}
+	switch args[0] {
+	case "controller":
+		g.controller(args[1], args[2:])
+	case "scaffold":
+		g.scaffold(args[1], args[2:])
+	default:
+		errorf("Unknown generator %s, expected controller or scaffold.", args[0])
+	}
+}
+
+type generator struct {
+	appDir, revelDir string
+}
+
+func (g *generator) controller(name string, actionNames []string) {
+	data := &generateData{Name: exportedName(name), Path: "/" + strings.ToLower(name)}
+	if len(actionNames) == 0 {
+		actionNames = []string{"index"}
+	}
+	for _, actionName := range actionNames {
+		data.Actions = append(data.Actions, restAction(data.Path, actionName))
+	}
+
+	g.write("controller/controller.go", filepath.Join("app", "controllers", strings.ToLower(data.Name)+".go"), data)
+	for _, action := range data.Actions {
+		if action.View {
+			g.write("controller/view.html", filepath.Join("app", "views", data.Name, action.Name+".html"),
+				map[string]interface{}{"Controller": data, "Action": action})
+		}
+	}
+	g.write("controller/test.go", filepath.Join("tests", strings.ToLower(data.Name)+"test.go"), data)
+	g.addRoutes(data)
+}
+
+func (g *generator) scaffold(name string, fieldSpecs []string) {
+	model := &generateModel{Name: exportedName(name)}
+	model.Var = unexportedName(model.Name)
+	model.Label = strings.ToLower(words(model.Name))
+	model.PluralName = plural(model.Name)
+	model.Plural = unexportedName(model.PluralName)
+	model.Table = snakeCase(model.PluralName)
+	for _, spec := range fieldSpecs {
+		field := parseField(spec)
+		model.NeedsTime = model.NeedsTime || field.Type == "time"
+		model.Fields = append(model.Fields, field)
+	}
+	if len(model.Fields) == 0 {
+		errorf("No fields given for %s, e.g. title:string.", model.Name)
+	}
+
+	data := &generateData{
+		AppImportPath: appImportPath(g.appDir),
+		Name:          model.PluralName,
+		Path:          "/" + strings.ToLower(model.PluralName),
+		Model:         model,
+	}
+	for _, actionName := range []string{"index", "new", "create", "show", "edit", "update", "delete"} {
+		data.Actions = append(data.Actions, restAction(data.Path, actionName))
+	}
+
+	g.write("scaffold/model.go", filepath.Join("app", "models", strings.ToLower(model.Name)+".go"), data)
+	g.write("scaffold/controller.go", filepath.Join("app", "controllers", strings.ToLower(data.Name)+".go"), data)
+	for _, view := range []string{"Index", "Show", "New", "Edit", "form"} {
+		g.write("scaffold/"+view+".html", filepath.Join("app", "views", data.Name, view+".html"), data)
+	}
+	g.write("scaffold/test.go", filepath.Join("tests", strings.ToLower(data.Name)+"test.go"), data)
+	g.addRoutes(data)
+
+	fmt.Printf("\nCreate the %s table with models.%sSchema, e.g. in an OnAppStart hook.\n", model.Table, model.Name)
+}
+
+// Return the action of the given name, routed RESTfully under the given path.
+func restAction(path, name string) generateAction {
+	action := generateAction{Name: exportedName(name), Method: "GET", View: true}
+	switch strings.ToLower(name) {
+	case "index":
+		action.Path = path
+	case "new":
+		action.Path = path + "/new"
+	case "show":
+		action.Path, action.HasId = path+"/{id}", true
+	case "edit":
+		action.Path, action.HasId = path+"/{id}/edit", true
+	case "create":
+		action.Method, action.Path, action.View = "POST", path, false
+	case "update":
+		action.Method, action.Path, action.HasId, action.View = "POST", path+"/{id}", true, false
+	case "delete", "destroy":
+		action.Method, action.Path, action.HasId, action.View = "POST", path+"/{id}/delete", true, false
+	default:
+		action.Path = path + "/" + strings.ToLower(name)
+	}
+	return action
+}
+
+// Parse a field of a scaffold, e.g. "title:string".
+func parseField(spec string) generateField {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) == 1 {
+		parts = append(parts, "string")
+	}
+	types, ok := generateFieldTypes[parts[1]]
+	if !ok {
+		errorf("Unknown type %s of field %s, expected string, text, int, int64, float, bool or time.",
+			parts[1], parts[0])
+	}
+	name := exportedName(parts[0])
+	label := words(name)
+	return generateField{
+		Name:     name,
+		Label:    strings.ToUpper(label[:1]) + strings.ToLower(label[1:]),
+		Column:   snakeCase(name),
+		Type:     parts[1],
+		GoType:   types[0],
+		SqlType:  types[1],
+		Input:    types[2],
+		Required: types[0] == "string",
+	}
+}
+
+// The functions of the generator templates.
+var generateFuncs = template.FuncMap{
+	// The number from 1 of the given index, e.g. of a query argument.
+	"inc": func(i int) int { return i + 1 },
+}
+
+// Render the named generator template to the given file of the app, unless it
+// exists.  Go source is formatted.
+func (g *generator) write(name, path string, data interface{}) {
+	fullPath := filepath.Join(g.appDir, path)
+	if _, err := os.Stat(fullPath); err == nil {
+		fmt.Println("Skipping existing", path)
+		return
+	}
+
+	// Look for the template in the app first, so that it may override ours.
+	templatePath := filepath.Join(g.appDir, "conf", "generate", filepath.FromSlash(name)+".template")
+	if _, err := os.Stat(templatePath); err != nil {
+		templatePath = filepath.Join(g.revelDir, "revel", "generate", filepath.FromSlash(name)+".template")
+	}
+	content, err := ioutil.ReadFile(templatePath)
+	panicOnError(err, "Failed to read generator template")
+
+	// The generated views are templates themselves, hence the delimiters.
+	tmpl, err := template.New(name).Delims("[[", "]]").Funcs(generateFuncs).Parse(string(content))
+	panicOnError(err, "Failed to parse generator template "+templatePath)
+	var output bytes.Buffer
+	panicOnError(tmpl.Execute(&output, data), "Failed to render generator template "+templatePath)
+
+	source := output.Bytes()
+	if filepath.Ext(path) == ".go" {
+		source, err = format.Source(source)
+		panicOnError(err, "Failed to format the generated "+path)
+	}
+
+	panicOnError(os.MkdirAll(filepath.Dir(fullPath), 0777), "Failed to create directory")
+	panicOnError(ioutil.WriteFile(fullPath, source, 0666), "Failed to write "+path)
+	fmt.Println("Created", path)
+}
+
+var catchAllRoutePattern = regexp.MustCompile(`^\S+\s+\S+\s+\{controller\}`)
+
+// Add the routes of the controller actions to conf/routes, before the catch-all
+// route if there is one.  Routes with the same method and path are skipped.
+func (g *generator) addRoutes(data *generateData) {
+	routesPath := filepath.Join(g.appDir, "conf", "routes")
+	content, err := ioutil.ReadFile(routesPath)
+	panicOnError(err, "Failed to read the routes")
+	lines := strings.Split(string(content), "\n")
+
+	existing := make(map[string]bool)
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) >= 2 && !strings.HasPrefix(fields[0], "#") {
+			existing[strings.ToUpper(fields[0])+" "+fields[1]] = true
+		}
+	}
+
+	// Route the actions with the id after the others, so that e.g.
+	// /users/new is not shadowed by /users/{id}.
+	actions := append([]generateAction{}, data.Actions...)
+	sort.SliceStable(actions, func(i, j int) bool { return !actions[i].HasId && actions[j].HasId })
+	routes := []string{"# " + data.Name}
+	for _, action := range actions {
+		if existing[action.Method+" "+action.Path] {
+			fmt.Println("Skipping existing route", action.Method, action.Path)
+			continue
+		}
+		routes = append(routes, fmt.Sprintf("%-7s %-39s %s.%s", action.Method, action.Path, data.Name, action.Name))
+	}
+	if len(routes) == 1 {
+		return
+	}
+	routes = append(routes, "")
+
+	// Insert them before the catch-all route, and the comments above it.
+	at := len(lines)
+	for i, line := range lines {
+		if catchAllRoutePattern.MatchString(strings.TrimSpace(line)) {
+			at = i
+			for at > 0 && strings.HasPrefix(strings.TrimSpace(lines[at-1]), "#") {
+				at--
+			}
+			break
+		}
+	}
+	if at == len(lines) && at > 0 && lines[at-1] == "" {
+		// Append before the final newline.
+		at--
+		routes = append([]string{""}, routes[:len(routes)-1]...)
+	}
+	lines = append(lines[:at], append(routes, lines[at:]...)...)
+
+	panicOnError(ioutil.WriteFile(routesPath, []byte(strings.Join(lines, "\n")), 0666),
+		"Failed to write the routes")
+	fmt.Println("Added", len(routes)-2, "routes to conf/routes")
+}
+
+// Return the import path of the app in the given directory, from the go tool.
+func appImportPath(appDir string) string {
+	cmd := exec.Command("go", "list", "-e", "-f", "{{.ImportPath}}", ".")
+	cmd.Dir = appDir
+	output, err := cmd.Output()
+	panicOnError(err, "Failed to find the import path of the application")
+	importPath := strings.TrimSpace(string(output))
+	if strings.HasPrefix(importPath, "_") {
+		errorf("Abort: %s is neither in GOPATH nor in a Go module.", appDir)
+	}
+	return importPath
+}
+
+// Return the name with its first letter upper-cased, and without the
+// underscores and dashes, e.g. "created_at" => "CreatedAt".
+func exportedName(name string) string {
+	var result []rune
+	upper := true
+	for _, r := range name {
+		if r == '_' || r == '-' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		result = append(result, r)
+	}
+	return string(result)
+}
+
+func unexportedName(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// Return the words of a camel-cased name, e.g. "CreatedAt" => "Created At".
+func words(name string) string {
+	var result []rune
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			result = append(result, ' ')
+		}
+		result = append(result, r)
+	}
+	return string(result)
+}
+
+// Return the snake-cased name, e.g. "CreatedAt" => "created_at".
+func snakeCase(name string) string {
+	return strings.ToLower(strings.Replace(words(name), " ", "_", -1))
+}
+
+// Return the (English) plural of the name, e.g. "Category" => "Categories".
+func plural(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case len(lower) > 1 && strings.HasSuffix(lower, "y") && !strings.ContainsAny(lower[len(lower)-2:len(lower)-1], "aeiou"):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	}
+	return name + "s"
+}
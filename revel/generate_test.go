@@ -0,0 +1,214 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateNames(t *testing.T) {
+	for _, test := range []struct {
+		name, exported, snake, plural string
+	}{
+		{"post", "Post", "post", "Posts"},
+		{"created_at", "CreatedAt", "created_at", "CreatedAts"},
+		{"blog-post", "BlogPost", "blog_post", "BlogPosts"},
+		{"category", "Category", "category", "Categories"},
+		{"day", "Day", "day", "Days"},
+		{"box", "Box", "box", "Boxes"},
+		{"bus", "Bus", "bus", "Buses"},
+		{"church", "Church", "church", "Churches"},
+		{"wish", "Wish", "wish", "Wishes"},
+		{"y", "Y", "y", "Ys"},
+	} {
+		exported := exportedName(test.name)
+		if exported != test.exported {
+			t.Errorf("exportedName(%q): expected %q, got %q", test.name, test.exported, exported)
+		}
+		if snake := snakeCase(exported); snake != test.snake {
+			t.Errorf("snakeCase(%q): expected %q, got %q", exported, test.snake, snake)
+		}
+		if plural := plural(exported); plural != test.plural {
+			t.Errorf("plural(%q): expected %q, got %q", exported, test.plural, plural)
+		}
+	}
+}
+
+func TestParseField(t *testing.T) {
+	for spec, expected := range map[string]generateField{
+		"title:string":      {"Title", "Title", "title", "string", "string", "VARCHAR(255)", "text", true},
+		"body":              {"Body", "Body", "body", "string", "string", "VARCHAR(255)", "text", true},
+		"published_at:time": {"PublishedAt", "Published at", "published_at", "time", "time.Time", "TIMESTAMP", "datetime-local", false},
+		"views:int64":       {"Views", "Views", "views", "int64", "int64", "INTEGER", "number", false},
+	} {
+		if field := parseField(spec); field != expected {
+			t.Errorf("%s: expected %+v, got %+v", spec, expected, field)
+		}
+	}
+
+	defer func() {
+		if _, ok := recover().(LoggedError); !ok {
+			t.Error("Expected an error for an unknown type")
+		}
+	}()
+	parseField("title:varchar")
+}
+
+func TestRestAction(t *testing.T) {
+	for name, expected := range map[string]generateAction{
+		"index":  {"Index", "GET", "/users", false, true},
+		"new":    {"New", "GET", "/users/new", false, true},
+		"show":   {"Show", "GET", "/users/{id}", true, true},
+		"edit":   {"Edit", "GET", "/users/{id}/edit", true, true},
+		"create": {"Create", "POST", "/users", false, false},
+		"update": {"Update", "POST", "/users/{id}", true, false},
+		"delete": {"Delete", "POST", "/users/{id}/delete", true, false},
+		"search": {"Search", "GET", "/users/search", false, true},
+	} {
+		if action := restAction("/users", name); action != expected {
+			t.Errorf("%s: expected %+v, got %+v", name, expected, action)
+		}
+	}
+}
+
+// Return a generator of an app in a temporary directory, with the given routes.
+func newTestGenerator(t *testing.T, routes string) *generator {
+	appDir, err := ioutil.TempDir("", "generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(appDir) })
+	if err = os.MkdirAll(filepath.Join(appDir, "conf"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(appDir, "conf", "routes"), []byte(routes), 0666); err != nil {
+		t.Fatal(err)
+	}
+	revelDir, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &generator{appDir: appDir, revelDir: revelDir}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestAddRoutes(t *testing.T) {
+	data := &generateData{Name: "Users", Path: "/users"}
+	for _, name := range []string{"show", "index", "new", "update", "create"} {
+		data.Actions = append(data.Actions, restAction(data.Path, name))
+	}
+
+	for _, test := range []struct {
+		routes, expected string
+	}{
+		// Before the catch-all route and its comments, the routes with the id last,
+		// and those with an existing method and path skipped.
+		{`GET     /                                       App.Index
+get     /users/new                              Users.Create
+
+# Catch all
+*       /{controller}/{action}                  {controller}.{action}
+`, `GET     /                                       App.Index
+get     /users/new                              Users.Create
+
+# Users
+GET     /users                                  Users.Index
+POST    /users                                  Users.Create
+GET     /users/{id}                             Users.Show
+POST    /users/{id}                             Users.Update
+
+# Catch all
+*       /{controller}/{action}                  {controller}.{action}
+`},
+		// At the end without a catch-all route.
+		{`GET     /                                       App.Index
+`, `GET     /                                       App.Index
+
+# Users
+GET     /users                                  Users.Index
+GET     /users/new                              Users.New
+POST    /users                                  Users.Create
+GET     /users/{id}                             Users.Show
+POST    /users/{id}                             Users.Update
+`},
+		// Not at all if they all exist.
+		{`GET /users Users.Index
+GET /users/new Users.New
+POST /users Users.Create
+GET /users/{id} Users.Show
+POST /users/{id} Users.Update
+`, `GET /users Users.Index
+GET /users/new Users.New
+POST /users Users.Create
+GET /users/{id} Users.Show
+POST /users/{id} Users.Update
+`},
+	} {
+		g := newTestGenerator(t, test.routes)
+		g.addRoutes(data)
+		if routes := readTestFile(t, filepath.Join(g.appDir, "conf", "routes")); routes != test.expected {
+			t.Errorf("Expected the routes:\n%s\ngot:\n%s", test.expected, routes)
+		}
+	}
+}
+
+func TestGenerateScaffoldModel(t *testing.T) {
+	g := newTestGenerator(t, "")
+	model := &generateModel{Name: "Post", Var: "post", Plural: "posts", PluralName: "Posts", Table: "posts"}
+	for _, spec := range []string{"title:string", "body:text", "published_at:time"} {
+		model.Fields = append(model.Fields, parseField(spec))
+	}
+	model.NeedsTime = true
+	data := &generateData{Name: "Posts", Path: "/posts", Model: model}
+	path := filepath.Join("app", "models", "post.go")
+	g.write("scaffold/model.go", path, data)
+
+	// The queries have the placeholders of the driver.
+	source := readTestFile(t, filepath.Join(g.appDir, path))
+	for _, query := range []string{
+		`fmt.Sprintf("SELECT %s FROM posts WHERE id = %s", postColumns, db.Placeholder(1))`,
+		`fmt.Sprintf("INSERT INTO posts (title, body, published_at) VALUES (%s, %s, %s)", db.Placeholder(1), db.Placeholder(2), db.Placeholder(3))`,
+		`fmt.Sprintf("UPDATE posts SET title = %s, body = %s, published_at = %s WHERE id = %s", db.Placeholder(1), db.Placeholder(2), db.Placeholder(3), db.Placeholder(4))`,
+		`fmt.Sprintf("DELETE FROM posts WHERE id = %s", db.Placeholder(1))`,
+	} {
+		if !strings.Contains(source, query) {
+			t.Errorf("Expected the query %s in:\n%s", query, source)
+		}
+	}
+	if strings.Contains(source, "?") {
+		t.Errorf("Expected no ? placeholder in:\n%s", source)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), path, source, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imports []string
+	for _, spec := range file.Imports {
+		imports = append(imports, spec.Path.Value)
+	}
+	expected := []string{`"database/sql"`, `"fmt"`, `"time"`, `"github.com/BSP-Mosaic/teltech-revel"`, `"github.com/BSP-Mosaic/teltech-revel/modules/db/app"`}
+	if !reflect.DeepEqual(imports, expected) {
+		t.Errorf("Expected the imports %v, got %v", expected, imports)
+	}
+
+	// An existing file is not overwritten.
+	if err = ioutil.WriteFile(filepath.Join(g.appDir, path), []byte("package models\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	g.write("scaffold/model.go", path, data)
+	if source = readTestFile(t, filepath.Join(g.appDir, path)); source != "package models\n" {
+		t.Errorf("Expected the existing file to be kept, got:\n%s", source)
+	}
+}
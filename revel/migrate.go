@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/BSP-Mosaic/teltech-revel/harness"
+	"github.com/BSP-Mosaic/teltech-revel/modules/db/app/migrate"
+)
+
+var cmdMigrate = &Command{
+	UsageLine: "migrate [-n count] up|down|status|create [import path] [run mode|name]",
+	Short:     "apply, revert, list or create database migrations",
+	Long: `
+Manage the SQL migrations of the Revel web application named by the given
+import path, in its db/migrations directory.  (See the db module)
+
+To apply the pending migrations, or only the first few of them with -n:
+
+    revel migrate up github.com/BSP-Mosaic/teltech-revel/samples/booking dev
+
+To revert the last applied migration, or the last few of them with -n:
+
+    revel migrate -n 2 down github.com/BSP-Mosaic/teltech-revel/samples/booking dev
+
+To list the migrations, and whether they are applied:
+
+    revel migrate status github.com/BSP-Mosaic/teltech-revel/samples/booking dev
+
+The app is built and run against the database of the given run mode (by
+default "dev"), with the driver it imports.  It must call db.Init on startup.
+
+To create the empty up and down files of a new migration, with the next
+version:
+
+    revel migrate create github.com/BSP-Mosaic/teltech-revel/samples/booking add_users`,
+}
+
+var (
+	migrateFlags = flag.NewFlagSet("migrate", flag.ExitOnError)
+	migrateNFlag = migrateFlags.Int("n", 0, "number of migrations to apply or revert (default: all pending, or the last applied)")
+)
+
+func init() {
+	cmdMigrate.Run = migrateApp
+}
+
+func migrateApp(args []string) {
+	args = parseInterleavedFlags(migrateFlags, args)
+	if len(args) < 2 {
+		errorf("No command or import path given.\nRun 'revel help migrate' for usage.\n")
+	}
+	command := args[0]
+
+	if command == "create" {
+		if len(args) < 3 {
+			errorf("No migration name given.\nRun 'revel help migrate' for usage.\n")
+		}
+		revel.Init("dev", args[1], "")
+		upPath, downPath, err := migrate.Create(filepath.Join(revel.BasePath, migrate.Path), args[2])
+		panicOnError(err, "Failed to create the migration")
+		fmt.Println("Created", upPath)
+		fmt.Println("Created", downPath)
+		return
+	}
+	if command != "up" && command != "down" && command != "status" {
+		errorf("Unknown migration command %q.\nRun 'revel help migrate' for usage.\n", command)
+	}
+
+	mode := "dev"
+	if len(args) >= 3 {
+		mode = args[2]
+	}
+	revel.Init(mode, args[1], "")
+	revel.LoadModules()
+
+	// Set working directory to BasePath, to make relative paths convenient and
+	// dependable.
+	if err := os.Chdir(revel.BasePath); err != nil {
+		log.Fatalln("Failed to change directory into app path: ", err)
+	}
+
+	// Run the app, which runs the command from db.Init, and exits.
+	app, reverr := harness.Build()
+	panicOnError(reverr, "Failed to build app")
+	os.Setenv(migrate.Env, command+" "+strconv.Itoa(*migrateNFlag))
+	if err := app.Cmd().Cmd.Run(); err != nil {
+		errorf("Migration failed: %s", err)
+	}
+}
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/BSP-Mosaic/teltech-revel/harness"
 )
 
 var cmdPackage = &Command{
-	UsageLine: "package [import path] [run mode]",
+	UsageLine: "package [-targets os/arch,...] [-docker] [-out path] [import path] [run mode]",
 	Short:     "package a Revel application (e.g. for deployment)",
 	Long: `
 Package the Revel web application named by the given import path.
@@ -24,19 +33,61 @@ For package, run mode defaults to "prod".
 For example:
 
     revel package github.com/BSP-Mosaic/teltech-revel/samples/chat
+
+The -targets flag cross-compiles the application for other platforms, given
+as GOOS/GOARCH, with one archive per platform named after it:
+
+    revel package -targets linux/amd64,linux/arm64 github.com/BSP-Mosaic/teltech-revel/samples/chat
+
+The -docker flag adds a Dockerfile to the archives of linux platforms, to build
+an image of the application from the extracted archive.  Its base image is set
+by -base-image.
+
+The archives are written to the working directory, or to the directory given
+by -out.  They are reproducible: their entries are sorted, and have the same
+owner and modification time, taken from the SOURCE_DATE_EPOCH environment
+variable, or else from the last git commit of the application.
+
+Each archive has a manifest.json, which records the application, run mode,
+platform, git revision and Go version it was built from.
 `,
 }
 
+var (
+	packageFlags         = flag.NewFlagSet("package", flag.ExitOnError)
+	packageTargetsFlag   = packageFlags.String("targets", "", "comma-separated list of GOOS/GOARCH platforms to build for (default: the host's)")
+	packageDockerFlag    = packageFlags.Bool("docker", false, "add a Dockerfile to the archives of linux platforms")
+	packageBaseImageFlag = packageFlags.String("base-image", "gcr.io/distroless/base", "base image of the Dockerfile")
+	packageOutFlag       = packageFlags.String("out", "", "directory to write the archives to (default: the working directory)")
+)
+
 func init() {
 	cmdPackage.Run = packageApp
 }
 
+// The manifest.json of a package.
+type packageManifest struct {
+	App        string `json:"app"`
+	ImportPath string `json:"importPath"`
+	RunMode    string `json:"runMode"`
+	GOOS       string `json:"goos"`
+	GOARCH     string `json:"goarch"`
+	Revision   string `json:"revision,omitempty"` // The git commit of the app.
+	Dirty      bool   `json:"dirty,omitempty"`    // The app had uncommitted changes.
+	GoVersion  string `json:"goVersion,omitempty"`
+	SourceDate string `json:"sourceDate"` // The modification time of the entries.
+}
+
 func packageApp(args []string) {
+	args = parseInterleavedFlags(packageFlags, args)
 	if len(args) == 0 {
 		fmt.Fprint(os.Stderr, cmdPackage.Long)
 		return
 	}
 	appImportPath := args[0]
+	targets := parsePackageTargets(*packageTargetsFlag)
+	outDir, err := filepath.Abs(*packageOutFlag)
+	panicOnError(err, "Invalid archive directory")
 
 	// Determine the run mode.
 	mode := "prod"
@@ -47,18 +98,123 @@ func packageApp(args []string) {
 	revel.Init(mode, appImportPath, "")
 	revel.LoadModules()
 
-	// Remove the archive if it already exists.
-	destFile := filepath.Base(revel.BasePath) + ".tar.gz"
-	os.Remove(destFile)
+	appName := filepath.Base(revel.BasePath)
+	sourceDate := packageSourceDate()
+	manifest := packageManifest{
+		App:        appName,
+		ImportPath: revel.ImportPath,
+		RunMode:    mode,
+		Revision:   commandOutput(revel.BasePath, "git", "rev-parse", "HEAD"),
+		GoVersion:  commandOutput("", "go", "env", "GOVERSION"),
+		SourceDate: sourceDate.UTC().Format(time.RFC3339),
+	}
+	if manifest.Revision != "" {
+		manifest.Dirty = commandOutput(revel.BasePath, "git", "status", "--porcelain", "--untracked-files=no") != ""
+	}
+
+	for _, target := range targets {
+		// Without -targets, the archive is named after the app alone.
+		destFile := appName + ".tar.gz"
+		if *packageTargetsFlag != "" {
+			destFile = fmt.Sprintf("%s-%s-%s.tar.gz", appName, target.GOOS, target.GOARCH)
+		}
+		destFile = filepath.Join(outDir, destFile)
+
+		// Remove the archive if it already exists.
+		os.Remove(destFile)
+
+		// Collect stuff in a temp directory.
+		tmpDir, err := ioutil.TempDir("", appName)
+		panicOnError(err, "Failed to get temp dir")
+
+		binName := buildAppTo(tmpDir, target)
 
-	// Collect stuff in a temp directory.
-	tmpDir, err := ioutil.TempDir("", filepath.Base(revel.BasePath))
-	panicOnError(err, "Failed to get temp dir")
+		manifest.GOOS, manifest.GOARCH = target.GOOS, target.GOARCH
+		content, err := json.MarshalIndent(manifest, "", "  ")
+		panicOnError(err, "Failed to encode the manifest")
+		panicOnError(ioutil.WriteFile(filepath.Join(tmpDir, "manifest.json"), append(content, '\n'), 0666),
+			"Failed to write the manifest")
 
-	buildApp([]string{args[0], tmpDir})
+		if *packageDockerFlag && target.GOOS == "linux" {
+			writeDockerfile(tmpDir, binName, mode)
+		}
 
-	// Create the zip file.
-	archiveName := mustTarGzDir(destFile, tmpDir)
+		// Create the zip file.
+		archiveName := mustTarGzDir(destFile, tmpDir, sourceDate)
+		os.RemoveAll(tmpDir)
 
-	fmt.Println("Your archive is ready:", archiveName)
+		fmt.Println("Your archive is ready:", archiveName)
+	}
+}
+
+// Parse the -targets flag, e.g. "linux/amd64,darwin/arm64", into the build
+// targets.  Without targets, the app is built for the host.
+func parsePackageTargets(targetsFlag string) []harness.BuildTarget {
+	// Build reproducibly: without the paths of the build machine.
+	flags := []string{"-trimpath"}
+	if targetsFlag == "" {
+		goos, goarch := commandOutput("", "go", "env", "GOOS"), commandOutput("", "go", "env", "GOARCH")
+		if goos == "" || goarch == "" {
+			goos, goarch = runtime.GOOS, runtime.GOARCH
+		}
+		return []harness.BuildTarget{{GOOS: goos, GOARCH: goarch, Flags: flags}}
+	}
+
+	var targets []harness.BuildTarget
+	for _, target := range strings.Split(targetsFlag, ",") {
+		parts := strings.Split(strings.TrimSpace(target), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			errorf("Invalid target %s, expected GOOS/GOARCH, e.g. linux/amd64", target)
+		}
+		targets = append(targets, harness.BuildTarget{GOOS: parts[0], GOARCH: parts[1], Flags: flags})
+	}
+	return targets
+}
+
+// Return the modification time of the archive entries: SOURCE_DATE_EPOCH, or
+// the time of the last commit of the app, or else the Unix epoch.
+func packageSourceDate() time.Time {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		epoch = commandOutput(revel.BasePath, "git", "log", "-1", "--format=%ct")
+	}
+	if seconds, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+		return time.Unix(seconds, 0)
+	}
+	return time.Unix(0, 0)
+}
+
+const dockerfileTemplate = `FROM {{.BaseImage}}
+COPY . /app
+WORKDIR /app
+EXPOSE {{.Port}}
+ENTRYPOINT ["/app/{{.BinName}}", "-importPath", "{{.ImportPath}}", "-srcPath", "/app/src", "-runMode", "{{.RunMode}}"]
+`
+
+// Write a Dockerfile to run the app from the extracted archive.
+func writeDockerfile(destPath, binName, mode string) {
+	file, err := os.Create(filepath.Join(destPath, "Dockerfile"))
+	panicOnError(err, "Failed to create the Dockerfile")
+	defer file.Close()
+
+	err = template.Must(template.New("Dockerfile").Parse(dockerfileTemplate)).Execute(file, map[string]interface{}{
+		"BaseImage":  *packageBaseImageFlag,
+		"Port":       revel.HttpPort,
+		"BinName":    binName,
+		"ImportPath": revel.ImportPath,
+		"RunMode":    mode,
+	})
+	panicOnError(err, "Failed to write the Dockerfile")
+}
+
+// Return the trimmed output of the command run in the given directory, or ""
+// if it failed.
+func commandOutput(dir, name string, args ...string) string {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel/harness"
+)
+
+func TestParsePackageTargets(t *testing.T) {
+	flags := []string{"-trimpath"}
+	expected := []harness.BuildTarget{
+		{GOOS: "linux", GOARCH: "amd64", Flags: flags},
+		{GOOS: "darwin", GOARCH: "arm64", Flags: flags},
+	}
+	if targets := parsePackageTargets("linux/amd64, darwin/arm64"); !reflect.DeepEqual(targets, expected) {
+		t.Errorf("Expected %v, got %v", expected, targets)
+	}
+	if targets := parsePackageTargets(""); len(targets) != 1 || targets[0].GOOS == "" || targets[0].GOARCH == "" {
+		t.Errorf("Expected the host target, got %v", targets)
+	}
+
+	for _, targetsFlag := range []string{"linux", "linux/", "/amd64", "linux/amd64/v2", "linux/amd64,", "linux-amd64"} {
+		func() {
+			defer func() {
+				if _, ok := recover().(LoggedError); !ok {
+					t.Errorf("Expected an error for the targets %q", targetsFlag)
+				}
+			}()
+			parsePackageTargets(targetsFlag)
+		}()
+	}
+}
+
+func TestPackageSourceDate(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if date := packageSourceDate(); !date.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected the time of SOURCE_DATE_EPOCH, got %s", date)
+	}
+}
@@ -32,6 +32,10 @@ var commands = []*Command{
 	cmdPackage,
 	cmdClean,
 	cmdTest,
+	cmdCodegen,
+	cmdRoutes,
+	cmdGenerate,
+	cmdMigrate,
 }
 
 func main() {
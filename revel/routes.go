@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/BSP-Mosaic/teltech-revel/harness"
+)
+
+var cmdRoutes = &Command{
+	UsageLine: "routes [import path] [run mode]",
+	Short:     "list and check the routes of a Revel application",
+	Long: `
+List the routes of the Revel web application named by the given import path,
+including the routes of its modules, in the order they are matched.
+
+For example:
+
+    revel routes github.com/BSP-Mosaic/teltech-revel/samples/booking
+
+For each route, it prints the method, path, action, fixed parameters and the
+routes file and line declaring it.  It then warns about the routes that can
+never match, because an earlier route matches all their requests, and about
+the actions that no route refers to.
+
+It exits with an error if a route refers to an action that does not exist, so
+it may run as a check in continuous integration.
+
+The run mode selects the modules whose routes are included, and defaults to
+"dev".`,
+}
+
+func init() {
+	cmdRoutes.Run = listRoutes
+}
+
+func listRoutes(args []string) {
+	if len(args) == 0 {
+		errorf("No import path given.\nRun 'revel help routes' for usage.\n")
+	}
+
+	mode := "dev"
+	if len(args) >= 2 {
+		mode = args[1]
+	}
+
+	revel.Init(mode, args[0], "")
+	revel.LoadModules()
+
+	routes, reverr := revel.ParseRoutesFile(filepath.Join(revel.BasePath, "conf", "routes"))
+	panicOnError(reverr, "Failed to parse the routes")
+
+	// Find the actions of the controllers in the source.
+	sourceInfo, reverr := harness.ProcessSource(revel.CodePaths)
+	panicOnError(reverr, "Failed to process the source")
+	actions := make(map[string]map[string]bool) // controller => action => found
+	var actionNames []string
+	for _, spec := range sourceInfo.ControllerSpecs() {
+		methods := make(map[string]bool)
+		for _, method := range spec.MethodSpecs {
+			methods[strings.ToLower(method.Name)] = true
+			actionNames = append(actionNames, spec.StructName+"."+method.Name)
+		}
+		actions[strings.ToLower(spec.StructName)] = methods
+	}
+	findAction := func(controllerName, methodName string) error {
+		methods, ok := actions[strings.ToLower(controllerName)]
+		if !ok {
+			return fmt.Errorf("failed to find controller %s", controllerName)
+		}
+		if !methods[strings.ToLower(methodName)] {
+			return fmt.Errorf("failed to find action %s.%s", controllerName, methodName)
+		}
+		return nil
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "METHOD\tPATH\tACTION\tPARAMS\tSOURCE")
+	for _, route := range routes {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", route.Method, route.Path, route.Action,
+			strings.Join(route.FixedParams, ", "), routeSource(route))
+	}
+	table.Flush()
+
+	// Warn about the routes shadowed by an earlier route.
+	for i, route := range routes {
+		for _, earlier := range routes[:i] {
+			if earlier.Shadows(route) {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %s %s is unreachable, shadowed by %s %s (%s)\n",
+					routeSource(route), route.Method, route.Path, earlier.Method, earlier.Path, routeSource(earlier))
+				break
+			}
+		}
+	}
+
+	// Warn about the actions that no route refers to.
+	for _, action := range actionNames {
+		if !actionRouted(routes, action) {
+			fmt.Fprintf(os.Stderr, "Warning: no route refers to %s\n", action)
+		}
+	}
+
+	// Fail on the routes to missing actions.
+	var invalid int
+	for _, route := range routes {
+		if err := revel.CheckRoute(route, findAction); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", routeSource(route), err)
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		errorf("%d invalid route(s)", invalid)
+	}
+}
+
+// Return the routes file and line of the route, relative to the app or the
+// module declaring it.
+func routeSource(route *revel.Route) string {
+	file := route.File
+	if rel, ok := relativePath(revel.BasePath, file); ok {
+		file = rel
+	} else {
+		for _, module := range revel.Modules {
+			if rel, ok := relativePath(module.Path, file); ok {
+				file = module.Name + ":" + rel
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("%s:%d", file, route.Line)
+}
+
+func relativePath(basePath, path string) (string, bool) {
+	rel, err := filepath.Rel(basePath, path)
+	return rel, err == nil && !strings.HasPrefix(rel, "..")
+}
+
+var routeArgPattern = regexp.MustCompile(`\{[^}]*\}`)
+
+// Return whether any of the routes refers to the action, e.g. Hotels.Show,
+// either by name or through variables (e.g. "{controller}.{action}").
+func actionRouted(routes []*revel.Route, action string) bool {
+	for _, route := range routes {
+		parts := routeArgPattern.Split(route.Action, -1)
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		pattern := "(?i)^" + strings.Join(parts, "[^.]+") + "$"
+		if matched, _ := regexp.MatchString(pattern, action); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/BSP-Mosaic/teltech-glog"
@@ -18,7 +22,7 @@ import (
 )
 
 var cmdTest = &Command{
-	UsageLine: "test [import path] [run mode] [suite.method]",
+	UsageLine: "test [-format formats] [-out path] [-run regexp] [-parallel n] [-shards n -shard i] [import path] [run mode] [suite.method]",
 	Short:     "run all tests from the command-line",
 	Long: `
 Run all tests for the Revel app named by the given import path.
@@ -40,15 +44,70 @@ For example, to run all of UserTest:
 or one of UserTest's methods:
 
     revel test outspoken test UserTest.Test1
+
+The -run flag selects the tests whose "Suite.Method" name matches the given
+regular expression, across all suites:
+
+    revel test -run 'User.*Login' outspoken test
+
+Results are written to the test-results directory of the app, or to the
+directory given by -out, in the formats listed by -format (by default
+"html,junit,json"):
+
+    html   one HTML page per suite
+    junit  a JUnit XML report, junit.xml
+    json   a JSON summary with the duration, message and stack of each test,
+           results.json
+
+The -parallel flag runs several suites at the same time, against the same
+application.  Suites run in parallel must not depend on shared state.  It is
+refused with the transactional test mode of the db module, which has a single
+test transaction.
+
+The -shards and -shard flags split the suites across several machines, e.g.
+to run the second of three shards:
+
+    revel test -shards 3 -shard 1 outspoken test
+
+The time taken by each suite is printed, along with the slowest suites.
+
+The command exits with a non-zero status if any test failed.
 `,
 }
 
+var (
+	testFlags        = flag.NewFlagSet("test", flag.ExitOnError)
+	testFormatFlag   = testFlags.String("format", "html,junit,json", "comma-separated list of report formats (html, junit, json)")
+	testOutFlag      = testFlags.String("out", "", "directory to write the test results to (default: test-results in the app)")
+	testRunFlag      = testFlags.String("run", "", "only run tests whose Suite.Method name matches this regular expression")
+	testParallelFlag = testFlags.Int("parallel", 1, "number of test suites to run at the same time")
+	testShardsFlag   = testFlags.Int("shards", 1, "number of shards to split the test suites into")
+	testShardFlag    = testFlags.Int("shard", 0, "shard of the test suites to run, from 0 to shards-1")
+)
+
 func init() {
 	cmdTest.Run = testApp
 }
 
 func testApp(args []string) {
 	var err error
+	args = parseInterleavedFlags(testFlags, args)
+	formats := parseReportFormats(*testFormatFlag)
+	var runRegexp *regexp.Regexp
+	if *testRunFlag != "" {
+		if runRegexp, err = regexp.Compile(*testRunFlag); err != nil {
+			errorf("Invalid -run regular expression %s: %s", *testRunFlag, err)
+		}
+	}
+	if *testShardsFlag < 1 || *testShardFlag < 0 || *testShardFlag >= *testShardsFlag {
+		errorf("Invalid -shard %d of -shards %d: the shard must be from 0 to shards-1", *testShardFlag, *testShardsFlag)
+	}
+	var outPath string
+	if *testOutFlag != "" {
+		if outPath, err = filepath.Abs(*testOutFlag); err != nil {
+			errorf("Invalid test result directory %s: %s", *testOutFlag, err)
+		}
+	}
 	if len(args) == 0 {
 		errorf("No import path given.\nRun 'revel help test' for usage.\n")
 	}
@@ -61,6 +120,9 @@ func testApp(args []string) {
 	// Find and parse app.conf
 	revel.Init(mode, args[0], "")
 	revel.LoadModules()
+	if err = checkTestParallel(*testParallelFlag); err != nil {
+		errorf("Invalid -parallel %d: %s", *testParallelFlag, err)
+	}
 
 	// Set working directory to BasePath, to make relative paths convenient and
 	// dependable.
@@ -87,11 +149,16 @@ You can add it to a run mode configuration with the following line:
 	}
 
 	// Create a directory to hold the test result files.
-	resultPath := filepath.Join(revel.BasePath, "test-results")
-	if err = os.RemoveAll(resultPath); err != nil {
-		errorf("Failed to remove test result directory %s: %s", resultPath, err)
+	// The default directory is cleared first; a directory given by -out may hold
+	// other files, which are left alone.
+	resultPath := outPath
+	if resultPath == "" {
+		resultPath = filepath.Join(revel.BasePath, "test-results")
+		if err = os.RemoveAll(resultPath); err != nil {
+			errorf("Failed to remove test result directory %s: %s", resultPath, err)
+		}
 	}
-	if err = os.Mkdir(resultPath, 0777); err != nil {
+	if err = os.MkdirAll(resultPath, 0777); err != nil {
 		errorf("Failed to create test result directory %s: %s", resultPath, err)
 	}
 
@@ -135,6 +202,13 @@ You can add it to a run mode configuration with the following line:
 	if len(args) == 3 {
 		testSuites = filterTestSuites(testSuites, args[2])
 	}
+	if runRegexp != nil {
+		testSuites = filterTestSuitesByRegexp(testSuites, runRegexp)
+	}
+	if *testShardsFlag > 1 {
+		testSuites = shardTestSuites(testSuites, *testShardsFlag, *testShardFlag)
+		fmt.Printf("\nRunning shard %d of %d.", *testShardFlag, *testShardsFlag)
+	}
 	fmt.Printf("\n%d test suite%s to run.\n", len(testSuites), pluralize(len(testSuites), "", "s"))
 	fmt.Println()
 
@@ -149,49 +223,51 @@ You can add it to a run mode configuration with the following line:
 		errorf("Failed to load suite result template: %s", err)
 	}
 
-	// Run each suite.
+	// Run the suites, several at a time if requested.
 	var (
 		overallSuccess = true
 		failedResults  []controllers.TestSuiteResult
+		suiteResults   = make([]controllers.TestSuiteResult, len(testSuites))
+		runStart       = time.Now()
+		printLock      sync.Mutex
+		wg             sync.WaitGroup
+		next           = make(chan int)
 	)
-	for _, suite := range testSuites {
-		// Print the name of the suite we're running.
-		name := suite.Name
-		if len(name) > 22 {
-			name = name[:19] + "..."
-		}
-		fmt.Printf("%-22s", name)
-
-		// Run every test.
-		startTime := time.Now()
-		suiteResult := controllers.TestSuiteResult{Name: suite.Name, Passed: true}
-		for _, test := range suite.Tests {
-			testUrl := baseUrl + "/@tests/" + suite.Name + "/" + test.Name
-			resp, err := http.Get(testUrl)
-			if err != nil {
-				errorf("Failed to fetch test result at url %s: %s", testUrl, err)
+	for i := 0; i < *testParallelFlag; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range next {
+				suiteResults[index] = runSuite(baseUrl, testSuites[index])
+
+				// Print result.  (Just PASSED or FAILED, and the time taken)
+				printLock.Lock()
+				printSuiteResult(suiteResults[index])
+				printLock.Unlock()
 			}
-			defer resp.Body.Close()
+		}()
+	}
+	for index := range testSuites {
+		next <- index
+	}
+	close(next)
+	wg.Wait()
+	runEnd := time.Now()
 
-			var testResult controllers.TestResult
-			json.NewDecoder(resp.Body).Decode(&testResult)
-			if !testResult.Passed {
-				suiteResult.Passed = false
-			}
-			suiteResult.Results = append(suiteResult.Results, testResult)
-		}
+	for _, suiteResult := range suiteResults {
 		overallSuccess = overallSuccess && suiteResult.Passed
-
-		// Print result.  (Just PASSED or FAILED, and the time taken)
-		suiteResultStr, suiteAlert := "PASSED", ""
+		suiteResultStr := "passed"
 		if !suiteResult.Passed {
-			suiteResultStr, suiteAlert = "FAILED", "!"
+			suiteResultStr = "failed"
 			failedResults = append(failedResults, suiteResult)
 		}
-		fmt.Printf("%8s%3s%6ds\n", suiteResultStr, suiteAlert, int(time.Since(startTime).Seconds()))
+
 		// Create the result HTML file.
+		if !formats[reportHtml] {
+			continue
+		}
 		suiteResultFilename := filepath.Join(resultPath,
-			fmt.Sprintf("%s.%s.html", suite.Name, strings.ToLower(suiteResultStr)))
+			fmt.Sprintf("%s.%s.html", suiteResult.Name, suiteResultStr))
 		suiteResultFile, err := os.Create(suiteResultFilename)
 		if err != nil {
 			errorf("Failed to create result file %s: %s", suiteResultFilename, err)
@@ -199,6 +275,16 @@ You can add it to a run mode configuration with the following line:
 		if err = resultTemplate.Execute(suiteResultFile, suiteResult); err != nil {
 			errorf("Failed to render result template: %s", err)
 		}
+		suiteResultFile.Close()
+	}
+	printSlowestSuites(suiteResults, runEnd.Sub(runStart))
+
+	// Write the machine-readable reports.
+	if formats[reportJunit] {
+		writeJunitReport(filepath.Join(resultPath, "junit.xml"), suiteResults, runStart, runEnd)
+	}
+	if formats[reportJson] {
+		writeJsonReport(filepath.Join(resultPath, "results.json"), suiteResults, runStart, runEnd)
 	}
 
 	fmt.Println()
@@ -220,6 +306,115 @@ You can add it to a run mode configuration with the following line:
 	}
 }
 
+// Run the tests of the suite, between its BeforeAll and AfterAll methods.
+func runSuite(baseUrl string, suite controllers.TestSuiteDesc) controllers.TestSuiteResult {
+	startTime := time.Now()
+	suiteResult := controllers.TestSuiteResult{Name: suite.Name, Passed: true}
+	if hookResult := fetchTestResult(baseUrl+"/@tests/"+suite.Name+".beforeAll", "BeforeAll"); !hookResult.Passed {
+		// The tests can not run without their setup; report the failure instead.
+		suiteResult.Passed = false
+		suiteResult.Results = append(suiteResult.Results, hookResult)
+		suite.Tests = nil
+	}
+	for _, test := range suite.Tests {
+		testResult := fetchTestResult(baseUrl+"/@tests/"+suite.Name+"/"+test.Name, test.Name)
+		if !testResult.Passed {
+			suiteResult.Passed = false
+		}
+		suiteResult.Results = append(suiteResult.Results, testResult)
+	}
+	if hookResult := fetchTestResult(baseUrl+"/@tests/"+suite.Name+".afterAll", "AfterAll"); !hookResult.Passed {
+		suiteResult.Passed = false
+		suiteResult.Results = append(suiteResult.Results, hookResult)
+	}
+	suiteResult.Duration = time.Since(startTime)
+	return suiteResult
+}
+
+// Request a test (or suite hook) result from the test runner.
+func fetchTestResult(url, name string) controllers.TestResult {
+	resp, err := http.Get(url)
+	if err != nil {
+		message := fmt.Sprintf("Failed to fetch test result at url %s: %s", url, err)
+		return controllers.TestResult{Name: name, ErrorSummary: message}
+	}
+	defer resp.Body.Close()
+
+	var result controllers.TestResult
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		message := fmt.Sprintf("Failed to decode test result at url %s: %s", url, err)
+		return controllers.TestResult{Name: name, ErrorSummary: message}
+	}
+	return result
+}
+
+// Print the name of the suite, whether it passed and the time taken.
+func printSuiteResult(suiteResult controllers.TestSuiteResult) {
+	name := suiteResult.Name
+	if len(name) > 22 {
+		name = name[:19] + "..."
+	}
+	suiteResultStr, suiteAlert := "PASSED", ""
+	if !suiteResult.Passed {
+		suiteResultStr, suiteAlert = "FAILED", "!"
+	}
+	fmt.Printf("%-22s%8s%3s%9.2fs\n", name, suiteResultStr, suiteAlert, suiteResult.Duration.Seconds())
+}
+
+// The number of slowest suites listed after a run.
+const slowestSuitesCount = 5
+
+// Print the total time taken, and the slowest suites if there are several.
+func printSlowestSuites(suiteResults []controllers.TestSuiteResult, total time.Duration) {
+	fmt.Printf("\nRan %d test suite%s in %.2fs.\n",
+		len(suiteResults), pluralize(len(suiteResults), "", "s"), total.Seconds())
+	if len(suiteResults) <= 1 {
+		return
+	}
+
+	slowest := make([]controllers.TestSuiteResult, len(suiteResults))
+	copy(slowest, suiteResults)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].Duration > slowest[j].Duration
+	})
+	if len(slowest) > slowestSuitesCount {
+		slowest = slowest[:slowestSuitesCount]
+	}
+	fmt.Println("Slowest suites:")
+	for _, suiteResult := range slowest {
+		fmt.Printf("  %-40s%9.2fs\n", suiteResult.Name, suiteResult.Duration.Seconds())
+	}
+}
+
+// Return an error if the suites of the app may not run n at a time.
+func checkTestParallel(n int) error {
+	if n < 1 {
+		return fmt.Errorf("at least one suite must run at a time")
+	}
+	if n > 1 && revel.Config.BoolDefault("db.test.transactional", false) {
+		return fmt.Errorf("the suites would share the test transaction of db.test.transactional")
+	}
+	return nil
+}
+
+// Keep the suites of the given shard (numbered from 0), splitting the suites
+// by name in round-robin so that every machine gets the same split.
+func shardTestSuites(suites []controllers.TestSuiteDesc, shards, shard int) []controllers.TestSuiteDesc {
+	sorted := make([]controllers.TestSuiteDesc, len(suites))
+	copy(sorted, suites)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var selected []controllers.TestSuiteDesc
+	for i, suite := range sorted {
+		if i%shards == shard {
+			selected = append(selected, suite)
+		}
+	}
+	return selected
+}
+
 func writeResultFile(resultPath, name, content string) {
 	if err := ioutil.WriteFile(filepath.Join(resultPath, name), []byte(content), 0666); err != nil {
 		errorf("Failed to write result file %s: %s", filepath.Join(resultPath, name), err)
@@ -269,3 +464,24 @@ func filterTestSuites(suites []controllers.TestSuiteDesc, suiteArgument string)
 	errorf("Couldn't find test suite %s", suiteName)
 	return nil
 }
+
+// Filters the tests of the suites to those whose "Suite.Method" name matches
+// the regular expression, dropping the suites left without tests.
+func filterTestSuitesByRegexp(suites []controllers.TestSuiteDesc, run *regexp.Regexp) []controllers.TestSuiteDesc {
+	var filtered []controllers.TestSuiteDesc
+	for _, suite := range suites {
+		var tests []controllers.TestDesc
+		for _, test := range suite.Tests {
+			if run.MatchString(suite.Name + "." + test.Name) {
+				tests = append(tests, test)
+			}
+		}
+		if len(tests) > 0 {
+			filtered = append(filtered, controllers.TestSuiteDesc{Name: suite.Name, Tests: tests})
+		}
+	}
+	if len(filtered) == 0 {
+		errorf("No tests match %s", run)
+	}
+	return filtered
+}
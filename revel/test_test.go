@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+	"github.com/BSP-Mosaic/teltech-revel/modules/testrunner/app/controllers"
+)
+
+func TestShardTestSuites(t *testing.T) {
+	var suites []controllers.TestSuiteDesc
+	for i := 9; i >= 0; i-- {
+		suites = append(suites, controllers.TestSuiteDesc{Name: fmt.Sprintf("Suite%d", i)})
+	}
+
+	for _, shards := range []int{1, 3, 4, 10, 12} {
+		seen := make(map[string]int)
+		for shard := 0; shard < shards; shard++ {
+			selected := shardTestSuites(suites, shards, shard)
+			if len(selected) < len(suites)/shards || len(selected) > len(suites)/shards+1 {
+				t.Errorf("%d shards: shard %d has %d suites", shards, shard, len(selected))
+			}
+			for _, suite := range selected {
+				if other, ok := seen[suite.Name]; ok {
+					t.Errorf("%d shards: %s is in both shard %d and shard %d", shards, suite.Name, other, shard)
+				}
+				seen[suite.Name] = shard
+			}
+
+			// The same shard is selected on every machine, whatever the order of the suites.
+			reversed := make([]controllers.TestSuiteDesc, len(suites))
+			for i, suite := range suites {
+				reversed[len(suites)-1-i] = suite
+			}
+			if again := shardTestSuites(reversed, shards, shard); !reflect.DeepEqual(again, selected) {
+				t.Errorf("%d shards: shard %d differs by order: %v and %v", shards, shard, selected, again)
+			}
+		}
+		if len(seen) != len(suites) {
+			t.Errorf("%d shards: %d of %d suites run", shards, len(seen), len(suites))
+		}
+	}
+}
+
+func TestCheckTestParallel(t *testing.T) {
+	defer func(config *revel.MergedConfig) { revel.Config = config }(revel.Config)
+	revel.Config = revel.NewEmptyConfig()
+
+	for _, test := range []struct {
+		parallel      int
+		transactional bool
+		valid         bool
+	}{
+		{1, false, true},
+		{4, false, true},
+		{1, true, true},
+		{4, true, false},
+		{0, false, false},
+	} {
+		revel.Config.SetOption("db.test.transactional", fmt.Sprint(test.transactional))
+		if err := checkTestParallel(test.parallel); (err == nil) != test.valid {
+			t.Errorf("-parallel %d with db.test.transactional=%v: expected valid %v, got %v", test.parallel, test.transactional, test.valid, err)
+		}
+	}
+}
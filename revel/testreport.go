@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel/modules/testrunner/app/controllers"
+)
+
+// The test report formats written by "revel test".
+const (
+	reportHtml  = "html"
+	reportJunit = "junit"
+	reportJson  = "json"
+)
+
+// Parse a comma-separated list of report formats.
+func parseReportFormats(formats string) map[string]bool {
+	result := make(map[string]bool)
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		switch format {
+		case "":
+		case reportHtml, reportJunit, reportJson:
+			result[format] = true
+		default:
+			errorf("Unknown test report format %s, expected html, junit or json", format)
+		}
+	}
+	return result
+}
+
+// The JUnit XML report, as understood by CI servers.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Stack   string `xml:",chardata"`
+}
+
+// The JSON summary of a test run.
+type jsonTestReport struct {
+	Passed   bool            `json:"passed"`
+	Tests    int             `json:"tests"`
+	Failures int             `json:"failures"`
+	Duration float64         `json:"duration"` // In seconds
+	Suites   []jsonTestSuite `json:"suites"`
+}
+
+type jsonTestSuite struct {
+	Name     string           `json:"name"`
+	Passed   bool             `json:"passed"`
+	Duration float64          `json:"duration"`
+	Tests    []jsonTestResult `json:"tests"`
+}
+
+type jsonTestResult struct {
+	Name     string  `json:"name"`
+	Passed   bool    `json:"passed"`
+	Duration float64 `json:"duration"`
+	Message  string  `json:"message,omitempty"`
+	Stack    string  `json:"stack,omitempty"`
+}
+
+// Write the JUnit XML report of the suite results to the given file.  The
+// total time is the wall-clock time of the run, from start to end, which is less
+// than the sum of the suite times when they run in parallel.
+func writeJunitReport(path string, results []controllers.TestSuiteResult, start, end time.Time) {
+	report := junitTestSuites{}
+	for _, suiteResult := range results {
+		suite := junitTestSuite{
+			Name:      suiteResult.Name,
+			Time:      junitSeconds(suiteResult.Duration),
+			Timestamp: start.Format("2006-01-02T15:04:05"),
+		}
+		for _, result := range suiteResult.Results {
+			testCase := junitTestCase{
+				ClassName: suiteResult.Name,
+				Name:      result.Name,
+				Time:      junitSeconds(result.Duration),
+			}
+			if !result.Passed {
+				testCase.Failure = &junitFailure{
+					Message: strings.TrimSpace(result.ErrorSummary),
+					Type:    "failure",
+					Stack:   result.ErrorStack,
+				}
+				suite.Failures++
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Time = junitSeconds(end.Sub(start))
+
+	output, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		errorf("Failed to encode the JUnit report: %s", err)
+	}
+	writeReport(path, append([]byte(xml.Header), output...))
+}
+
+// Write the JSON summary of the suite results to the given file, with the
+// wall-clock duration of the run from start to end.
+func writeJsonReport(path string, results []controllers.TestSuiteResult, start, end time.Time) {
+	report := jsonTestReport{Passed: true}
+	for _, suiteResult := range results {
+		suite := jsonTestSuite{
+			Name:     suiteResult.Name,
+			Passed:   suiteResult.Passed,
+			Duration: suiteResult.Duration.Seconds(),
+			Tests:    []jsonTestResult{},
+		}
+		for _, result := range suiteResult.Results {
+			suite.Tests = append(suite.Tests, jsonTestResult{
+				Name:     result.Name,
+				Passed:   result.Passed,
+				Duration: result.Duration.Seconds(),
+				Message:  strings.TrimSpace(result.ErrorSummary),
+				Stack:    result.ErrorStack,
+			})
+			report.Tests++
+			if !result.Passed {
+				report.Failures++
+			}
+		}
+		report.Passed = report.Passed && suiteResult.Passed
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Duration = end.Sub(start).Seconds()
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		errorf("Failed to encode the JSON report: %s", err)
+	}
+	writeReport(path, output)
+}
+
+func writeReport(path string, content []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		errorf("Failed to create test report directory %s: %s", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(path, content, 0666); err != nil {
+		errorf("Failed to write test report %s: %s", path, err)
+	}
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
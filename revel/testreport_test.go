@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel/modules/testrunner/app/controllers"
+)
+
+// Two suites run in parallel, each taking 2s of a 3s run.
+var reportResults = []controllers.TestSuiteResult{
+	{
+		Name:   "ApplicationTest",
+		Passed: true,
+		Results: []controllers.TestResult{
+			{Name: "TestIndex", Passed: true, Duration: 1500 * time.Millisecond},
+			{Name: "TestLogin", Passed: true, Duration: 500 * time.Millisecond},
+		},
+		Duration: 2 * time.Second,
+	},
+	{
+		Name:   "HotelsTest",
+		Passed: false,
+		Results: []controllers.TestResult{
+			{Name: "TestShow", Passed: true, Duration: time.Second},
+			{Name: "TestBook", Passed: false, ErrorSummary: " Status differs \n", ErrorStack: "hotels_test.go:42", Duration: time.Second},
+		},
+		Duration: 2 * time.Second,
+	},
+}
+
+var (
+	reportStart = time.Date(2014, 3, 1, 10, 30, 0, 0, time.UTC)
+	reportEnd   = reportStart.Add(3 * time.Second)
+)
+
+func tempReportDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "revel-test-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestWriteJunitReport(t *testing.T) {
+	dir := tempReportDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test-results", "junit.xml")
+	writeJunitReport(path, reportResults, reportStart, reportEnd)
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report junitTestSuites
+	if err = xml.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Invalid JUnit report: %s\n%s", err, content)
+	}
+
+	if report.Tests != 4 || report.Failures != 1 {
+		t.Errorf("Expected 4 tests and 1 failure, got %d and %d", report.Tests, report.Failures)
+	}
+	if report.Time != "3.000" {
+		t.Errorf("Expected the wall-clock time of the run, got %s", report.Time)
+	}
+	if len(report.Suites) != 2 {
+		t.Fatalf("Expected 2 suites, got %d", len(report.Suites))
+	}
+
+	suite := report.Suites[1]
+	if suite.Name != "HotelsTest" || suite.Tests != 2 || suite.Failures != 1 || suite.Time != "2.000" {
+		t.Errorf("Unexpected suite %+v", suite)
+	}
+	if suite.Timestamp != "2014-03-01T10:30:00" {
+		t.Errorf("Expected the start of the run as timestamp, got %s", suite.Timestamp)
+	}
+	if suite.Cases[0].Failure != nil {
+		t.Errorf("Expected no failure for a passed test, got %+v", suite.Cases[0].Failure)
+	}
+	failure := suite.Cases[1].Failure
+	if failure == nil || failure.Message != "Status differs" || failure.Stack != "hotels_test.go:42" {
+		t.Errorf("Unexpected failure %+v", failure)
+	}
+	if suite.Cases[1].ClassName != "HotelsTest" || suite.Cases[1].Time != "1.000" {
+		t.Errorf("Unexpected test case %+v", suite.Cases[1])
+	}
+}
+
+func TestWriteJsonReport(t *testing.T) {
+	dir := tempReportDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.json")
+	writeJsonReport(path, reportResults, reportStart, reportEnd)
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report jsonTestReport
+	if err = json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Invalid JSON report: %s\n%s", err, content)
+	}
+
+	if report.Passed || report.Tests != 4 || report.Failures != 1 {
+		t.Errorf("Expected a failed run of 4 tests with 1 failure, got %+v", report)
+	}
+	if report.Duration != 3 {
+		t.Errorf("Expected the wall-clock duration of the run, got %v", report.Duration)
+	}
+	if len(report.Suites) != 2 || !report.Suites[0].Passed || report.Suites[1].Passed {
+		t.Fatalf("Unexpected suites %+v", report.Suites)
+	}
+
+	expected := jsonTestResult{Name: "TestBook", Duration: 1, Message: "Status differs", Stack: "hotels_test.go:42"}
+	if actual := report.Suites[1].Tests[1]; actual != expected {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+	if actual := report.Suites[0].Tests[0]; actual.Message != "" || actual.Stack != "" {
+		t.Errorf("Expected no message for a passed test, got %+v", actual)
+	}
+}
+
+func TestParseReportFormats(t *testing.T) {
+	expected := map[string]bool{reportHtml: true, reportJunit: true}
+	if formats := parseReportFormats(" JUnit,html,,"); !reflect.DeepEqual(formats, expected) {
+		t.Errorf("Expected %v, got %v", expected, formats)
+	}
+}
@@ -3,12 +3,15 @@ package main
 import (
 	"archive/tar"
 	"compress/gzip"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/BSP-Mosaic/teltech-revel"
 )
@@ -95,7 +98,9 @@ func mustCopyDir(destDir, srcDir string, data map[string]interface{}) error {
 	})
 }
 
-func mustTarGzDir(destFilename, srcDir string) string {
+// mustTarGzDir archives the files of srcDir, reproducibly: in the order of
+// their paths, with the given modification time, and without their owner.
+func mustTarGzDir(destFilename, srcDir string, modTime time.Time) string {
 	zipFile, err := os.Create(destFilename)
 	panicOnError(err, "Failed to create archive")
 	defer zipFile.Close()
@@ -106,28 +111,55 @@ func mustTarGzDir(destFilename, srcDir string) string {
 	tarWriter := tar.NewWriter(gzipWriter)
 	defer tarWriter.Close()
 
+	var srcPaths []string
 	filepath.Walk(srcDir, func(srcPath string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
+		panicOnError(err, "Failed to read source directory")
+		if !info.IsDir() {
+			srcPaths = append(srcPaths, srcPath)
 		}
+		return nil
+	})
+	sort.Strings(srcPaths)
 
-		srcFile, err := os.Open(srcPath)
+	for _, srcPath := range srcPaths {
+		info, err := os.Stat(srcPath)
 		panicOnError(err, "Failed to read source file")
-		defer srcFile.Close()
 
+		// Only keep whether the file is executable.
+		mode := int64(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
 		err = tarWriter.WriteHeader(&tar.Header{
-			Name:    strings.TrimLeft(srcPath[len(srcDir):], string(os.PathSeparator)),
-			Size:    info.Size(),
-			Mode:    int64(info.Mode()),
-			ModTime: info.ModTime(),
+			Name:     filepath.ToSlash(strings.TrimLeft(srcPath[len(srcDir):], string(os.PathSeparator))),
+			Size:     info.Size(),
+			Mode:     mode,
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
 		})
 		panicOnError(err, "Failed to write tar entry header")
 
+		srcFile, err := os.Open(srcPath)
+		panicOnError(err, "Failed to read source file")
 		_, err = io.Copy(tarWriter, srcFile)
 		panicOnError(err, "Failed to copy")
-
-		return nil
-	})
+		srcFile.Close()
+	}
 
 	return zipFile.Name()
 }
+
+// Parse the flags of a command, which may appear before, between or after its
+// arguments, and return the arguments.
+func parseInterleavedFlags(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Write the files of a directory, in the given order, with the given modes.
+func writeTestDir(t *testing.T, files map[string]os.FileMode, order []string) string {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for _, name := range order {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte("content of "+name), files[name]); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Chmod(path, files[name]); err != nil {
+			t.Fatal(err)
+		}
+		// Files written at different times.
+		modTime := time.Now().Add(-time.Duration(len(order)) * time.Hour)
+		if err = os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestMustTarGzDir(t *testing.T) {
+	files := map[string]os.FileMode{
+		"app":                0700,
+		"run.sh":             0775,
+		"src/conf/app.conf":  0600,
+		"src/public/app.css": 0664,
+	}
+	modTime := time.Unix(1700000000, 0)
+	var archives [][]byte
+	for _, order := range [][]string{
+		{"app", "run.sh", "src/conf/app.conf", "src/public/app.css"},
+		{"src/public/app.css", "src/conf/app.conf", "run.sh", "app"},
+	} {
+		dir := writeTestDir(t, files, order)
+		archive := mustTarGzDir(filepath.Join(dir, "..", filepath.Base(dir)+".tar.gz"), dir, modTime)
+		defer os.Remove(archive)
+		content, err := ioutil.ReadFile(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives = append(archives, content)
+	}
+
+	// The same files give the same archive, whatever their order and times.
+	if !bytes.Equal(archives[0], archives[1]) {
+		t.Error("Expected the archives of the same files to be identical")
+	}
+
+	// The entries are in the order of their paths, with the given time, no owner
+	// and only whether they are executable.
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archives[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		mode := int64(0644)
+		if files[header.Name]&0100 != 0 {
+			mode = 0755
+		}
+		if header.Mode != mode || !header.ModTime.Equal(modTime) || header.Uid != 0 || header.Gid != 0 || header.Uname != "" || header.Gname != "" {
+			t.Errorf("%s: unexpected header %+v", header.Name, header)
+		}
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "content of "+header.Name {
+			t.Errorf("%s: unexpected content %q", header.Name, content)
+		}
+	}
+	if expected := []string{"app", "run.sh", "src/conf/app.conf", "src/public/app.css"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected the entries %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,152 @@
+// Package reveltest runs the test suites of a Revel application with "go test",
+// their requests handled in-process.  It is separate from the revel package so
+// that the applications do not link the testing package.
+package reveltest
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+// RunTestSuites runs the given test suites (pointers to structs embedding
+// revel.TestSuite, or all the revel.TestSuites if none are given) one after the
+// other.  Each suite is a subtest, and each of its test methods a subtest of the
+// suite, which fails if the test panics, e.g. on a failed assertion.  The
+// application must be initialized first.  For example:
+//
+//	func TestApp(t *testing.T) {
+//		revel.Init("dev", "github.com/me/myapp", "")
+//		revel.InitInProcess()
+//		reveltest.RunTestSuites(t)
+//	}
+//
+// As with "revel test", the BeforeAll and AfterAll methods of a suite are
+// called around its tests, and the test hooks and the Before and After methods
+// around each test.
+func RunTestSuites(t *testing.T, suites ...interface{}) {
+	runTestSuites(t, false, suites)
+}
+
+// RunParallelTestSuites runs the given test suites like RunTestSuites, but in
+// parallel with each other: as many at once as the -parallel flag of "go test"
+// allows.  The tests of a suite still run one after the other.
+//
+// The suites are parallel subtests, which run once the calling test returns:
+// clean up after them with t.Cleanup rather than defer.  They must not depend on
+// state of the application that the others change, e.g. the transactional test
+// mode of the db module, which has one test transaction at a time.
+func RunParallelTestSuites(t *testing.T, suites ...interface{}) {
+	runTestSuites(t, true, suites)
+}
+
+func runTestSuites(t *testing.T, parallel bool, suites []interface{}) {
+	if len(suites) == 0 {
+		suites = revel.TestSuites
+	}
+	for _, suite := range suites {
+		suiteType := reflect.TypeOf(suite).Elem()
+		t.Run(suiteType.Name(), func(t *testing.T) {
+			if parallel {
+				t.Parallel()
+			}
+			shared := reflect.New(suiteType)
+			shared.Elem().FieldByName("TestSuite").Set(reflect.ValueOf(revel.NewInProcessTestSuite()))
+			if err := runSuiteHook(shared, "BeforeAll"); err != nil {
+				t.Fatal("BeforeAll: ", err)
+			}
+			defer func() {
+				if err := runSuiteHook(shared, "AfterAll"); err != nil {
+					t.Error("AfterAll: ", err)
+				}
+			}()
+
+			for _, name := range testMethods(suiteType) {
+				name := name
+				t.Run(name, func(t *testing.T) {
+					if err := runSuiteTest(shared, name); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+// Return the names of the test methods of the suite: those starting with
+// "Test", with no arguments nor results.
+func testMethods(suiteType reflect.Type) []string {
+	var names []string
+	ptrType := reflect.PtrTo(suiteType)
+	for i := 0; i < ptrType.NumMethod(); i++ {
+		m := ptrType.Method(i)
+		if strings.HasPrefix(m.Name, "Test") && m.Type.NumIn() == 1 && m.Type.NumOut() == 0 {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+// Call the BeforeAll or AfterAll method of the suite, if it has one.
+func runSuiteHook(suite reflect.Value, hook string) error {
+	m := suite.MethodByName(hook)
+	if !m.IsValid() {
+		return nil
+	}
+	return recoverTest(func() { m.Call(nil) })
+}
+
+// Run a test on a copy of the suite, so that it sees the fields set by
+// BeforeAll, with a new TestSuite.
+func runSuiteTest(shared reflect.Value, name string) error {
+	suite := reflect.New(shared.Elem().Type())
+	suite.Elem().Set(shared.Elem())
+	suite.Elem().FieldByName("TestSuite").Set(reflect.ValueOf(revel.NewInProcessTestSuite()))
+	return recoverTest(func() {
+		for _, hook := range revel.BeforeTestHooks {
+			hook()
+		}
+		defer func() {
+			for _, hook := range revel.AfterTestHooks {
+				hook()
+			}
+		}()
+
+		if m := suite.MethodByName("Before"); m.IsValid() {
+			m.Call(nil)
+		}
+		suite.MethodByName(name).Call(nil)
+		if m := suite.MethodByName("After"); m.IsValid() {
+			m.Call(nil)
+		}
+	})
+}
+
+// Call f, returning its panic as an error: the failed assertion, or else the
+// panic along with where it happened.
+func recoverTest(f func()) (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if _, isRuntimeError := recovered.(runtime.Error); !isRuntimeError {
+			if assertion, ok := recovered.(error); ok {
+				err = assertion
+				return
+			}
+		}
+		if revelError := revel.NewErrorFromPanic(recovered); revelError != nil {
+			err = fmt.Errorf("%s\nIn %s (around line %d)\n%s", revelError.Description, revelError.Path, revelError.Line, revelError.Stack)
+		} else {
+			err = fmt.Errorf("%v\n%s", recovered, debug.Stack())
+		}
+	}()
+	f()
+	return nil
+}
@@ -0,0 +1,175 @@
+package reveltest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BSP-Mosaic/teltech-revel"
+)
+
+type Hotel struct {
+	HotelId int
+	Address string
+}
+
+type Hotels struct {
+	*revel.Controller
+}
+
+func (c Hotels) Show(id int) revel.Result {
+	return c.RenderText("Hotel %d, 300 Main St.", id)
+}
+
+func (c Hotels) Book(id int) revel.Result {
+	return c.RenderJson(Hotel{id, "300 Main St."})
+}
+
+var startOnce sync.Once
+
+// Start the booking app in-process, with the routes of the Hotels controller.
+func startHotelsApp(t *testing.T) {
+	startOnce.Do(func() {
+		revel.Init("prod", "github.com/BSP-Mosaic/teltech-revel/samples/booking", "")
+
+		revel.RegisterController((*Hotels)(nil), []*revel.MethodType{
+			{Name: "Show", Args: []*revel.MethodArg{{Name: "id", Type: reflect.TypeOf((*int)(nil))}}},
+			{Name: "Book", Args: []*revel.MethodArg{{Name: "id", Type: reflect.TypeOf((*int)(nil))}}},
+		})
+		revel.InitInProcess()
+
+		// After the startup hooks, which load the routes of the app.
+		routes := filepath.Join(os.TempDir(), "reveltest.routes")
+		err := ioutil.WriteFile(routes, []byte("GET /hotels/{id} Hotels.Show\nGET /hotels/{id}/booking Hotels.Book\n"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(routes)
+		revel.MainRouter = revel.NewRouter(routes)
+		if err := revel.MainRouter.Refresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// A suite of the booking app.
+type HotelsTest struct {
+	revel.TestSuite
+	hotelId int
+	calls   *[]string
+}
+
+func (s *HotelsTest) BeforeAll() {
+	s.hotelId = 3
+	s.calls = &[]string{"BeforeAll"}
+}
+
+func (s *HotelsTest) Before() { *s.calls = append(*s.calls, "Before") }
+func (s *HotelsTest) After()  { *s.calls = append(*s.calls, "After") }
+
+func (s *HotelsTest) AfterAll() {
+	*s.calls = append(*s.calls, "AfterAll")
+	hotelsTestCalls = *s.calls
+}
+
+func (s *HotelsTest) TestShow() {
+	s.Get(fmt.Sprintf("/hotels/%d", s.hotelId))
+	s.AssertOk()
+	s.AssertContains("300 Main St.")
+}
+
+func (s *HotelsTest) TestBooking() {
+	s.Get(fmt.Sprintf("/hotels/%d/booking", s.hotelId))
+	s.AssertOk()
+	s.AssertJsonPath("HotelId", s.hotelId)
+}
+
+func (s *HotelsTest) TestMissingHotel() {
+	s.Get("/nothing/here")
+	s.AssertOk()
+}
+
+var hotelsTestCalls []string
+
+func TestRunTestSuites(t *testing.T) {
+	startHotelsApp(t)
+	shared := reflect.New(reflect.TypeOf(HotelsTest{}))
+	if err := runSuiteHook(shared, "BeforeAll"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed assertion is the error of the test.
+	err := runSuiteTest(shared, "TestMissingHotel")
+	expected := "Status differs (-expected +actual):\n- 200\n+ 404"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error: %v\nexpected: %s", err, expected)
+	}
+
+	// The tests run as subtests, and see the fields set by BeforeAll.
+	RunTestSuites(t, (*passingHotelsTest)(nil))
+	expectedCalls := []string{"BeforeAll", "Before", "After", "Before", "After", "Before", "After", "AfterAll"}
+	if !reflect.DeepEqual(hotelsTestCalls, expectedCalls) {
+		t.Errorf("Unexpected calls: %v\nexpected: %v", hotelsTestCalls, expectedCalls)
+	}
+}
+
+// The passing tests of HotelsTest.
+type passingHotelsTest struct {
+	HotelsTest
+}
+
+func (s *passingHotelsTest) TestMissingHotel() {
+	s.Get("/nothing/here")
+	s.AssertNotFound()
+}
+
+// Suites which each wait for the other to be running.
+var (
+	suitesStarted sync.WaitGroup
+	suitesRunning = make(chan struct{})
+)
+
+type FirstParallelTest struct {
+	revel.TestSuite
+}
+
+func (s *FirstParallelTest) TestWaitForSecond() { waitForSuites() }
+
+type SecondParallelTest struct {
+	revel.TestSuite
+}
+
+func (s *SecondParallelTest) TestWaitForFirst() { waitForSuites() }
+
+func waitForSuites() {
+	suitesStarted.Done()
+	select {
+	case <-suitesRunning:
+	case <-time.After(5 * time.Second):
+		panic("The suites did not run in parallel")
+	}
+}
+
+func TestRunParallelTestSuites(t *testing.T) {
+	if parallel, _ := strconv.Atoi(flag.Lookup("test.parallel").Value.String()); parallel < 2 {
+		t.Skip("go test -parallel is less than 2")
+	}
+	startHotelsApp(t)
+	suitesStarted.Add(2)
+	go func() {
+		suitesStarted.Wait()
+		close(suitesRunning)
+	}()
+
+	// The parallel suites run once the test which started them returns.
+	t.Run("Suites", func(t *testing.T) {
+		RunParallelTestSuites(t, (*FirstParallelTest)(nil), (*SecondParallelTest)(nil))
+	})
+}
@@ -19,12 +19,44 @@ type Route struct {
 	Path        string   // e.g. /app/{id}
 	Action      string   // e.g. Application.ShowApp
 	FixedParams []string // e.g. "arg1","arg2","arg3" (CSV formatting)
+	File        string   // The routes file declaring the route, e.g. /path/to/app/conf/routes
+	Line        int      // The line declaring the route in the file.
 
 	pathPattern   *regexp.Regexp // for matching the url path
 	args          []*arg         // e.g. {id} from path /app/{id}
 	actionPattern *regexp.Regexp
 }
 
+// Shadows returns whether r, declared before other, matches every request that
+// other matches, which makes other unreachable.  Path parameters of other are
+// taken to have any value, so they are only matched by a parameter of r
+// accepting (at least) any segment, or with the same constraint.
+func (r *Route) Shadows(other *Route) bool {
+	if r.pathPattern == nil || other.pathPattern == nil {
+		return false
+	}
+	if r.Method != "*" && r.Method != other.Method && !(other.Method == "HEAD" && r.Method == "GET") {
+		return false
+	}
+
+	// Match the path of other, with its parameters left as their constraint, e.g.
+	// "{[0-9]+}", by the path of r, whose parameters also match their constraint.
+	path := argsPattern.ReplaceAllStringFunc(normalizePath(other.Path), func(m string) string {
+		return constraintToken(argsPattern.FindStringSubmatch(m)[1])
+	})
+	pattern, err := regexp.Compile("^" + argsPattern.ReplaceAllStringFunc(normalizePath(r.Path), func(m string) string {
+		constraint := argsPattern.FindStringSubmatch(m)[1]
+		return "(?:" + constraint + "|" + regexp.QuoteMeta(constraintToken(constraint)) + ")"
+	}) + "$")
+	return err == nil && pattern.MatchString(path)
+}
+
+// Return the parameter with the given constraint as it is matched by Shadows:
+// within braces, without slashes so that it is a single segment.
+func constraintToken(constraint string) string {
+	return "{" + strings.Replace(constraint, "/", "\x00", -1) + "}"
+}
+
 type RouteMatch struct {
 	Action         string // e.g. Application.ShowApp
 	ControllerName string // e.g. Application
@@ -44,6 +76,15 @@ var (
 	argsPattern         = regexp.MustCompile(`\{<(?P<pattern>[^>]+)>(?P<var>[a-zA-Z_0-9]+)\}`)
 )
 
+// Convert path arguments with unspecified regexes to standard form.
+// e.g. "/customer/{id}" => "/customer/{<[^/]+>id}
+func normalizePath(path string) string {
+	return nakedPathParamRegex.ReplaceAllStringFunc(path, func(m string) string {
+		var argMatches []string = nakedPathParamRegex.FindStringSubmatch(m)
+		return "{<[^/]+>" + argMatches[1] + "}"
+	})
+}
+
 // Prepares the route to be used in matching.
 func NewRoute(method, path, action, fixedArgs string) (r *Route) {
 	// Handle fixed arguments
@@ -70,12 +111,7 @@ func NewRoute(method, path, action, fixedArgs string) (r *Route) {
 
 	// Handle embedded arguments
 
-	// Convert path arguments with unspecified regexes to standard form.
-	// e.g. "/customer/{id}" => "/customer/{<[^/]+>id}
-	normPath := nakedPathParamRegex.ReplaceAllStringFunc(r.Path, func(m string) string {
-		var argMatches []string = nakedPathParamRegex.FindStringSubmatch(m)
-		return "{<[^/]+>" + argMatches[1] + "}"
-	})
+	normPath := normalizePath(r.Path)
 
 	// Go through the arguments
 	r.args = make([]*arg, 0, 3)
@@ -220,6 +256,7 @@ func parseRoutes(routesPath, content string, validate bool) ([]*Route, *Error) {
 		}
 
 		route := NewRoute(method, path, action, fixedArgs)
+		route.File, route.Line = routesPath, n+1
 		routes = append(routes, route)
 
 		if validate {
@@ -232,8 +269,24 @@ func parseRoutes(routesPath, content string, validate bool) ([]*Route, *Error) {
 	return routes, nil
 }
 
+// ParseRoutesFile reads the given routes file, and the routes files of the
+// modules it includes, without checking that the actions exist.
+func ParseRoutesFile(routesPath string) ([]*Route, *Error) {
+	return parseRoutesFile(routesPath, false)
+}
+
 // validateRoute checks that every specified action exists.
 func validateRoute(route *Route) error {
+	return CheckRoute(route, func(controllerName, methodName string) error {
+		var c Controller
+		return c.SetAction(controllerName, methodName)
+	})
+}
+
+// CheckRoute checks that the action of the route is of the form
+// Controller.Action, and calls findAction to check that it exists.  Variable
+// actions and 404s are not checked.
+func CheckRoute(route *Route, findAction func(controllerName, methodName string) error) error {
 	// Skip variable routes.
 	if strings.ContainsAny(route.Action, "{}") {
 		return nil
@@ -251,12 +304,7 @@ func validateRoute(route *Route) error {
 			len(parts), route.Action)
 	}
 
-	var c Controller
-	if err := c.SetAction(parts[0], parts[1]); err != nil {
-		return err
-	}
-
-	return nil
+	return findAction(parts[0], parts[1])
 }
 
 // routeError adds context to a simple error message.
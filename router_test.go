@@ -456,3 +456,59 @@ func eq(t *testing.T, name string, a, b interface{}) bool {
 	}
 	return true
 }
+
+func TestRouteShadows(t *testing.T) {
+	tests := []struct {
+		first, second string
+		shadows       bool
+	}{
+		{"GET /hotels/{id} Hotels.Show", "GET /hotels/new Hotels.New", true},
+		{"GET /hotels/new Hotels.New", "GET /hotels/{id} Hotels.Show", false},
+		{"GET /hotels/{id} Hotels.Show", "GET /hotels/{<[0-9]+>id} Hotels.Book", true},
+		{"GET /hotels/{<[0-9]+>id} Hotels.Book", "GET /hotels/{id} Hotels.Show", false},
+		{"GET /hotels/{id} Hotels.Show", "POST /hotels/{id} Hotels.Save", false},
+		{"GET /hotels/{id} Hotels.Show", "HEAD /hotels/{id} Hotels.Head", true},
+		{"* /{controller}/{action} {controller}.{action}", "POST /hotels/{id} Hotels.Save", true},
+		{"* /{controller}/{action} {controller}.{action}", "POST /hotels/{id}/book Hotels.Book", false},
+		{"* /{<.*>path} Static.Serve", "POST /hotels/{id} Hotels.Save", true},
+		{"GET /public/ Static.Serve", "GET /public/{<.+>filepath} Static.Serve", false},
+		{"GET /hotels/{<[0-9]+>id} Hotels.Show", "GET /hotels/{<[0-9]+>hid} Hotels.Book", true},
+		{"GET /hotels/{<[0-9]+>id}/rooms Hotels.Rooms", "GET /hotels/{<[0-9]+>hid}/rooms Rooms.List", true},
+		{"GET /hotels/{<[0-9]+>id} Hotels.Show", "GET /hotels/{<[a-z]+>slug} Hotels.Find", false},
+		{"GET /files/{<[^/]+>name} Files.Show", "GET /files/{name} Files.Download", true},
+		{"GET /files/{<[a-z/]+>path} Files.Show", "GET /files/{<[a-z/]+>name} Files.Download", true},
+	}
+	for _, test := range tests {
+		first, second := parseTestRoute(t, test.first), parseTestRoute(t, test.second)
+		eq(t, test.first+" shadows "+test.second, first.Shadows(second), test.shadows)
+	}
+}
+
+func TestCheckRoute(t *testing.T) {
+	findAction := func(controllerName, methodName string) error {
+		if controllerName+"."+methodName != "Hotels.Show" {
+			return fmt.Errorf("no action %s.%s", controllerName, methodName)
+		}
+		return nil
+	}
+	for line, valid := range map[string]bool{
+		"GET /hotels/{id} Hotels.Show":                     true,
+		"GET /hotels/{id} Hotels.Missing":                  false,
+		"GET /hotels Hotels":                               false,
+		"GET /{controller}/{action} {controller}.{action}": true,
+		"GET /favicon.ico 404":                             true,
+	} {
+		err := CheckRoute(parseTestRoute(t, line), findAction)
+		eq(t, line+" is valid", err == nil, valid)
+	}
+}
+
+func parseTestRoute(t *testing.T, line string) *Route {
+	routes, err := parseRoutes("routes", line, false)
+	if err != nil || len(routes) != 1 {
+		t.Fatalf("Failed to parse route %s: %v", line, err)
+	}
+	eq(t, "File", routes[0].File, "routes")
+	eq(t, "Line", routes[0].Line, 1)
+	return routes[0]
+}
@@ -1,8 +1,12 @@
 package revel
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/BSP-Mosaic/teltech-glog"
@@ -14,6 +18,9 @@ var (
 	MainTemplateLoader *TemplateLoader
 	MainWatcher        *Watcher
 	Server             *http.Server
+
+	// True when requests are served in-process (see InitInProcess).
+	inProcess bool
 )
 
 // This method handles all requests.  It dispatches to handleInternal after
@@ -77,17 +84,71 @@ func Run(port int) {
 
 	runStartupHooks()
 
+	// On signal, stop accepting requests and wait for those in progress, for at
+	// most http.shutdown.timeout, then run the shutdown hooks and return.
+	stopped := make(chan struct{})
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		<-ch
+		shutdownServer(Server, shutdownTimeout())
+		runShutdownHooks()
+		close(stopped)
+	}()
+
 	go func() {
 		time.Sleep(100 * time.Millisecond)
 		fmt.Printf("Listening on port %d...\n", port)
 	}()
 
+	var err error
 	if HttpSsl {
-		glog.Fatalln("Failed to listen:",
-			Server.ListenAndServeTLS(HttpSslCert, HttpSslKey))
+		err = Server.ListenAndServeTLS(HttpSslCert, HttpSslKey)
 	} else {
-		glog.Fatalln("Failed to listen:", Server.ListenAndServe())
+		err = Server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		glog.Fatalln("Failed to listen:", err)
+	}
+	<-stopped
+}
+
+const DEFAULT_SHUTDOWN_TIMEOUT = 10 * time.Second
+
+// Return the time given to the requests in progress on shutdown, from
+// http.shutdown.timeout.
+func shutdownTimeout() time.Duration {
+	value, found := Config.String("http.shutdown.timeout")
+	if !found {
+		return DEFAULT_SHUTDOWN_TIMEOUT
 	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		glog.Errorf("Invalid http.shutdown.timeout %s: %s", value, err)
+		return DEFAULT_SHUTDOWN_TIMEOUT
+	}
+	return timeout
+}
+
+// Close the listeners of the server, and wait for the requests in progress to
+// end, for at most the timeout.
+func shutdownServer(server *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		glog.Warningf("Some requests were still in progress after %s: %s", timeout, err)
+	}
+}
+
+// InitInProcess prepares the application to serve requests in-process, without
+// listening on a port: it loads the templates and runs the startup hooks.
+// It must be called after Init, and is meant for running the application tests
+// with "go test" (see NewTestSuite).
+func InitInProcess() {
+	MainTemplateLoader = NewTemplateLoader(TemplatePaths)
+	MainTemplateLoader.Refresh()
+	runStartupHooks()
+	inProcess = true
 }
 
 func runStartupHooks() {
@@ -101,3 +162,18 @@ var startupHooks []func()
 func OnAppStart(f func()) {
 	startupHooks = append(startupHooks, f)
 }
+
+// Run the shutdown hooks, last registered first.
+func runShutdownHooks() {
+	for i := len(shutdownHooks) - 1; i >= 0; i-- {
+		shutdownHooks[i]()
+	}
+}
+
+var shutdownHooks []func()
+
+// OnAppStop registers a func to run when the server is stopped by a signal
+// (SIGINT or SIGTERM), before it exits, e.g. to stop background work.
+func OnAppStop(f func()) {
+	shutdownHooks = append(shutdownHooks, f)
+}
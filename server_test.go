@@ -1,12 +1,15 @@
 package revel
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // This tries to benchmark the usual request-serving pipeline to get an overall
@@ -101,3 +104,50 @@ var (
 	jsonRequest, _      = http.NewRequest("GET", "/hotels/3/booking", nil)
 	plaintextRequest, _ = http.NewRequest("GET", "/hotels", nil)
 )
+
+// Test that the server waits for the requests in progress when shut down.
+func TestShutdownServer(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+	<-started
+
+	// The request in progress is completed before the shutdown ends.
+	var released int32
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&released, 1)
+		close(release)
+	}()
+	shutdownServer(server.Config, time.Second)
+	if atomic.LoadInt32(&released) != 1 {
+		t.Error("Expected the shutdown to wait for the request in progress")
+	}
+	select {
+	case body := <-responses:
+		if body != "done" {
+			t.Errorf("Expected the request to complete, got %s", body)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the request to complete")
+	}
+	if _, err := http.Get(server.URL); err == nil {
+		t.Error("Expected no request to be accepted after the shutdown")
+	}
+}
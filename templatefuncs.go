@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"html"
 	"html/template"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strings"
@@ -30,6 +31,8 @@ var (
 		"pad":        pad,
 		"errorClass": errorClass,
 		"msg":        msg,
+		"msgp":       msgp,
+		"localeUrl":  localeUrl,
 		"nl2br":      nl2br,
 		"raw":        raw,
 		"pluralize":  Pluralize,
@@ -170,6 +173,22 @@ func msg(renderArgs map[string]interface{}, message string, args ...interface{})
 	return template.HTML(Message(renderArgs[CurrentLocaleRenderArg].(string), message, args...))
 }
 
+func msgp(renderArgs map[string]interface{}, message string, count int, args ...interface{}) template.HTML {
+	return template.HTML(MessagePlural(renderArgs[CurrentLocaleRenderArg].(string), message, count, args...))
+}
+
+// Return the URL of the current page in the given locale.
+func localeUrl(renderArgs map[string]interface{}, locale string) (string, error) {
+	currentUrl, ok := renderArgs[CurrentUrlRenderArg].(*url.URL)
+	if !ok {
+		return "", fmt.Errorf("localeUrl: no %s in the render args; is I18nFilter enabled?", CurrentUrlRenderArg)
+	}
+	if !localeSwitchable() {
+		return "", fmt.Errorf("localeUrl: none of the path, query, cookie and session resolvers is in %s", localeResolversConfigKey)
+	}
+	return LocaleUrl(currentUrl, locale), nil
+}
+
 // Replaces newlines with <br>
 func nl2br(text string) template.HTML {
 	return template.HTML(strings.Replace(template.HTMLEscapeString(text), "\n", "<br>", -1))
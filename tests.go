@@ -2,13 +2,21 @@ package revel
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/websocket"
@@ -19,22 +27,60 @@ type TestSuite struct {
 	Response     *http.Response
 	ResponseBody []byte
 	Session      Session
+
+	// Whether redirect responses are followed (the default).  When false, the
+	// redirect itself is the response; see AssertRedirect and FollowRedirect.
+	FollowRedirects bool
 }
 
 var TestSuites []interface{} // Array of structs that embed TestSuite
 
+// Functions called by the test runner before and after every test, e.g. by
+// modules to reset the state of the application between tests.
+var (
+	BeforeTestHooks []func()
+	AfterTestHooks  []func()
+)
+
 // NewTestSuite returns an initialized TestSuite ready for use. It is invoked
 // by the test harness to initialize the embedded field in application tests.
+//
+// When the application serves requests in-process (see InitInProcess), the
+// suite dispatches its requests directly through the filter chain instead of
+// over the network.
 func NewTestSuite() TestSuite {
+	if inProcess {
+		return NewInProcessTestSuite()
+	}
 	jar, _ := cookiejar.New(nil)
 	return TestSuite{
-		Client:  &http.Client{Jar: jar},
-		Session: make(Session),
+		Client:          &http.Client{Jar: jar},
+		Session:         make(Session),
+		FollowRedirects: true,
 	}
 }
 
+// NewInProcessTestSuite returns a TestSuite whose requests are handled by the
+// application in this process, recorded with an httptest.ResponseRecorder.
+// No server needs to be running, so tests may be run with "go test" (see
+// reveltest.RunTestSuites).
+func NewInProcessTestSuite() TestSuite {
+	jar, _ := cookiejar.New(nil)
+	return TestSuite{
+		Client:          &http.Client{Jar: jar, Transport: inProcessTransport{}},
+		Session:         make(Session),
+		FollowRedirects: true,
+	}
+}
+
+// The host used in the URLs of in-process requests.
+const inProcessHost = "revel.test"
+
 // Return the address and port of the server, e.g. "127.0.0.1:8557"
 func (t *TestSuite) Host() string {
+	if t.isInProcess() {
+		return inProcessHost
+	}
 	if Server.Addr[0] == ':' {
 		return "127.0.0.1" + Server.Addr
 	}
@@ -51,6 +97,11 @@ func (t *TestSuite) WebSocketUrl() string {
 	return "ws://" + t.Host()
 }
 
+func (t *TestSuite) isInProcess() bool {
+	_, ok := t.Client.Transport.(inProcessTransport)
+	return ok
+}
+
 // Issue a GET request to the given path and store the result in Request and
 // RequestBody.
 func (t *TestSuite) Get(path string) {
@@ -64,12 +115,7 @@ func (t *TestSuite) Get(path string) {
 // Issue a POST request to the given path, sending the given Content-Type and
 // data, and store the result in Request and RequestBody.  "data" may be nil.
 func (t *TestSuite) Post(path string, contentType string, reader io.Reader) {
-	req, err := http.NewRequest("POST", t.BaseUrl()+path, reader)
-	if err != nil {
-		panic(err)
-	}
-	req.Header.Set("Content-Type", contentType)
-	t.MakeRequestSession(req)
+	t.send("POST", path, contentType, reader)
 }
 
 // Issue a POST request to the given path as a form post of the given key and
@@ -78,6 +124,71 @@ func (t *TestSuite) PostForm(path string, data url.Values) {
 	t.Post(path, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
 }
 
+// Issue a POST request to the given path as a multipart form post of the given
+// key and values, and of the given files (by field name, the paths of the files
+// to upload), and store the result in Request and RequestBody.
+func (t *TestSuite) PostMultipart(path string, params url.Values, files map[string]string) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, values := range params {
+		for _, value := range values {
+			if err := writer.WriteField(key, value); err != nil {
+				panic(err)
+			}
+		}
+	}
+	for field, filePath := range files {
+		part, err := writer.CreateFormFile(field, filepath.Base(filePath))
+		if err != nil {
+			panic(err)
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			panic(err)
+		}
+		_, err = io.Copy(part, file)
+		file.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		panic(err)
+	}
+	t.Post(path, writer.FormDataContentType(), &body)
+}
+
+// Issue a PUT request to the given path, sending the given Content-Type and
+// data, and store the result in Request and RequestBody.  "data" may be nil.
+func (t *TestSuite) Put(path string, contentType string, reader io.Reader) {
+	t.send("PUT", path, contentType, reader)
+}
+
+// Issue a PATCH request to the given path, sending the given Content-Type and
+// data, and store the result in Request and RequestBody.  "data" may be nil.
+func (t *TestSuite) Patch(path string, contentType string, reader io.Reader) {
+	t.send("PATCH", path, contentType, reader)
+}
+
+// Issue a DELETE request to the given path and store the result in Request and
+// RequestBody.
+func (t *TestSuite) Delete(path string) {
+	req, err := http.NewRequest("DELETE", t.BaseUrl()+path, nil)
+	if err != nil {
+		panic(err)
+	}
+	t.MakeRequestSession(req)
+}
+
+func (t *TestSuite) send(method, path, contentType string, reader io.Reader) {
+	req, err := http.NewRequest(method, t.BaseUrl()+path, reader)
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	t.MakeRequestSession(req)
+}
+
 // Issue any request and read the response. If successful, the caller may
 // examine the Response and ResponseBody properties. Session data will be
 // added to the request cookies for you.
@@ -90,6 +201,13 @@ func (t *TestSuite) MakeRequestSession(req *http.Request) {
 // examine the Response and ResponseBody properties. You will need to
 // manage session / cookie data manually
 func (t *TestSuite) MakeRequest(req *http.Request) {
+	t.Client.CheckRedirect = nil
+	if !t.FollowRedirects {
+		t.Client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
 	var err error
 	if t.Response, err = t.Client.Do(req); err != nil {
 		panic(err)
@@ -110,6 +228,9 @@ func (t *TestSuite) MakeRequest(req *http.Request) {
 
 // Create a websocket connection to the given path and return the connection
 func (t *TestSuite) WebSocket(path string) *websocket.Conn {
+	if t.isInProcess() {
+		panic("WebSocket connections are not supported by in-process test suites")
+	}
 	origin := t.BaseUrl() + "/"
 	url := t.WebSocketUrl() + path
 	ws, err := websocket.Dial(url, "", origin)
@@ -119,6 +240,27 @@ func (t *TestSuite) WebSocket(path string) *websocket.Conn {
 	return ws
 }
 
+// inProcessTransport handles requests by running them through the filter
+// chain, as the server would.
+type inProcessTransport struct{}
+
+func (inProcessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Give the request the fields set on requests received by a server.
+	serverReq := *req
+	serverReq.RequestURI = req.URL.RequestURI()
+	serverReq.RemoteAddr = "127.0.0.1:0"
+	if serverReq.Body == nil {
+		serverReq.Body = ioutil.NopCloser(bytes.NewReader(nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	handleInternal(recorder, &serverReq, nil)
+
+	resp := recorder.Result()
+	resp.Request = req
+	return resp, nil
+}
+
 func (t *TestSuite) AssertOk() {
 	t.AssertStatus(http.StatusOK)
 }
@@ -129,7 +271,7 @@ func (t *TestSuite) AssertNotFound() {
 
 func (t *TestSuite) AssertStatus(status int) {
 	if t.Response.StatusCode != status {
-		panic(fmt.Errorf("Status: (expected) %d != %d (actual)", status, t.Response.StatusCode))
+		panic(diffError("Status", status, t.Response.StatusCode))
 	}
 }
 
@@ -140,13 +282,13 @@ func (t *TestSuite) AssertContentType(contentType string) {
 func (t *TestSuite) AssertHeader(name, value string) {
 	actual := t.Response.Header.Get(name)
 	if actual != value {
-		panic(fmt.Errorf("Header %s: (expected) %s != %s (actual)", name, value, actual))
+		panic(diffError("Header "+name, value, actual))
 	}
 }
 
 func (t *TestSuite) AssertEqual(expected, actual interface{}) {
 	if !Equal(expected, actual) {
-		panic(fmt.Errorf("(expected) %v != %v (actual)", expected, actual))
+		panic(diffError("Value", expected, actual))
 	}
 }
 
@@ -163,7 +305,7 @@ func (t *TestSuite) Assertf(exp bool, formatStr string, args ...interface{}) {
 // Assert that the response contains the given string.
 func (t *TestSuite) AssertContains(s string) {
 	if !bytes.Contains(t.ResponseBody, []byte(s)) {
-		panic(fmt.Errorf("Assertion failed. Expected response to contain %s", s))
+		panic(diffError("Response containing", s, t.responseExcerpt()))
 	}
 }
 
@@ -172,6 +314,325 @@ func (t *TestSuite) AssertContainsRegex(regex string) {
 	r := regexp.MustCompile(regex)
 
 	if !r.Match(t.ResponseBody) {
-		panic(fmt.Errorf("Assertion failed. Expected response to match regexp %s", regex))
+		panic(diffError("Response matching", regex, t.responseExcerpt()))
+	}
+}
+
+// Decode the JSON response into the value pointed to by v.
+func (t *TestSuite) DecodeJson(v interface{}) {
+	if err := json.Unmarshal(t.ResponseBody, v); err != nil {
+		panic(fmt.Errorf("Failed to decode the response as JSON: %s", err))
+	}
+}
+
+// Return the value at the given path of the JSON response, e.g.
+// "hotels[0].name" or "hotels.0.name".  Objects decode to
+// map[string]interface{}, arrays to []interface{} and numbers to float64.
+func (t *TestSuite) JsonPath(path string) interface{} {
+	var value interface{}
+	t.DecodeJson(&value)
+
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return value
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				panic(fmt.Errorf("JSON path %s: no key %q", path, key))
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				panic(fmt.Errorf("JSON path %s: no index %q in an array of length %d", path, key, len(v)))
+			}
+			value = v[i]
+		default:
+			panic(fmt.Errorf("JSON path %s: %q is not in an object or array", path, key))
+		}
+	}
+	return value
+}
+
+// Assert that the value at the given path of the JSON response equals the
+// expected value, once both are encoded as JSON.
+func (t *TestSuite) AssertJsonPath(path string, expected interface{}) {
+	actual := t.JsonPath(path)
+	expectedJson, err := json.MarshalIndent(expected, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	var normalized interface{}
+	json.Unmarshal(expectedJson, &normalized)
+	if !reflect.DeepEqual(normalized, actual) {
+		actualJson, _ := json.MarshalIndent(actual, "", "  ")
+		panic(diffError("JSON path "+path, string(expectedJson), string(actualJson)))
+	}
+}
+
+// Return the elements of the HTML response matching the CSS selector.
+func (t *TestSuite) selectHtml(selector string) []*htmlNode {
+	document, err := parseHtml(t.ResponseBody)
+	if err != nil {
+		panic(fmt.Errorf("Failed to parse the response as HTML: %s", err))
+	}
+	nodes, err := document.Find(selector)
+	if err != nil {
+		panic(err)
+	}
+	return nodes
+}
+
+// Assert that the HTML response has an element matching the CSS selector.
+// See AssertSelectorCount for the supported selectors.
+func (t *TestSuite) AssertSelector(selector string) {
+	if len(t.selectHtml(selector)) == 0 {
+		panic(diffError("Elements matching "+selector, "at least 1", 0))
+	}
+}
+
+// Assert that the HTML response has no element matching the CSS selector.
+func (t *TestSuite) AssertNoSelector(selector string) {
+	if count := len(t.selectHtml(selector)); count != 0 {
+		panic(diffError("Elements matching "+selector, 0, count))
+	}
+}
+
+// Assert that the HTML response has the given number of elements matching the
+// CSS selector.  Supported are type (div), universal (*), id (#main), class
+// (.item) and attribute ([name], [name=value]) selectors, the descendant and
+// child (>) combinators, and groups of selectors separated by commas.
+func (t *TestSuite) AssertSelectorCount(selector string, count int) {
+	if actual := len(t.selectHtml(selector)); actual != count {
+		panic(diffError("Elements matching "+selector, count, actual))
+	}
+}
+
+// Assert that the text of the first element matching the CSS selector equals
+// the given text, whitespace being collapsed in both.
+func (t *TestSuite) AssertSelectorText(selector, text string) {
+	nodes := t.selectHtml(selector)
+	if len(nodes) == 0 {
+		panic(diffError("Elements matching "+selector, "at least 1", 0))
+	}
+	expected := strings.Join(strings.Fields(text), " ")
+	if actual := nodes[0].Text(); actual != expected {
+		panic(diffError("Text of "+selector, expected, actual))
+	}
+}
+
+// Assert that the response redirects to the given location.  A location
+// without a host is compared to the path and query of the redirect.  This
+// requires FollowRedirects to be false.
+func (t *TestSuite) AssertRedirect(location string) {
+	actual, err := t.redirectLocation()
+	if err != nil {
+		panic(err)
+	}
+	if !strings.Contains(location, "://") {
+		if actual.Host == t.Response.Request.URL.Host {
+			actual = &url.URL{Path: actual.Path, RawQuery: actual.RawQuery, Fragment: actual.Fragment}
+		}
+	}
+	if actual.String() != location {
+		panic(diffError("Redirect", location, actual.String()))
+	}
+}
+
+// Issue a GET request to the location the response redirects to.
+func (t *TestSuite) FollowRedirect() {
+	location, err := t.redirectLocation()
+	if err != nil {
+		panic(err)
+	}
+	req, err := http.NewRequest("GET", location.String(), nil)
+	if err != nil {
+		panic(err)
+	}
+	t.MakeRequestSession(req)
+}
+
+// Return the absolute location the response redirects to.
+func (t *TestSuite) redirectLocation() (*url.URL, error) {
+	if t.Response.StatusCode < 300 || t.Response.StatusCode >= 400 {
+		return nil, diffError("Status", "3xx (redirect)", t.Response.StatusCode)
+	}
+	location, err := t.Response.Location()
+	if err != nil {
+		return nil, fmt.Errorf("Redirect: %s", err)
+	}
+	return location, nil
+}
+
+// Return the cookie of the given name the client would send to the
+// application, or nil.
+func (t *TestSuite) Cookie(name string) *http.Cookie {
+	u, err := url.Parse(t.BaseUrl() + "/")
+	if err != nil {
+		panic(err)
+	}
+	for _, cookie := range t.Client.Jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// Assert that the client holds the cookie with the given value.
+func (t *TestSuite) AssertCookie(name, value string) {
+	cookie := t.Cookie(name)
+	if cookie == nil {
+		panic(diffError("Cookies", name+"="+value, t.cookieLines()))
+	}
+	if cookie.Value != value {
+		panic(diffError("Cookie "+name, value, cookie.Value))
+	}
+}
+
+// Assert that the session holds the given value.
+func (t *TestSuite) AssertSession(key, value string) {
+	actual, ok := t.Session[key]
+	if !ok {
+		panic(diffError("Session", key+"="+value, sortedLines(t.Session)))
+	}
+	if actual != value {
+		panic(diffError("Session "+key, value, actual))
+	}
+}
+
+// Return the flash values set by the last response.
+func (t *TestSuite) Flash() map[string]string {
+	return t.keyValueCookie(CookiePrefix + "_FLASH")
+}
+
+// Assert that the last response set the flash value, e.g. "success" or "error".
+func (t *TestSuite) AssertFlash(key, value string) {
+	flash := t.Flash()
+	actual, ok := flash[key]
+	if !ok {
+		panic(diffError("Flash", key+"="+value, sortedLines(flash)))
+	}
+	if actual != value {
+		panic(diffError("Flash "+key, value, actual))
+	}
+}
+
+// Return the validation error messages kept by the last response (see
+// Validation.Keep), by key.
+func (t *TestSuite) ValidationErrors() map[string]string {
+	return t.keyValueCookie(CookiePrefix + "_ERRORS")
+}
+
+// Assert that the last response kept a validation error for the key.
+func (t *TestSuite) AssertValidationError(key string) {
+	errors := t.ValidationErrors()
+	if _, ok := errors[key]; !ok {
+		keys := make([]string, 0, len(errors))
+		for errorKey := range errors {
+			keys = append(keys, errorKey)
+		}
+		sort.Strings(keys)
+		panic(diffError("Validation errors", key, strings.Join(keys, "\n")))
+	}
+}
+
+// Assert that the last response kept no validation errors.
+func (t *TestSuite) AssertNoValidationErrors() {
+	if errors := t.ValidationErrors(); len(errors) != 0 {
+		panic(diffError("Validation errors", "", sortedLines(errors)))
+	}
+}
+
+// Return the cookies held by the client, one "name=value" by line.
+func (t *TestSuite) cookieLines() string {
+	u, err := url.Parse(t.BaseUrl() + "/")
+	if err != nil {
+		panic(err)
+	}
+	cookies := make(map[string]string)
+	for _, cookie := range t.Client.Jar.Cookies(u) {
+		cookies[cookie.Name] = cookie.Value
+	}
+	return sortedLines(cookies)
+}
+
+// The longest part of the response shown by a failed assertion.
+const maxResponseExcerpt = 4096
+
+// Return the response body, cut if it is long, for a failed assertion.
+func (t *TestSuite) responseExcerpt() string {
+	if len(t.ResponseBody) > maxResponseExcerpt {
+		return string(t.ResponseBody[:maxResponseExcerpt]) + "\n..."
+	}
+	return string(t.ResponseBody)
+}
+
+// Return the entries of a map, one "key=value" by line, sorted by key.
+func sortedLines(values map[string]string) string {
+	lines := make([]string, 0, len(values))
+	for key, value := range values {
+		lines = append(lines, key+"="+value)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func (t *TestSuite) keyValueCookie(name string) map[string]string {
+	values := make(map[string]string)
+	if cookie := t.Cookie(name); cookie != nil {
+		ParseKeyValueCookie(cookie.Value, func(key, val string) {
+			values[key] = val
+		})
+	}
+	return values
+}
+
+// diffError describes how the actual value differs from the expected one, line
+// by line for values spanning several lines:
+//
+//	Status differs (-expected +actual):
+//	- 200
+//	+ 404
+func diffError(subject string, expected, actual interface{}) error {
+	expectedLines := strings.Split(fmt.Sprint(expected), "\n")
+	actualLines := strings.Split(fmt.Sprint(actual), "\n")
+
+	// Find the longest common subsequence of lines.
+	lcs := make([][]int, len(expectedLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actualLines)+1)
+	}
+	for i := len(expectedLines) - 1; i >= 0; i-- {
+		for j := len(actualLines) - 1; j >= 0; j-- {
+			if expectedLines[i] == actualLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "%s differs (-expected +actual):", subject)
+	i, j := 0, 0
+	for i < len(expectedLines) || j < len(actualLines) {
+		switch {
+		case i < len(expectedLines) && j < len(actualLines) && expectedLines[i] == actualLines[j]:
+			fmt.Fprintf(&buffer, "\n  %s", expectedLines[i])
+			i, j = i+1, j+1
+		case j == len(actualLines) || (i < len(expectedLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&buffer, "\n- %s", expectedLines[i])
+			i++
+		default:
+			fmt.Fprintf(&buffer, "\n+ %s", actualLines[j])
+			j++
+		}
 	}
+	return fmt.Errorf("%s", buffer.String())
 }
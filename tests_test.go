@@ -0,0 +1,180 @@
+package revel
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestInProcessTestSuite(t *testing.T) {
+	startFakeBookingApp()
+
+	suite := NewInProcessTestSuite()
+	if !suite.isInProcess() {
+		t.Fatalf("Expected an in-process test suite")
+	}
+	if suite.BaseUrl() != "http://"+inProcessHost {
+		t.Errorf("Unexpected base URL: %s", suite.BaseUrl())
+	}
+
+	suite.Get("/hotels/3")
+	suite.AssertOk()
+	suite.AssertContentType("text/html; charset=utf-8")
+	suite.AssertContains("300 Main St.")
+
+	suite.Get("/hotels/3/booking")
+	suite.AssertOk()
+	suite.AssertContentType("application/json; charset=utf-8")
+	suite.AssertContains(`"HotelId":3`)
+
+	suite.Get("/nothing/here")
+	suite.AssertNotFound()
+}
+
+func TestTestSuiteJson(t *testing.T) {
+	startFakeBookingApp()
+
+	suite := NewInProcessTestSuite()
+	suite.Get("/hotels/3/booking")
+
+	var hotel Hotel
+	suite.DecodeJson(&hotel)
+	if hotel.HotelId != 3 || hotel.City != "New York" {
+		t.Errorf("Unexpected hotel: %+v", hotel)
+	}
+
+	suite.AssertJsonPath("HotelId", 3)
+	suite.AssertJsonPath("$.Name", "A Hotel")
+	expectPanic(t, "JSON path Price differs (-expected +actual):\n- 200\n+ 300", func() {
+		suite.AssertJsonPath("Price", 200)
+	})
+
+	suite.ResponseBody = []byte(`{"hotels": [{"name": "A"}, {"name": "B", "tags": ["x"]}]}`)
+	suite.AssertJsonPath("hotels[1].name", "B")
+	suite.AssertJsonPath("hotels.1.tags", []string{"x"})
+	expectPanic(t, `JSON path hotels.2.name: no index "2" in an array of length 2`, func() {
+		suite.JsonPath("hotels[2].name")
+	})
+}
+
+func TestTestSuiteSelectors(t *testing.T) {
+	suite := NewTestSuite()
+	suite.ResponseBody = []byte(`<!DOCTYPE html>
+<html>
+<head><script>if (a < b) {}</script></head>
+<body>
+  <div id="main" class="content wide">
+    <h1>Hotels</h1>
+    <ul>
+      <li class="hotel"><a href="/hotels/1">A&amp;B  Hotel</a>
+      <li class="hotel closed"><a href="/hotels/2">Other</a>
+    </ul>
+    <input type="text" name="query" disabled>
+  </div>
+  <p>Footer <br> text</p>
+</body>
+</html>`)
+
+	suite.AssertSelector("#main")
+	suite.AssertSelector("div.content.wide > h1")
+	suite.AssertNoSelector("body > h1")
+	suite.AssertSelectorCount("li.hotel", 2)
+	suite.AssertSelectorCount("#main li.closed a", 1)
+	suite.AssertSelectorCount("input[name=query][disabled]", 1)
+	suite.AssertSelectorCount(`a[href="/hotels/1"], p`, 2)
+	suite.AssertSelectorText("li.hotel a", "A&B Hotel")
+	suite.AssertSelectorText("p", "Footer text")
+	expectPanic(t, "Elements matching li differs (-expected +actual):\n- 3\n+ 2", func() {
+		suite.AssertSelectorCount("li", 3)
+	})
+
+	// Elements left open are closed as browsers would, and script contents are not markup.
+	suite.ResponseBody = []byte(`<!DOCTYPE html><p>Rooms<table><tr><td>Single<td>Double<tr><td>Suite</table>
+<p>Total <script>document.write("<p>" + total + "</p>")</script><p>Taxes`)
+	suite.AssertSelectorCount("table tr", 2)
+	suite.AssertSelectorCount("tr > td", 3)
+	suite.AssertSelectorCount("p", 3)
+	suite.AssertNoSelector("p p")
+	suite.AssertSelectorText("p", "Rooms")
+	suite.AssertSelectorText("td", "Single")
+}
+
+func TestTestSuiteRedirectsAndCookies(t *testing.T) {
+	suite := NewInProcessTestSuite()
+	req, _ := http.NewRequest("POST", suite.BaseUrl()+"/hotels", nil)
+	suite.Response = &http.Response{
+		StatusCode: http.StatusFound,
+		Header:     http.Header{"Location": {"/hotels/1?booked=true"}},
+		Request:    req,
+	}
+	suite.AssertRedirect("/hotels/1?booked=true")
+	suite.AssertRedirect("http://" + inProcessHost + "/hotels/1?booked=true")
+	expectPanic(t, "Redirect differs (-expected +actual):\n- /hotels\n+ /hotels/1?booked=true", func() {
+		suite.AssertRedirect("/hotels")
+	})
+
+	u, _ := url.Parse(suite.BaseUrl())
+	suite.Client.Jar.SetCookies(u, []*http.Cookie{
+		{Name: "theme", Value: "dark"},
+		{Name: CookiePrefix + "_FLASH", Value: url.QueryEscape("\x00success:Booked\x00")},
+		{Name: CookiePrefix + "_ERRORS", Value: url.QueryEscape("\x00hotel.Name:Required\x00")},
+	})
+	suite.AssertCookie("theme", "dark")
+	suite.AssertFlash("success", "Booked")
+	suite.AssertValidationError("hotel.Name")
+	expectPanic(t, "Cookie theme differs (-expected +actual):\n- light\n+ dark", func() {
+		suite.AssertCookie("theme", "light")
+	})
+
+	// The failures list what was there instead.
+	expectPanic(t, "Cookies differs (-expected +actual):\n- lang=en\n+ "+
+		CookiePrefix+"_ERRORS="+url.QueryEscape("\x00hotel.Name:Required\x00")+"\n+ "+
+		CookiePrefix+"_FLASH="+url.QueryEscape("\x00success:Booked\x00")+"\n+ theme=dark", func() {
+		suite.AssertCookie("lang", "en")
+	})
+	expectPanic(t, "Flash differs (-expected +actual):\n- error=Failed\n+ success=Booked", func() {
+		suite.AssertFlash("error", "Failed")
+	})
+	expectPanic(t, "Validation errors differs (-expected +actual):\n- hotel.City\n+ hotel.Name", func() {
+		suite.AssertValidationError("hotel.City")
+	})
+	expectPanic(t, "Validation errors differs (-expected +actual):\n- \n+ hotel.Name=Required", func() {
+		suite.AssertNoValidationErrors()
+	})
+}
+
+func TestTestSuiteBodyAssertions(t *testing.T) {
+	suite := NewInProcessTestSuite()
+	suite.ResponseBody = []byte("<p>Hotels</p>\n<p>None</p>")
+	suite.AssertContains("Hotels")
+	expectPanic(t, "Response containing differs (-expected +actual):\n- Motels\n+ <p>Hotels</p>\n+ <p>None</p>", func() {
+		suite.AssertContains("Motels")
+	})
+	expectPanic(t, "Elements matching div differs (-expected +actual):\n- at least 1\n+ 0", func() {
+		suite.AssertSelector("div")
+	})
+	expectPanic(t, "Elements matching p differs (-expected +actual):\n- 0\n+ 2", func() {
+		suite.AssertNoSelector("p")
+	})
+}
+
+func TestDiffError(t *testing.T) {
+	err := diffError("Body", "a\nb\nc", "a\nx\nc\nd")
+	expected := "Body differs (-expected +actual):\n  a\n- b\n+ x\n  c\n+ d"
+	if err.Error() != expected {
+		t.Errorf("Unexpected diff:\n%s\nexpected:\n%s", err, expected)
+	}
+}
+
+func expectPanic(t *testing.T, message string, f func()) {
+	defer func() {
+		err := recover()
+		if err == nil {
+			t.Errorf("Expected a panic: %s", message)
+		} else if fmt.Sprint(err) != message {
+			t.Errorf("Unexpected panic: %s\nexpected: %s", err, message)
+		}
+	}()
+	f()
+}
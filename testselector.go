@@ -0,0 +1,208 @@
+package revel
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// htmlNode is an element or text node of a parsed HTML document, as used by the
+// TestSuite selector assertions.
+type htmlNode struct {
+	tag      string // Empty for text nodes
+	attrs    map[string]string
+	text     string
+	parent   *htmlNode
+	children []*htmlNode
+}
+
+// Parse an HTML document as browsers would, closing unclosed elements and
+// treating script and style contents as text.
+func parseHtml(body []byte) (*htmlNode, error) {
+	document, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return convertHtmlNode(document, nil), nil
+}
+
+// Convert the nodes of the parsed document, keeping only elements and text.
+func convertHtmlNode(source *html.Node, parent *htmlNode) *htmlNode {
+	var node *htmlNode
+	switch source.Type {
+	case html.DocumentNode:
+		node = &htmlNode{tag: "#document"}
+	case html.ElementNode:
+		node = &htmlNode{tag: source.Data, attrs: make(map[string]string, len(source.Attr)), parent: parent}
+		for _, attr := range source.Attr {
+			node.attrs[attr.Key] = attr.Val
+		}
+	case html.TextNode:
+		return &htmlNode{text: source.Data, parent: parent}
+	default:
+		return nil
+	}
+
+	for child := source.FirstChild; child != nil; child = child.NextSibling {
+		if converted := convertHtmlNode(child, node); converted != nil {
+			node.children = append(node.children, converted)
+		}
+	}
+	return node
+}
+
+// Text returns the text content of the node, with whitespace collapsed.
+func (n *htmlNode) Text() string {
+	var buffer bytes.Buffer
+	var collect func(*htmlNode)
+	collect = func(node *htmlNode) {
+		if node.tag == "" {
+			buffer.WriteString(node.text)
+			buffer.WriteByte(' ')
+		}
+		for _, child := range node.children {
+			collect(child)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(buffer.String()), " ")
+}
+
+// Find returns the elements under the node matching the CSS selector, in
+// document order.  Supported are type (div), universal (*), id (#main), class
+// (.item) and attribute ([name], [name=value]) selectors, the descendant and
+// child (>) combinators, and groups of selectors separated by commas.
+func (n *htmlNode) Find(selector string) ([]*htmlNode, error) {
+	groups, err := compileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*htmlNode
+	var walk func(*htmlNode)
+	walk = func(node *htmlNode) {
+		for _, child := range node.children {
+			if child.tag == "" {
+				continue
+			}
+			for _, steps := range groups {
+				if child.matches(steps) {
+					matches = append(matches, child)
+					break
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(n)
+	return matches, nil
+}
+
+// A compound selector, and how it relates to the previous one.
+type selectorStep struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSelector
+	child   bool // Whether the element must be a child (rather than a descendant) of the previous step
+}
+
+type attrSelector struct {
+	name, value string
+	hasValue    bool
+}
+
+var selectorStepPattern = regexp.MustCompile(`^([a-zA-Z][\w-]*|\*)?((?:#[\w-]+|\.[\w-]+|\[\s*[\w-]+\s*(?:=\s*(?:"[^"]*"|'[^']*'|[^\]]*))?\])*)$`)
+var selectorPartPattern = regexp.MustCompile(`#[\w-]+|\.[\w-]+|\[\s*([\w-]+)\s*(?:=\s*("[^"]*"|'[^']*'|[^\]]*))?\]`)
+
+func compileSelector(selector string) ([][]selectorStep, error) {
+	var groups [][]selectorStep
+	for _, group := range strings.Split(selector, ",") {
+		// Surround child combinators with spaces, so that steps are separated by spaces.
+		fields := strings.Fields(strings.Replace(group, ">", " > ", -1))
+		var steps []selectorStep
+		child := false
+		for _, field := range fields {
+			if field == ">" {
+				if len(steps) == 0 || child {
+					return nil, fmt.Errorf("invalid selector %q", selector)
+				}
+				child = true
+				continue
+			}
+			match := selectorStepPattern.FindStringSubmatch(field)
+			if match == nil {
+				return nil, fmt.Errorf("invalid selector %q: unsupported %q", selector, field)
+			}
+			step := selectorStep{tag: strings.ToLower(match[1]), child: child}
+			for _, part := range selectorPartPattern.FindAllStringSubmatch(match[2], -1) {
+				switch part[0][0] {
+				case '#':
+					step.id = part[0][1:]
+				case '.':
+					step.classes = append(step.classes, part[0][1:])
+				case '[':
+					attr := attrSelector{name: strings.ToLower(part[1])}
+					if strings.Contains(part[0], "=") {
+						attr.hasValue = true
+						attr.value = strings.Trim(strings.TrimSpace(part[2]), `"'`)
+					}
+					step.attrs = append(step.attrs, attr)
+				}
+			}
+			steps = append(steps, step)
+			child = false
+		}
+		if len(steps) == 0 || child {
+			return nil, fmt.Errorf("invalid selector %q", selector)
+		}
+		groups = append(groups, steps)
+	}
+	return groups, nil
+}
+
+// Whether the element matches the last step, and its ancestors the previous ones.
+func (n *htmlNode) matches(steps []selectorStep) bool {
+	last := steps[len(steps)-1]
+	if !n.matchesStep(last) {
+		return false
+	}
+	if len(steps) == 1 {
+		return true
+	}
+
+	for ancestor := n.parent; ancestor != nil && ancestor.parent != nil; ancestor = ancestor.parent {
+		if ancestor.matches(steps[:len(steps)-1]) {
+			return true
+		}
+		if last.child {
+			break
+		}
+	}
+	return false
+}
+
+func (n *htmlNode) matchesStep(step selectorStep) bool {
+	if step.tag != "" && step.tag != "*" && step.tag != n.tag {
+		return false
+	}
+	if step.id != "" && n.attrs["id"] != step.id {
+		return false
+	}
+	classes := strings.Fields(n.attrs["class"])
+	for _, class := range step.classes {
+		if !ContainsString(classes, class) {
+			return false
+		}
+	}
+	for _, attr := range step.attrs {
+		value, ok := n.attrs[attr.name]
+		if !ok || (attr.hasValue && value != attr.value) {
+			return false
+		}
+	}
+	return true
+}
@@ -34,12 +34,21 @@ type Watcher struct {
 	forceRefresh bool
 	lastError    int
 	notifyMutex  sync.Mutex
+
+	// The errors of the listeners refreshed by the eager goroutine, by index,
+	// which Notify() returns rather than refreshing them again.
+	refreshed map[int]*Error
+
+	// Eager refreshes listeners as soon as their files change, rather than on
+	// the next request, as with watcher.mode = eager.  Set before Listen.
+	Eager bool
 }
 
 func NewWatcher() *Watcher {
 	return &Watcher{
 		forceRefresh: true,
 		lastError:    -1,
+		refreshed:    make(map[int]*Error),
 	}
 }
 
@@ -75,13 +84,15 @@ func (w *Watcher) Listen(listener Listener, roots ...string) {
 		}
 	}
 
+	w.notifyMutex.Lock()
+	w.events = append(w.events, eventCh)
+	w.listeners = append(w.listeners, listener)
+	w.notifyMutex.Unlock()
+
 	if w.eagerRebuildEnabled() {
 		// Create goroutine to notify file changes in real time
 		go w.NotifyWhenUpdated(listener, eventCh)
 	}
-
-	w.events = append(w.events, eventCh)
-	w.listeners = append(w.listeners, listener)
 }
 
 // NotifyWhenUpdated notifies the watcher when a file event is received.
@@ -89,12 +100,38 @@ func (w *Watcher) NotifyWhenUpdated(listener Listener, eventCh chan notify.Event
 	for {
 		select {
 		case ev := <-eventCh:
-			if w.rebuildRequired(ev, listener) {
-				// Serialize listener.Refresh() calls.
-				w.notifyMutex.Lock()
-				listener.Refresh()
-				w.notifyMutex.Unlock()
+			if !w.rebuildRequired(ev, listener) {
+				continue
 			}
+			// Saving a file usually raises several events: refresh once for
+			// those already pending.
+			w.drain(eventCh)
+
+			w.refresh(listener)
+		}
+	}
+}
+
+// Refresh the listener on behalf of the next Notify().
+func (w *Watcher) refresh(listener Listener) {
+	// Serialize listener.Refresh() calls.
+	w.notifyMutex.Lock()
+	defer w.notifyMutex.Unlock()
+	for i, l := range w.listeners {
+		if l == listener {
+			w.refreshed[i] = listener.Refresh()
+			return
+		}
+	}
+}
+
+// Discard the events pending on the channel.
+func (w *Watcher) drain(eventCh chan notify.EventInfo) {
+	for {
+		select {
+		case <-eventCh:
+		default:
+			return
 		}
 	}
 }
@@ -124,7 +161,18 @@ func (w *Watcher) Notify() *Error {
 			break
 		}
 
+		// Already refreshed since its last change: a failed refresh would fail
+		// again.
+		if err, ok := w.refreshed[i]; ok && !refresh {
+			if err != nil {
+				w.lastError = i
+				return err
+			}
+			continue
+		}
+
 		if w.forceRefresh || refresh || w.lastError == i {
+			delete(w.refreshed, i)
 			err := listener.Refresh()
 			if err != nil {
 				w.lastError = i
@@ -142,6 +190,9 @@ func (w *Watcher) Notify() *Error {
 // when a source file is changed.
 // This feature is available only in dev mode.
 func (w *Watcher) eagerRebuildEnabled() bool {
+	if w.Eager {
+		return true
+	}
 	return Config.BoolDefault("mode.dev", true) &&
 		Config.BoolDefault("watch", true) &&
 		Config.StringDefault("watcher.mode", "normal") == "eager"
@@ -0,0 +1,54 @@
+package revel
+
+import (
+	"testing"
+
+	"github.com/rjeczalik/notify"
+)
+
+// A Listener which counts its refreshes, failing with the given error.
+type countingListener struct {
+	refreshes int
+	err       *Error
+}
+
+func (l *countingListener) Refresh() *Error {
+	l.refreshes++
+	return l.err
+}
+
+func TestNotifyAfterEagerRefresh(t *testing.T) {
+	w := NewWatcher()
+	listener := &countingListener{err: &Error{Title: "Compilation Error"}}
+	w.events = append(w.events, make(chan notify.EventInfo, 1))
+	w.listeners = append(w.listeners, listener)
+
+	// The failed eager refresh is not done again by the requests.
+	w.refresh(listener)
+	for i := 0; i < 2; i++ {
+		if err := w.Notify(); err != listener.err {
+			t.Errorf("Expected the error of the eager refresh, got %v", err)
+		}
+	}
+	if listener.refreshes != 1 {
+		t.Errorf("Expected 1 refresh, got %d", listener.refreshes)
+	}
+
+	// Once fixed, the next request does not refresh it either.
+	listener.err = nil
+	w.refresh(listener)
+	if err := w.Notify(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if listener.refreshes != 2 {
+		t.Errorf("Expected 2 refreshes, got %d", listener.refreshes)
+	}
+
+	// Without the eager refresh, the first request refreshes the listener.
+	w = NewWatcher()
+	w.events = append(w.events, make(chan notify.EventInfo, 1))
+	w.listeners = append(w.listeners, listener)
+	if err := w.Notify(); err != nil || listener.refreshes != 3 {
+		t.Errorf("Expected a refresh without error, got %d refreshes and %v", listener.refreshes, err)
+	}
+}